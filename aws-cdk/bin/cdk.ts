@@ -6,6 +6,7 @@ import {BackendStack} from '../lib/backend-stack';
 import {FrontendStack} from '../lib/frontend-stack';
 import {CustomDomainDistributionStack} from '../lib/custom-domain-distribution-stack';
 import {EC2Stack} from '../lib/ec2-stack';
+import {IdleDetectorStack} from '../lib/idle-detector-stack';
 import {CustomDomainConfigLoader} from '../lib/utils/custom-domain-config';
 import {execSync} from "child_process";
 
@@ -63,6 +64,14 @@ const ec2Stack = new EC2Stack(app, `total-ctl-ec2-${environment}-2`, {
 // Add dependencies
 ec2Stack.addDependency(infrastructureStack);
 
+// The idle detector looks after every branch environment's cluster, so only the main branch deploys it
+if (environment === 'main' || environment === 'master') {
+    new IdleDetectorStack(app, 'total-ctl-idle-detector', {
+        env: { account, region },
+        description: `Shrinks idle branch-environment DocumentDB clusters in ${region} (${account})`,
+    });
+}
+
 // Check if this environment has a custom domain configured
 const customDomainConfig = CustomDomainConfigLoader.getCustomDomainForEnvironment(environment);
 if (customDomainConfig) {
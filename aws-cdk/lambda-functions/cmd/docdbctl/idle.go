@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	awsClients "docdb-autoscaling-lambdas/internal/aws"
+	"docdb-autoscaling-lambdas/internal/config"
+	"docdb-autoscaling-lambdas/internal/idle"
+)
+
+// runIdle implements idle report, idle shrink and idle restart with the
+// idle-detector logic
+func runIdle(out *output, args []string) error {
+	command, args, err := subcommand("idle", args, "report|shrink|restart")
+	if err != nil {
+		return err
+	}
+	if command != "report" && command != "shrink" && command != "restart" {
+		return usageError(fmt.Sprintf("unknown idle command %q: must be report, shrink or restart", command))
+	}
+
+	cfg, err := config.IdleDetectorConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("idle "+command, flag.ExitOnError)
+	var cluster *string
+	if command == "restart" {
+		cluster = flags.String("cluster", envDefault("CLUSTER_IDENTIFIER", ""), "cluster to start and resume autoscaling on")
+	} else {
+		flags.IntVar(&cfg.IdleHours, "hours", cfg.IdleHours, "hours without use before a cluster counts as idle")
+		flags.BoolVar(&cfg.StopClusters, "stop", cfg.StopClusters, "also stop idle clusters")
+	}
+	flags.Parse(args)
+
+	clients, err := awsClients.NewClients()
+	if err != nil {
+		return fmt.Errorf("failed to create AWS clients: %w", err)
+	}
+	cfg.DryRun = command == "report"
+	detector, err := idle.New(clients, cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if command == "restart" {
+		if *cluster == "" {
+			return errors.New("-cluster or CLUSTER_IDENTIFIER is required")
+		}
+		if err := detector.Restart(ctx, *cluster); err != nil {
+			return err
+		}
+		return out.print(map[string]string{"restarted": *cluster}, func(w io.Writer) {
+			fmt.Fprintf(w, "%s\trestarted\n", *cluster)
+		})
+	}
+
+	report, err := detector.Run(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	err = out.print(report, func(w io.Writer) {
+		fmt.Fprintln(w, "CLUSTER\tENVIRONMENT\tIDLE\tREADERS\tSAVINGS/H\tREASON")
+		for _, c := range report.Clusters {
+			reason := c.Reason
+			if c.Error != "" {
+				reason += ": " + c.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%.3f\t%s\n", c.ClusterIdentifier, c.Environment, c.Idle,
+				strings.Join(c.Readers, ","), c.HourlySavings, reason)
+		}
+		fmt.Fprintln(w)
+		verb := "Saved"
+		if report.DryRun {
+			verb = "Would save"
+		}
+		fmt.Fprintf(w, "%s %.3f %s/h (%.0f %s/month)\n", verb, report.HourlySavings, report.Currency, report.MonthlySavings, report.Currency)
+		if len(report.Unpriced) > 0 {
+			fmt.Fprintf(w, "Not priced: %s\n", strings.Join(report.Unpriced, ", "))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if report.HasErrors() {
+		return errors.New("some idle clusters could not be shrunk")
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	awsClients "docdb-autoscaling-lambdas/internal/aws"
+	"docdb-autoscaling-lambdas/internal/docdb"
+	"docdb-autoscaling-lambdas/internal/loadgen"
+)
+
+// runLoadtest implements loadtest run and cleanup with the load-generator
+// logic
+func runLoadtest(out *output, args []string) error {
+	command, args, err := subcommand("loadtest", args, "run|cleanup")
+	if err != nil {
+		return err
+	}
+	switch command {
+	case "run":
+	case "cleanup":
+		return runLoadtestCleanup(out, args)
+	default:
+		return usageError(fmt.Sprintf("unknown loadtest command %q: must be run or cleanup", command))
+	}
+
+	var request loadgen.Request
+	flags := flag.NewFlagSet("loadtest run", flag.ExitOnError)
+	uri := flags.String("uri", envDefault("MONGODB_CONNECTION_STRING", ""), "DocumentDB connection string")
+	flags.IntVar(&request.DurationMinutes, "duration", 5, "test duration in minutes")
+	flags.IntVar(&request.NumThreads, "threads", 5, "concurrent workers")
+	flags.StringVar(&request.OperationType, "operation", "", "operation type: read, write or mixed (default read)")
+	flags.StringVar(&request.Workload, "workload", "", "workload template: users, points, roles, badwords or holograms (default synthetic documents)")
+	readPreferences := flags.String("read-preference", "", "read preference by read operation type or read for all, e.g. find=secondary:120,aggregate=primary")
+	mix := flags.String("mix", "", "weighted operations instead of -operation, e.g. find=60,insert=20,update=15,aggregate=5")
+	flags.Float64Var(&request.TargetRate, "rate", 0, "operations per second across all workers (default: as fast as the workers go)")
+	flags.Float64Var(&request.LateAfterMs, "late-after-ms", 10, "with -rate, lag behind schedule that counts as late")
+	flags.IntVar(&request.IntervalSeconds, "interval", 10, "seconds per interval of the latency series in -o json output")
+	flags.Float64Var(&request.MaxErrorRate, "max-error-rate", 0, "fail the run if more than this fraction of operations fail (default: never)")
+	profile := flags.String("profile", "", "JSON file with a list of load profile phases (replaces -duration and -rate)")
+	seedDocuments := flags.Int64("seed-documents", 0, "documents to write before the measured run, across all workers")
+	documentBytes := flags.Int("document-bytes", 0, "data in each synthetic document (default 1000)")
+	var seedIndexes indexFlags
+	flags.Var(&seedIndexes, "seed-index", "extra index to create before the run, e.g. thread_id,hits:-1 (repeatable)")
+	flags.StringVar(&request.Teardown, "teardown", "keep", "what happens to the test data after the run: keep, drop or ttl")
+	flags.IntVar(&request.TTLHours, "ttl-hours", 24, "with -teardown ttl, hours before documents expire")
+	flags.IntVar(&request.Invocations, "invocations", 0, "copies to split the load across, each running -threads workers")
+	function := flags.String("function", "", "with -invocations, the load-generator function to invoke (default: run the copies in this process)")
+	flags.IntVar(&request.StartDelaySeconds, "start-delay", 30, "with -invocations, seconds the copies get to start and seed")
+	flags.StringVar(&request.Checkpoint, "checkpoint", "", "continue a run that stopped for a deadline from its checkpoint")
+	flags.Parse(args)
+
+	if *uri == "" {
+		return errors.New("-uri or MONGODB_CONNECTION_STRING is required")
+	}
+	if *mix != "" {
+		if request.Mix, err = loadgen.ParseMix(*mix); err != nil {
+			return usageError(err.Error())
+		}
+	}
+	if *readPreferences != "" {
+		if request.ReadPreferences, err = loadgen.ParseReadPreferences(*readPreferences); err != nil {
+			return usageError(err.Error())
+		}
+	}
+	if *seedDocuments > 0 || *documentBytes > 0 || len(seedIndexes) > 0 {
+		request.Seed = &loadgen.Seed{Documents: *seedDocuments, DocumentBytes: *documentBytes, Indexes: seedIndexes}
+	}
+	if *profile != "" {
+		data, err := os.ReadFile(*profile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &request.Profile); err != nil {
+			return fmt.Errorf("%s: invalid profile: %w", *profile, err)
+		}
+	}
+	request.SetDefaults()
+	if err := request.Validate(); err != nil {
+		return usageError(err.Error())
+	}
+
+	ctx := context.Background()
+	client, err := connect(ctx, *uri)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	var result loadgen.Result
+	switch {
+	case request.Invocations > 1 && *function != "":
+		clients, err := awsClients.NewClients()
+		if err != nil {
+			return fmt.Errorf("failed to create AWS clients: %w", err)
+		}
+		result = loadgen.Coordinate(ctx, client, loadgen.LambdaInvoker{Lambda: clients.Lambda, FunctionName: *function}, request)
+	case request.Invocations > 1:
+		result = loadgen.Coordinate(ctx, client, loadgen.LocalInvoker{Client: client}, request)
+	default:
+		result = loadgen.Run(ctx, client, request)
+	}
+
+	err = out.print(result, func(w io.Writer) {
+		fmt.Fprintln(w, "THREAD\tOPERATIONS\tSECONDS\tERROR")
+		for _, thread := range result.ThreadResults {
+			fmt.Fprintf(w, "%d\t%d\t%.1f\t%s\n", thread.ThreadID, thread.OperationsCompleted, thread.DurationSeconds, thread.Error)
+		}
+		fmt.Fprintf(w, "TOTAL\t%d\t\t\n", result.TotalOperations)
+		if rate := result.Rate; rate != nil {
+			fmt.Fprintf(w, "RATE\t%.1f/s of %.1f/s\t\t%d scheduled, %d missed, %d late, max lag %.0fms\n",
+				rate.AchievedRate, rate.RequestedRate, rate.Scheduled, rate.Missed, rate.Late, rate.MaxLagMs)
+		}
+		for _, invocation := range result.Invocations {
+			if invocation.Error != "" {
+				fmt.Fprintf(w, "INVOCATION %d\t\t\t%s\n", invocation.Invocation, invocation.Error)
+			}
+		}
+		if result.Stopped() {
+			fmt.Fprintf(w, "CHECKPOINT\t%.0fs left\t\t%s\n", result.RemainingSeconds, result.Checkpoint)
+		}
+		if seed := result.Seed; seed != nil {
+			fmt.Fprintf(w, "SEED\t%d\t%.1f\t%s\n", seed.Documents, seed.Seconds, seed.Error)
+		}
+		if teardown := result.Teardown; teardown != nil {
+			fmt.Fprintf(w, "TEARDOWN\t%s\t\t%s %s\n", teardown.Action, strings.Join(teardown.Namespaces, ","), teardown.Error)
+		}
+		if len(result.Phases) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "PHASE\tSHAPE\tSECONDS\tOPERATIONS\tRATE")
+			for i, phase := range result.Phases {
+				rate := fmt.Sprintf("%.1f/s", phase.AchievedRate)
+				if phase.RequestedRate > 0 {
+					rate += fmt.Sprintf(" of %.1f/s", phase.RequestedRate)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%.0f-%.0f\t%d\t%s\n", i+1, phase.Shape, phase.StartSeconds, phase.EndSeconds, phase.Operations, rate)
+			}
+		}
+		if len(result.Errors) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "OPERATION\tCLASS\tERRORS\tSAMPLE")
+			for _, e := range result.Errors {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", e.Operation, e.Class, e.Count, e.Samples[0])
+			}
+			fmt.Fprintf(w, "ERROR RATE\t%.2f%%\t\t\n", result.ErrorRate*100)
+		}
+		if len(result.Servers) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "OPERATION\tSERVER\tCOMMANDS")
+			for _, server := range result.Servers {
+				fmt.Fprintf(w, "%s\t%s\t%d\n", server.Operation, server.Address, server.Commands)
+			}
+		}
+		if len(result.Latency) > 0 {
+			operations := make([]string, 0, len(result.Latency))
+			for op := range result.Latency {
+				operations = append(operations, op)
+			}
+			sort.Strings(operations)
+
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "OPERATION\tCOUNT\tP50\tP90\tP99\tP99.9\tMAX")
+			for _, op := range operations {
+				l := result.Latency[op]
+				fmt.Fprintf(w, "%s\t%d\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.1fms\n", op, l.Count, l.P50Ms, l.P90Ms, l.P99Ms, l.P999Ms, l.MaxMs)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if result.HasErrors() {
+		return errors.New(result.Message)
+	}
+	return nil
+}
+
+// runLoadtestCleanup implements loadtest cleanup, which drops the databases
+// of earlier runs
+func runLoadtestCleanup(out *output, args []string) error {
+	flags := flag.NewFlagSet("loadtest cleanup", flag.ExitOnError)
+	uri := flags.String("uri", envDefault("MONGODB_CONNECTION_STRING", ""), "DocumentDB connection string")
+	flags.Parse(args)
+
+	if *uri == "" {
+		return errors.New("-uri or MONGODB_CONNECTION_STRING is required")
+	}
+
+	ctx := context.Background()
+	client, err := connect(ctx, *uri)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	result, err := loadgen.Cleanup(ctx, client)
+	if err != nil {
+		return err
+	}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintln(w, "DROPPED DATABASE")
+		for _, name := range result.Dropped {
+			fmt.Fprintln(w, name)
+		}
+	})
+}
+
+// connect connects a client that counts the members serving a run
+func connect(ctx context.Context, uri string) (*docdb.Client, error) {
+	client, err := docdb.NewClient(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DocumentDB client: %w", err)
+	}
+	client.SetCommandMonitor(loadgen.CommandMonitor)
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// indexFlags collects -seed-index flags, each of which may also list
+// several indexes separated by semicolons
+type indexFlags []string
+
+func (f *indexFlags) String() string {
+	return strings.Join(*f, ";")
+}
+
+func (f *indexFlags) Set(value string) error {
+	for _, spec := range strings.Split(value, ";") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			*f = append(*f, spec)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Command docdbctl is the operator CLI for DocumentDB autoscaling. It runs
+// the same code as the Lambda functions from a workstation or CI job.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+const usage = `Usage: docdbctl [-o table|json] [-v] <command> [arguments]
+
+Commands:
+  status                      cluster members, CloudWatch metrics and alarm states
+  scale plan                  show the scaling decision without acting on it
+  scale apply                 run one autoscaler evaluation, including its action
+  scale pause                 pause (or with -resume, resume) autoscaling
+  loadtest run                generate load against the cluster
+  loadtest cleanup            drop the test databases of earlier load tests
+  policy validate <file>      check a policy document against a base configuration
+  policy simulate <file>      show the decision a policy makes for given metrics
+  idle report                 list idle branch-environment clusters and the possible savings
+  idle shrink                 remove the readers of idle clusters (and with -stop, stop them)
+  idle restart                start a shrunk cluster and resume its autoscaling
+
+Run "docdbctl <command> -h" for the flags of a command.
+`
+
+// output renders command results as an aligned table or as JSON
+type output struct {
+	format string
+	w      io.Writer
+}
+
+// print writes v as indented JSON, or calls table with a tab-aligned writer
+func (o *output) print(v interface{}, table func(w io.Writer)) error {
+	if o.format == "json" {
+		encoder := json.NewEncoder(o.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("docdbctl", flag.ExitOnError)
+	format := flags.String("o", "table", "output format: table or json")
+	verbose := flags.Bool("v", false, "log progress to stderr")
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	flags.Parse(args)
+
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Invalid output format %q: must be table or json\n", *format)
+		return 2
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	out := &output{format: *format, w: os.Stdout}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "status":
+		err = runStatus(out, args[1:])
+	case "scale":
+		err = runScale(out, args[1:])
+	case "loadtest":
+		err = runLoadtest(out, args[1:])
+	case "policy":
+		err = runPolicy(out, args[1:])
+	case "idle":
+		err = runIdle(out, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if _, ok := err.(usageError); ok {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// usageError is returned for a missing or unknown subcommand or argument
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// subcommand returns the first argument, or a usage error naming the choices
+func subcommand(command string, args []string, choices string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", nil, usageError(fmt.Sprintf("usage: docdbctl %s %s", command, choices))
+	}
+	return args[0], args[1:], nil
+}
+
+// envDefault returns the environment variable key, or fallback if unset
+func envDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"docdb-auto-scaling/autoscaler"
+)
+
+// runPolicy implements policy validate and policy simulate. Both run offline:
+// the policy is applied to the defaults, or to the configuration in -env-file.
+func runPolicy(out *output, args []string) error {
+	command, args, err := subcommand("policy", args, "validate|simulate <file>")
+	if err != nil {
+		return err
+	}
+	if command != "validate" && command != "simulate" {
+		return usageError(fmt.Sprintf("unknown policy command %q: must be validate or simulate", command))
+	}
+
+	flags := flag.NewFlagSet("policy "+command, flag.ExitOnError)
+	envFile := flags.String("env-file", "", "base configuration the policy overrides (default: built-in defaults)")
+	var metrics autoscaler.Metrics
+	var readers *int
+	var freeableMB *float64
+	if command == "simulate" {
+		flags.Float64Var(&metrics.WriterCPU, "writer-cpu", 0, "writer CPU utilization percent")
+		flags.Float64Var(&metrics.ReaderCPU, "reader-cpu", 0, "average reader CPU utilization percent")
+		flags.Float64Var(&metrics.WriterConnections, "connections", 0, "writer database connections")
+		flags.Float64Var(&metrics.ReplicaLag, "replica-lag", 0, "maximum reader replica lag in milliseconds")
+		freeableMB = flags.Float64("freeable-memory-mb", 0, "writer freeable memory in megabytes (0: unknown)")
+		readers = flags.Int("readers", 1, "current number of readers")
+	}
+
+	// Accept the file before or after the flags
+	var file string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		file, args = args[0], args[1:]
+	}
+	flags.Parse(args)
+	if file == "" && flags.NArg() > 0 {
+		file = flags.Arg(0)
+	}
+	if file == "" {
+		return usageError(fmt.Sprintf("usage: docdbctl policy %s <file>", command))
+	}
+
+	var base *autoscaler.Config
+	if *envFile != "" {
+		base, err = autoscaler.LoadConfigFile(*envFile)
+	} else {
+		base, err = autoscaler.DefaultConfig(envDefault("CLUSTER_IDENTIFIER", "policy-check"))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid base configuration:\n%w", err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	doc, err := autoscaler.ParsePolicy(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	cfg, err := doc.Apply(base)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	switch command {
+	case "validate":
+		result := struct {
+			File    string `json:"file"`
+			Version string `json:"version"`
+			Valid   bool   `json:"valid"`
+		}{File: file, Version: doc.Version, Valid: true}
+		return out.print(result, func(w io.Writer) {
+			fmt.Fprintf(w, "%s: ok (version %s)\n%s", file, doc.Version, cfg.Summary())
+		})
+	default:
+		if *readers < 0 {
+			return fmt.Errorf("invalid -readers %d: must not be negative", *readers)
+		}
+		metrics.FreeableMemory = *freeableMB * 1024 * 1024
+		decision := autoscaler.Decide(cfg, &autoscaler.ClusterInfo{ReaderCount: *readers}, &metrics)
+		decision.PolicyVersion = doc.Version
+		return out.print(decision, func(w io.Writer) {
+			fmt.Fprintf(w, "ACTION\t%s\n", decision.Action)
+			fmt.Fprintf(w, "REASON\t%s\n", decision.Reason)
+			if decision.Action != autoscaler.ActionNone && decision.Rule == "" {
+				// Scores sit around 1.0, so they need the extra precision
+				format := "%.1f"
+				if decision.Score != nil {
+					format = "%.2f"
+				}
+				fmt.Fprintf(w, "THRESHOLD\t"+format+"\n", decision.Threshold)
+				fmt.Fprintf(w, "CURRENT\t"+format+"\n", decision.Current)
+			}
+			if decision.Score != nil {
+				signals := make([]string, 0, len(decision.Score.Signals))
+				for _, signal := range decision.Score.Signals {
+					signals = append(signals, fmt.Sprintf("%s=%.2f", signal.Name, signal.Pressure))
+				}
+				fmt.Fprintf(w, "SCORE\t%.2f (%s)\n", decision.Score.Value, strings.Join(signals, " "))
+			}
+			if decision.Rule != "" {
+				fmt.Fprintf(w, "RULE\t%s\n", decision.Rule)
+			}
+			fmt.Fprintf(w, "POLICY\t%s\n", decision.PolicyVersion)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"docdb-auto-scaling/autoscaler"
+)
+
+// runScale implements scale plan, scale apply and scale pause
+func runScale(out *output, args []string) error {
+	command, args, err := subcommand("scale", args, "plan|apply|pause")
+	if err != nil {
+		return err
+	}
+	if command != "plan" && command != "apply" && command != "pause" {
+		return usageError(fmt.Sprintf("unknown scale command %q: must be plan, apply or pause", command))
+	}
+
+	flags := flag.NewFlagSet("scale "+command, flag.ExitOnError)
+	envFile := flags.String("env-file", "", "read the autoscaler configuration from this env file instead of the environment")
+	environment := flags.String("environment", envDefault("ENVIRONMENT", ""), "environment name that selects the scaling profile")
+	var pauseFor *time.Duration
+	var resume *bool
+	if command == "pause" {
+		pauseFor = flags.Duration("for", time.Hour, "how long to pause autoscaling")
+		resume = flags.Bool("resume", false, "resume autoscaling instead of pausing it")
+	}
+	flags.Parse(args)
+
+	var cfg *autoscaler.Config
+	if *envFile != "" {
+		cfg, err = autoscaler.LoadConfigFile(*envFile)
+	} else {
+		cfg, err = autoscaler.LoadConfig()
+	}
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	scaler, err := autoscaler.New(cfg, session.Must(session.NewSession()))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	event := autoscaler.SchedulerEvent{
+		Source:            "docdbctl",
+		Environment:       *environment,
+		ClusterIdentifier: cfg.ClusterIdentifier,
+	}
+
+	switch command {
+	case "plan":
+		return printEvaluation(out, scaler.Plan(ctx, event))
+	case "apply":
+		return printEvaluation(out, scaler.Evaluate(ctx, event))
+	default:
+		if *resume {
+			err = scaler.Resume(ctx)
+		} else if *pauseFor <= 0 {
+			return fmt.Errorf("invalid -for %s: must be positive", *pauseFor)
+		} else {
+			err = scaler.Pause(ctx, time.Now().Add(*pauseFor))
+		}
+		if err != nil {
+			return err
+		}
+
+		pausedUntil, err := scaler.PausedUntil(ctx)
+		if err != nil {
+			return err
+		}
+		state := struct {
+			Cluster     string     `json:"cluster"`
+			Paused      bool       `json:"paused"`
+			PausedUntil *time.Time `json:"pausedUntil,omitempty"`
+		}{Cluster: cfg.ClusterIdentifier}
+		if time.Now().Before(pausedUntil) {
+			state.Paused = true
+			state.PausedUntil = &pausedUntil
+		}
+		return out.print(state, func(w io.Writer) {
+			if state.Paused {
+				fmt.Fprintf(w, "%s\tpaused until %s\n", state.Cluster, pausedUntil.Format(time.RFC3339))
+			} else {
+				fmt.Fprintf(w, "%s\tactive\n", state.Cluster)
+			}
+		})
+	}
+}
+
+// printEvaluation prints an evaluation and fails if it didn't succeed
+func printEvaluation(out *output, ev autoscaler.Evaluation) error {
+	err := out.print(ev, func(w io.Writer) {
+		if ev.ClusterInfo != nil {
+			fmt.Fprintf(w, "READERS\t%d\n", ev.ClusterInfo.ReaderCount)
+		}
+		if ev.Metrics != nil {
+			fmt.Fprintf(w, "WRITER CPU\t%.1f%%\n", ev.Metrics.WriterCPU)
+			fmt.Fprintf(w, "READER CPU\t%.1f%%\n", ev.Metrics.ReaderCPU)
+			fmt.Fprintf(w, "WRITER CONNECTIONS\t%.0f\n", ev.Metrics.WriterConnections)
+		}
+		if ev.Decision != nil {
+			fmt.Fprintf(w, "ACTION\t%s\n", ev.Decision.Action)
+			fmt.Fprintf(w, "REASON\t%s\n", ev.Decision.Reason)
+			fmt.Fprintf(w, "WINDOW\t%s\n", ev.Decision.Window.Format(time.RFC3339))
+			fmt.Fprintf(w, "POLICY\t%s\n", ev.Decision.PolicyVersion)
+			fmt.Fprintf(w, "PROFILE\t%s\n", ev.Decision.Profile)
+		}
+		fmt.Fprintf(w, "RESULT\t%d %s\n", ev.Response.StatusCode, ev.Response.Body)
+		if ev.Response.Cost != nil {
+			fmt.Fprintf(w, "COST\t%s\n", ev.Response.Cost)
+		}
+		if ev.Plan != nil {
+			fmt.Fprintf(w, "\nPLAN\n%s", ev.Plan)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if ev.Response.StatusCode >= 300 {
+		return errors.New(ev.Response.Body)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	awsClients "docdb-autoscaling-lambdas/internal/aws"
+	"docdb-autoscaling-lambdas/internal/checker"
+	"docdb-autoscaling-lambdas/internal/config"
+)
+
+// runStatus implements the status command with the metrics-checker logic
+func runStatus(out *output, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	cluster := flags.String("cluster", envDefault("CLUSTER_IDENTIFIER", ""), "cluster identifier")
+	environment := flags.String("environment", envDefault("ENVIRONMENT", ""), "environment name used in the alarm names")
+	lookback := flags.Int("lookback", 10, "minutes of CloudWatch metrics to summarise")
+	flags.Parse(args)
+
+	if *cluster == "" {
+		return errors.New("-cluster or CLUSTER_IDENTIFIER is required")
+	}
+	if *lookback <= 0 {
+		return fmt.Errorf("invalid -lookback %d: must be positive", *lookback)
+	}
+
+	clients, err := awsClients.NewClients()
+	if err != nil {
+		return fmt.Errorf("failed to create AWS clients: %w", err)
+	}
+
+	cfg := &config.MetricsCheckerConfig{ClusterIdentifier: *cluster, Environment: *environment}
+	result, err := checker.New(clients, cfg).Check(context.Background(), *lookback)
+	if err != nil {
+		return err
+	}
+
+	return out.print(result, func(w io.Writer) {
+		status := result.ClusterStatus
+		fmt.Fprintf(w, "CLUSTER\t%s\n", status.ClusterID)
+		fmt.Fprintf(w, "STATUS\t%s\n", status.Status)
+		fmt.Fprintf(w, "WRITERS\t%d\n", status.WriterInstances)
+		fmt.Fprintf(w, "READERS\t%d\n", status.ReadReplicas)
+		fmt.Fprintln(w)
+
+		fmt.Fprintln(w, "READER\tCLASS\tSTATUS")
+		for _, replica := range status.ReadReplicaDetails {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", replica.InstanceID, replica.InstanceClass, replica.Status)
+		}
+		fmt.Fprintln(w)
+
+		metrics := result.Metrics
+		fmt.Fprintf(w, "METRIC (%dm)\tAVERAGE\tMAXIMUM\n", *lookback)
+		fmt.Fprintf(w, "cpu\t%.2f\t%.2f\n", metrics.CPUUtilization.Average, metrics.CPUUtilization.Maximum)
+		fmt.Fprintf(w, "writer cpu\t%.2f\t%.2f\n", metrics.WriterCPUUtilization.Average, metrics.WriterCPUUtilization.Maximum)
+		fmt.Fprintf(w, "reader cpu\t%.2f\t%.2f\n", metrics.ReaderCPUUtilization.Average, metrics.ReaderCPUUtilization.Maximum)
+		fmt.Fprintf(w, "connections\t%.0f\t%.0f\n", metrics.DatabaseConnections.Average, metrics.DatabaseConnections.Maximum)
+		fmt.Fprintln(w)
+
+		names := make([]string, 0, len(result.AlarmStates))
+		for name := range result.AlarmStates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(w, "ALARM\tSTATE\tDETAIL")
+		for _, name := range names {
+			alarm := result.AlarmStates[name]
+			if alarm.Error != "" {
+				fmt.Fprintf(w, "%s\t-\t%s\n", name, alarm.Error)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", name, alarm.State, alarm.Updated)
+			}
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	awsClients "docdb-autoscaling-lambdas/internal/aws"
+	"docdb-autoscaling-lambdas/internal/config"
+	"docdb-autoscaling-lambdas/internal/idle"
+)
+
+// IdleDetectorRequest represents the input to the idle detector function
+type IdleDetectorRequest struct {
+	// "scan" (default) shrinks idle clusters, "restart" undoes it for ClusterIdentifier
+	Action            string `json:"action,omitempty"`
+	ClusterIdentifier string `json:"clusterIdentifier,omitempty"`
+	// Overrides IDLE_DRY_RUN for this invocation
+	DryRun *bool `json:"dryRun,omitempty"`
+}
+
+// IdleDetectorResponse represents the output of the idle detector function
+type IdleDetectorResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+func errorResponse(statusCode int, message string, err error) IdleDetectorResponse {
+	body, _ := json.Marshal(map[string]string{"error": err.Error(), "message": message})
+	return IdleDetectorResponse{StatusCode: statusCode, Body: string(body)}
+}
+
+// handler is the main Lambda function handler
+func handler(ctx context.Context, request IdleDetectorRequest) (IdleDetectorResponse, error) {
+	log.Printf("Received request: %+v", request)
+
+	cfg, err := config.IdleDetectorConfigFromEnv()
+	if err != nil {
+		return errorResponse(500, "Configuration error", err), nil
+	}
+	if request.DryRun != nil {
+		cfg.DryRun = *request.DryRun
+	}
+
+	clients, err := awsClients.NewClients()
+	if err != nil {
+		return errorResponse(500, "Failed to create AWS clients", err), nil
+	}
+	detector, err := idle.New(clients, cfg)
+	if err != nil {
+		return errorResponse(500, "Failed to load prices", err), nil
+	}
+
+	switch request.Action {
+	case "", "scan":
+	case "restart":
+		if request.ClusterIdentifier == "" {
+			return errorResponse(400, "Invalid request", fmt.Errorf("restart needs clusterIdentifier")), nil
+		}
+		if err := detector.Restart(ctx, request.ClusterIdentifier); err != nil {
+			return errorResponse(500, "Restart failed", err), nil
+		}
+		return IdleDetectorResponse{StatusCode: 200, Body: fmt.Sprintf(`{"restarted": %q}`, request.ClusterIdentifier)}, nil
+	default:
+		return errorResponse(400, "Invalid request", fmt.Errorf("unknown action %q: must be scan or restart", request.Action)), nil
+	}
+
+	report, err := detector.Run(ctx, time.Now())
+	if err != nil {
+		return errorResponse(500, "Idle scan failed", err), nil
+	}
+	log.Printf("Idle scan of %d clusters done, savings %.2f %s/h (%.0f %s/month), dry run: %v",
+		len(report.Clusters), report.HourlySavings, report.Currency, report.MonthlySavings, report.Currency, report.DryRun)
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return errorResponse(500, "Failed to marshal response", err), nil
+	}
+	statusCode := 200
+	if report.HasErrors() {
+		statusCode = 207
+	}
+	return IdleDetectorResponse{StatusCode: statusCode, Body: string(body)}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
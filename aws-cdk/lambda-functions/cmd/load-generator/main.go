@@ -3,26 +3,22 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
-	"strings"
-	"sync"
-	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 
+	awsClients "docdb-autoscaling-lambdas/internal/aws"
 	"docdb-autoscaling-lambdas/internal/config"
 	"docdb-autoscaling-lambdas/internal/docdb"
+	"docdb-autoscaling-lambdas/internal/loadgen"
 )
 
 // LoadGeneratorRequest represents the input to the load generator function
-type LoadGeneratorRequest struct {
-	DurationMinutes int    `json:"durationMinutes"`
-	NumThreads      int    `json:"numThreads"`
-	OperationType   string `json:"operationType"` // "read", "write", "mixed"
-}
+type LoadGeneratorRequest = loadgen.Request
+
+// LoadGeneratorResult is the body of a successful response
+type LoadGeneratorResult = loadgen.Result
 
 // LoadGeneratorResponse represents the output of the load generator function
 type LoadGeneratorResponse struct {
@@ -30,105 +26,6 @@ type LoadGeneratorResponse struct {
 	Body       string `json:"body"`
 }
 
-// LoadGeneratorResult represents the detailed result
-type LoadGeneratorResult struct {
-	Message         string               `json:"message"`
-	TotalOperations int64                `json:"total_operations"`
-	ThreadResults   []ThreadResult       `json:"thread_results"`
-	TestParameters  LoadGeneratorRequest `json:"test_parameters"`
-}
-
-// ThreadResult represents the result from a single thread
-type ThreadResult struct {
-	ThreadID            int     `json:"thread_id"`
-	OperationsCompleted int64   `json:"operations_completed"`
-	DurationSeconds     float64 `json:"duration_seconds"`
-	Error               string  `json:"error,omitempty"`
-}
-
-// LoadWorker handles load generation for a single thread
-type LoadWorker struct {
-	threadID      int
-	operationType string
-	client        *docdb.Client
-}
-
-// generateLoad performs the actual load generation
-func (w *LoadWorker) generateLoad(ctx context.Context, duration time.Duration) ThreadResult {
-	startTime := time.Now()
-	var operationsCount int64
-
-	dbName := fmt.Sprintf("test_db_%d", w.threadID)
-	collectionName := "load_test"
-
-	// Generate load until duration expires
-	endTime := startTime.Add(duration)
-	for time.Now().Before(endTime) {
-		collection := w.client.Collection(dbName, collectionName)
-
-		// Perform operations based on type
-		if w.operationType == "write" || w.operationType == "mixed" {
-			if err := w.performWrite(ctx, collection, operationsCount); err != nil {
-				log.Printf("Write error in thread %d: %v", w.threadID, err)
-				continue
-			}
-			operationsCount++
-		}
-
-		if w.operationType == "read" || w.operationType == "mixed" {
-			if err := w.performRead(ctx, collection); err != nil {
-				log.Printf("Read error in thread %d: %v", w.threadID, err)
-				continue
-			}
-			operationsCount++
-		}
-
-		// Small delay to control load intensity
-		time.Sleep(10 * time.Millisecond)
-	}
-
-	return ThreadResult{
-		ThreadID:            w.threadID,
-		OperationsCompleted: operationsCount,
-		DurationSeconds:     time.Since(startTime).Seconds(),
-	}
-}
-
-// performWrite inserts a document into the collection
-func (w *LoadWorker) performWrite(ctx context.Context, collection *mongo.Collection, counter int64) error {
-	doc := bson.M{
-		"thread_id": w.threadID,
-		"timestamp": time.Now().Unix(),
-		"data":      strings.Repeat("x", 1000), // 1KB of data
-		"counter":   counter,
-	}
-
-	_, err := collection.InsertOne(ctx, doc)
-	return err
-}
-
-// performRead reads documents from the collection
-func (w *LoadWorker) performRead(ctx context.Context, collection *mongo.Collection) error {
-	filter := bson.M{"thread_id": w.threadID}
-	cursor, err := collection.Find(ctx, filter)
-	if err != nil {
-		return err
-	}
-	defer cursor.Close(ctx)
-
-	// Consume up to 100 documents
-	count := 0
-	for cursor.Next(ctx) && count < 100 {
-		var result bson.M
-		if err := cursor.Decode(&result); err != nil {
-			return err
-		}
-		count++
-	}
-
-	return cursor.Err()
-}
-
 // handler is the main Lambda function handler
 func handler(ctx context.Context, request LoadGeneratorRequest) (LoadGeneratorResponse, error) {
 	log.Printf("Starting load generation with parameters: %+v", request)
@@ -136,106 +33,63 @@ func handler(ctx context.Context, request LoadGeneratorRequest) (LoadGeneratorRe
 	// Load configuration
 	dbConfig, err := config.LoadGeneratorConfigFromEnv()
 	if err != nil {
-		return LoadGeneratorResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error": "%s", "message": "Configuration error"}`, err.Error()),
-		}, nil
+		return errorResponse(500, "Configuration error", err), nil
 	}
 
 	// Set defaults
-	if request.DurationMinutes <= 0 {
-		request.DurationMinutes = 5
-	}
-	if request.NumThreads <= 0 {
-		request.NumThreads = 5
-	}
-	if request.OperationType == "" {
-		request.OperationType = "read"
+	request.SetDefaults()
+	if err := request.Validate(); err != nil {
+		return errorResponse(400, "Invalid request", err), nil
 	}
 
 	log.Printf("Using MongoDB connection string: [REDACTED]")
-	log.Printf("Test parameters - Duration: %d min, Threads: %d, Operation: %s",
-		request.DurationMinutes, request.NumThreads, request.OperationType)
+	log.Printf("Test parameters - Duration: %d min, Threads: %d, Operation: %s, Workload: %s, Mix: %s, Target rate: %.1f ops/sec, Profile phases: %d, Invocations: %d",
+		request.DurationMinutes, request.NumThreads, request.OperationType, request.Workload, request.Mix, request.TargetRate, len(request.Profile), request.Invocations)
 
 	// Create a single, shared DocumentDB client
 	client, err := docdb.NewClient(dbConfig.MongoConnectionString)
 	if err != nil {
-		return LoadGeneratorResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error": "%s", "message": "Failed to create DocumentDB client"}`, err.Error()),
-		}, nil
+		return errorResponse(500, "Failed to create DocumentDB client", err), nil
 	}
+	client.SetCommandMonitor(loadgen.CommandMonitor)
 	if err := client.Connect(ctx); err != nil {
-		return LoadGeneratorResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error": "%s", "message": "Failed to connect to DocumentDB"}`, err.Error()),
-		}, nil
+		return errorResponse(500, "Failed to connect to DocumentDB", err), nil
 	}
 	defer client.Disconnect(ctx)
 
-	duration := time.Duration(request.DurationMinutes) * time.Minute
-
-	// Create workers and run them concurrently
-	var wg sync.WaitGroup
-	results := make([]ThreadResult, request.NumThreads)
-
-	for i := 0; i < request.NumThreads; i++ {
-		wg.Add(1)
-		go func(threadID int) {
-			defer wg.Done()
-
-			worker := &LoadWorker{
-				threadID:      threadID,
-				operationType: request.OperationType,
-				client:        client,
-			}
-
-			results[threadID] = worker.generateLoad(ctx, duration)
-			log.Printf("Thread %d completed: %+v", threadID, results[threadID])
-		}(i)
+	if request.Action == loadgen.ActionCleanup {
+		return cleanup(ctx, client)
 	}
 
-	// Wait for all workers to complete
-	wg.Wait()
-
-	// Calculate total operations
-	var totalOperations int64
-	for _, result := range results {
-		totalOperations += result.OperationsCompleted
-	}
-
-	// Prepare response
-	result := LoadGeneratorResult{
-		Message:         "Load generation completed",
-		TotalOperations: totalOperations,
-		ThreadResults:   results,
-		TestParameters:  request,
-	}
-
-	// Check if any threads had errors
-	hasErrors := false
-	for _, threadResult := range results {
-		if threadResult.Error != "" {
-			hasErrors = true
-			break
+	var result LoadGeneratorResult
+	if request.Invocations > 1 {
+		// Coordinate copies of this function, each generating a slice of the load
+		clients, err := awsClients.NewClients()
+		if err != nil {
+			return errorResponse(500, "Failed to create AWS clients", err), nil
 		}
+		invoker := loadgen.LambdaInvoker{Lambda: clients.Lambda, FunctionName: lambdacontext.FunctionName}
+		result = loadgen.Coordinate(ctx, client, invoker, request)
+	} else {
+		result = loadgen.Run(ctx, client, request)
 	}
 
 	statusCode := 200
-	if hasErrors {
-		result.Message = "Load generation completed with some errors"
+	switch {
+	case result.Failed():
+		statusCode = 500 // Error rate over maxErrorRate
+	case result.HasErrors():
 		statusCode = 206 // Partial success
+	case result.Stopped():
+		statusCode = 206 // Stopped for the function timeout; continue from result.Checkpoint
 	}
 
 	responseBody, err := json.Marshal(result)
 	if err != nil {
-		return LoadGeneratorResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf(`{"error": "%s", "message": "Failed to marshal response"}`, err.Error()),
-		}, nil
+		return errorResponse(500, "Failed to marshal response", err), nil
 	}
 
-	log.Printf("Load generation completed successfully. Total operations: %d", totalOperations)
+	log.Printf("%s. Total operations: %d, error rate: %.2f%%", result.Message, result.TotalOperations, result.ErrorRate*100)
 
 	return LoadGeneratorResponse{
 		StatusCode: statusCode,
@@ -243,6 +97,32 @@ func handler(ctx context.Context, request LoadGeneratorRequest) (LoadGeneratorRe
 	}, nil
 }
 
+// cleanup drops the test databases of earlier runs
+func cleanup(ctx context.Context, client *docdb.Client) (LoadGeneratorResponse, error) {
+	result, err := loadgen.Cleanup(ctx, client)
+	if err != nil {
+		return errorResponse(500, "Cleanup failed", err), nil
+	}
+
+	responseBody, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(500, "Failed to marshal response", err), nil
+	}
+
+	log.Printf("Cleanup dropped %d databases", len(result.Dropped))
+	return LoadGeneratorResponse{
+		StatusCode: 200,
+		Body:       string(responseBody),
+	}, nil
+}
+
+// errorResponse builds the body of a failed request with json.Marshal, since
+// error messages may contain quotes
+func errorResponse(statusCode int, message string, err error) LoadGeneratorResponse {
+	body, _ := json.Marshal(map[string]string{"error": err.Error(), "message": message})
+	return LoadGeneratorResponse{StatusCode: statusCode, Body: string(body)}
+}
+
 func main() {
 	lambda.Start(handler)
 }
@@ -3,6 +3,7 @@ module docdb-autoscaling-lambdas
 go 1.23.0
 
 require (
+	docdb-auto-scaling v0.0.0
 	github.com/aws/aws-lambda-go v1.47.0
 	github.com/aws/aws-sdk-go v1.50.0
 	go.mongodb.org/mongo-driver v1.13.1
@@ -20,4 +21,7 @@ require (
 	golang.org/x/crypto v0.35.0 // indirect
 	golang.org/x/sync v0.11.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace docdb-auto-scaling => ../lib/db-scaling
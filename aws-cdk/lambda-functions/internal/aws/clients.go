@@ -5,12 +5,14 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/docdb"
+	"github.com/aws/aws-sdk-go/service/lambda"
 )
 
 // Clients holds AWS service clients
 type Clients struct {
 	CloudWatch *cloudwatch.CloudWatch
 	DocDB      *docdb.DocDB
+	Lambda     *lambda.Lambda
 	Session    *session.Session
 }
 
@@ -26,6 +28,7 @@ func NewClients() (*Clients, error) {
 	return &Clients{
 		CloudWatch: cloudwatch.New(sess),
 		DocDB:      docdb.New(sess),
+		Lambda:     lambda.New(sess),
 		Session:    sess,
 	}, nil
 }
@@ -42,6 +45,7 @@ func NewClientsWithRegion(region string) (*Clients, error) {
 	return &Clients{
 		CloudWatch: cloudwatch.New(sess),
 		DocDB:      docdb.New(sess),
+		Lambda:     lambda.New(sess),
 		Session:    sess,
 	}, nil
 }
@@ -0,0 +1,315 @@
+// Package checker reports the state of a DocumentDB cluster: its members,
+// CloudWatch metrics and scaling alarms. It backs the metrics-checker Lambda
+// and the docdbctl status command.
+package checker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/docdb"
+
+	awsClients "docdb-autoscaling-lambdas/internal/aws"
+	"docdb-autoscaling-lambdas/internal/config"
+)
+
+// Result is the outcome of one check
+type Result struct {
+	ClusterStatus ClusterStatus         `json:"cluster_status"`
+	Metrics       MetricsData           `json:"metrics"`
+	AlarmStates   map[string]AlarmState `json:"alarm_states"`
+	Timestamp     string                `json:"timestamp"`
+}
+
+// ClusterStatus represents DocumentDB cluster information
+type ClusterStatus struct {
+	ClusterID          string              `json:"cluster_id"`
+	Status             string              `json:"status"`
+	TotalInstances     int                 `json:"total_instances"`
+	WriterInstances    int                 `json:"writer_instances"`
+	ReadReplicas       int                 `json:"read_replicas"`
+	ReadReplicaDetails []ReadReplicaDetail `json:"read_replica_details"`
+}
+
+// ReadReplicaDetail represents information about a read replica
+type ReadReplicaDetail struct {
+	InstanceID    string `json:"instance_id"`
+	InstanceClass string `json:"instance_class"`
+	Status        string `json:"status"`
+}
+
+// MetricsData represents CloudWatch metrics
+type MetricsData struct {
+	CPUUtilization       MetricValues `json:"cpu_utilization"`
+	WriterCPUUtilization MetricValues `json:"writer_cpu_utilization"`
+	ReaderCPUUtilization MetricValues `json:"reader_cpu_utilization"`
+	DatabaseConnections  MetricValues `json:"database_connections"`
+}
+
+// MetricValues represents metric statistics
+type MetricValues struct {
+	Average float64 `json:"average"`
+	Maximum float64 `json:"maximum"`
+}
+
+// AlarmState represents CloudWatch alarm information
+type AlarmState struct {
+	State   string `json:"state"`
+	Reason  string `json:"reason"`
+	Updated string `json:"updated"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Checker reads cluster membership, CloudWatch metrics and alarm states
+type Checker struct {
+	clients *awsClients.Clients
+	config  *config.MetricsCheckerConfig
+}
+
+// New creates a new metrics checker
+func New(clients *awsClients.Clients, config *config.MetricsCheckerConfig) *Checker {
+	return &Checker{
+		clients: clients,
+		config:  config,
+	}
+}
+
+// CheckClusterStatus retrieves DocumentDB cluster status and instance information
+func (mc *Checker) CheckClusterStatus(ctx context.Context) (ClusterStatus, error) {
+	input := &docdb.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(mc.config.ClusterIdentifier),
+	}
+
+	result, err := mc.clients.DocDB.DescribeDBClustersWithContext(ctx, input)
+	if err != nil {
+		return ClusterStatus{}, fmt.Errorf("failed to describe DB clusters: %w", err)
+	}
+
+	if len(result.DBClusters) == 0 {
+		return ClusterStatus{}, fmt.Errorf("cluster %s not found", mc.config.ClusterIdentifier)
+	}
+
+	cluster := result.DBClusters[0]
+	clusterMembers := cluster.DBClusterMembers
+
+	var readReplicas []ReadReplicaDetail
+	var writerCount, readerCount int
+
+	// Get detailed instance information
+	instancesInput := &docdb.DescribeDBInstancesInput{
+		Filters: []*docdb.Filter{
+			{
+				Name:   aws.String("db-cluster-id"),
+				Values: []*string{aws.String(mc.config.ClusterIdentifier)},
+			},
+		},
+	}
+
+	instancesResult, err := mc.clients.DocDB.DescribeDBInstancesWithContext(ctx, instancesInput)
+	if err != nil {
+		// If we can't get instance details, fall back to basic info
+		for _, member := range clusterMembers {
+			if member.IsClusterWriter != nil && *member.IsClusterWriter {
+				writerCount++
+			} else {
+				readerCount++
+				readReplicas = append(readReplicas, ReadReplicaDetail{
+					InstanceID:    aws.StringValue(member.DBInstanceIdentifier),
+					InstanceClass: "unknown",
+					Status:        "unknown",
+				})
+			}
+		}
+	} else {
+		// Create a map of instance details for quick lookup
+		instanceDetails := make(map[string]*docdb.DBInstance)
+		for _, instance := range instancesResult.DBInstances {
+			instanceDetails[aws.StringValue(instance.DBInstanceIdentifier)] = instance
+		}
+
+		for _, member := range clusterMembers {
+			if member.IsClusterWriter != nil && *member.IsClusterWriter {
+				writerCount++
+			} else {
+				readerCount++
+				instanceID := aws.StringValue(member.DBInstanceIdentifier)
+
+				// Get detailed info if available
+				instanceClass := "unknown"
+				status := "unknown"
+				if instance, exists := instanceDetails[instanceID]; exists {
+					instanceClass = aws.StringValue(instance.DBInstanceClass)
+					status = aws.StringValue(instance.DBInstanceStatus)
+				}
+
+				readReplicas = append(readReplicas, ReadReplicaDetail{
+					InstanceID:    instanceID,
+					InstanceClass: instanceClass,
+					Status:        status,
+				})
+			}
+		}
+	}
+
+	return ClusterStatus{
+		ClusterID:          mc.config.ClusterIdentifier,
+		Status:             aws.StringValue(cluster.Status),
+		TotalInstances:     len(clusterMembers),
+		WriterInstances:    writerCount,
+		ReadReplicas:       readerCount,
+		ReadReplicaDetails: readReplicas,
+	}, nil
+}
+
+// GetMetrics retrieves CloudWatch metrics for the cluster
+func (mc *Checker) GetMetrics(ctx context.Context, lookbackMinutes int) (MetricsData, error) {
+	endTime := time.Now()
+	startTime := endTime.Add(-time.Duration(lookbackMinutes) * time.Minute)
+
+	// Get CPU metrics
+	cpuMetrics, err := mc.getMetricStatistics(ctx, "CPUUtilization", "", startTime, endTime)
+	if err != nil {
+		return MetricsData{}, fmt.Errorf("failed to get CPU metrics: %w", err)
+	}
+
+	// Get CPU metrics per role
+	writerCPUMetrics, err := mc.getMetricStatistics(ctx, "CPUUtilization", "WRITER", startTime, endTime)
+	if err != nil {
+		return MetricsData{}, fmt.Errorf("failed to get writer CPU metrics: %w", err)
+	}
+	readerCPUMetrics, err := mc.getMetricStatistics(ctx, "CPUUtilization", "READER", startTime, endTime)
+	if err != nil {
+		return MetricsData{}, fmt.Errorf("failed to get reader CPU metrics: %w", err)
+	}
+
+	// Get connection metrics
+	connectionMetrics, err := mc.getMetricStatistics(ctx, "DatabaseConnections", "", startTime, endTime)
+	if err != nil {
+		return MetricsData{}, fmt.Errorf("failed to get connection metrics: %w", err)
+	}
+
+	return MetricsData{
+		CPUUtilization:       cpuMetrics,
+		WriterCPUUtilization: writerCPUMetrics,
+		ReaderCPUUtilization: readerCPUMetrics,
+		DatabaseConnections:  connectionMetrics,
+	}, nil
+}
+
+// getMetricStatistics retrieves statistics for a specific metric, optionally
+// restricted to the WRITER or READER role
+func (mc *Checker) getMetricStatistics(ctx context.Context, metricName, role string, startTime, endTime time.Time) (MetricValues, error) {
+	dimensions := []*cloudwatch.Dimension{
+		{
+			Name:  aws.String("DBClusterIdentifier"),
+			Value: aws.String(mc.config.ClusterIdentifier),
+		},
+	}
+	if role != "" {
+		dimensions = append(dimensions, &cloudwatch.Dimension{
+			Name:  aws.String("Role"),
+			Value: aws.String(role),
+		})
+	}
+
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/DocDB"),
+		MetricName: aws.String(metricName),
+		Dimensions: dimensions,
+		StartTime:  aws.Time(startTime),
+		EndTime:    aws.Time(endTime),
+		Period:     aws.Int64(300), // 5-minute periods
+		Statistics: []*string{aws.String("Average"), aws.String("Maximum")},
+	}
+
+	result, err := mc.clients.CloudWatch.GetMetricStatisticsWithContext(ctx, input)
+	if err != nil {
+		return MetricValues{}, err
+	}
+
+	if len(result.Datapoints) == 0 {
+		return MetricValues{Average: 0, Maximum: 0}, nil
+	}
+
+	var avgSum, maxValue float64
+	for _, datapoint := range result.Datapoints {
+		if datapoint.Average != nil {
+			avgSum += *datapoint.Average
+		}
+		if datapoint.Maximum != nil && *datapoint.Maximum > maxValue {
+			maxValue = *datapoint.Maximum
+		}
+	}
+
+	average := avgSum / float64(len(result.Datapoints))
+
+	return MetricValues{
+		Average: math.Round(average*100) / 100, // Round to 2 decimal places
+		Maximum: math.Round(maxValue*100) / 100,
+	}, nil
+}
+
+// CheckAlarmStates retrieves the state of relevant CloudWatch alarms
+func (mc *Checker) CheckAlarmStates(ctx context.Context) map[string]AlarmState {
+	alarmNames := []string{
+		fmt.Sprintf("DocDB-HighCPU-%s-%s", mc.config.ClusterIdentifier, mc.config.Environment),
+		fmt.Sprintf("DocDB-HighConnections-%s-%s", mc.config.ClusterIdentifier, mc.config.Environment),
+		fmt.Sprintf("DocDB-LowCPU-%s-%s", mc.config.ClusterIdentifier, mc.config.Environment),
+	}
+
+	alarmStates := make(map[string]AlarmState)
+
+	for _, alarmName := range alarmNames {
+		input := &cloudwatch.DescribeAlarmsInput{
+			AlarmNames: []*string{aws.String(alarmName)},
+		}
+
+		result, err := mc.clients.CloudWatch.DescribeAlarmsWithContext(ctx, input)
+		if err != nil {
+			alarmStates[alarmName] = AlarmState{
+				Error: err.Error(),
+			}
+			continue
+		}
+
+		if len(result.MetricAlarms) == 0 {
+			alarmStates[alarmName] = AlarmState{
+				Error: "alarm not found",
+			}
+			continue
+		}
+
+		alarm := result.MetricAlarms[0]
+		alarmStates[alarmName] = AlarmState{
+			State:   aws.StringValue(alarm.StateValue),
+			Reason:  aws.StringValue(alarm.StateReason),
+			Updated: alarm.StateUpdatedTimestamp.Format(time.RFC3339),
+		}
+	}
+
+	return alarmStates
+}
+
+// Check runs every check and returns the combined result
+func (mc *Checker) Check(ctx context.Context, lookbackMinutes int) (Result, error) {
+	clusterStatus, err := mc.CheckClusterStatus(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	metrics, err := mc.GetMetrics(ctx, lookbackMinutes)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		ClusterStatus: clusterStatus,
+		Metrics:       metrics,
+		AlarmStates:   mc.CheckAlarmStates(ctx),
+		Timestamp:     time.Now().Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,91 @@
+package checker
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	"docdb-auto-scaling/openmetrics"
+)
+
+// Exposition renders a metrics check as OpenMetrics text, for scraping
+// by Prometheus-compatible collectors
+type Exposition struct {
+	registry    *openmetrics.Registry
+	info        *openmetrics.Gauge
+	writers     *openmetrics.Gauge
+	readers     *openmetrics.Gauge
+	cpu         *openmetrics.Gauge
+	connections *openmetrics.Gauge
+	alarms      *openmetrics.Gauge
+	apiLatency  *openmetrics.Histogram
+}
+
+// NewExposition creates the metric families of a check
+func NewExposition() *Exposition {
+	registry := openmetrics.NewRegistry()
+	return &Exposition{
+		registry:    registry,
+		info:        registry.Gauge("docdb_cluster_info", "Cluster status, always 1"),
+		writers:     registry.Gauge("docdb_cluster_writers", "Writer instances"),
+		readers:     registry.Gauge("docdb_cluster_readers", "Reader instances by status"),
+		cpu:         registry.Gauge("docdb_cluster_cpu_percent", "CPU utilization by role and statistic over the lookback period"),
+		connections: registry.Gauge("docdb_cluster_connections", "Database connections by statistic over the lookback period"),
+		alarms:      registry.Gauge("docdb_cluster_alarm_state", "Scaling alarm state, 1 for the current state"),
+		apiLatency:  registry.Histogram("docdb_checker_aws_api_duration_seconds", "Latency of AWS API calls, including retries", openmetrics.DefaultLatencyBuckets),
+	}
+}
+
+// Instrument records the latency of every request sent through handlers
+func (m *Exposition) Instrument(handlers *request.Handlers) {
+	handlers.Complete.PushBack(func(r *request.Request) {
+		m.apiLatency.Observe(time.Since(r.Time).Seconds(), openmetrics.Labels{
+			"service":   r.ClientInfo.ServiceName,
+			"operation": r.Operation.Name,
+		})
+	})
+}
+
+// Render sets the gauges from result and returns the exposition text
+func (m *Exposition) Render(result Result) string {
+	cluster := result.ClusterStatus.ClusterID
+	labels := func(extra ...string) openmetrics.Labels {
+		l := openmetrics.Labels{"cluster": cluster}
+		for i := 0; i+1 < len(extra); i += 2 {
+			l[extra[i]] = extra[i+1]
+		}
+		return l
+	}
+
+	m.info.Set(1, labels("status", result.ClusterStatus.Status))
+	m.writers.Set(float64(result.ClusterStatus.WriterInstances), labels())
+
+	readersByStatus := make(map[string]int)
+	for _, replica := range result.ClusterStatus.ReadReplicaDetails {
+		readersByStatus[replica.Status]++
+	}
+	for status, count := range readersByStatus {
+		m.readers.Set(float64(count), labels("status", status))
+	}
+
+	for role, values := range map[string]MetricValues{
+		"cluster": result.Metrics.CPUUtilization,
+		"writer":  result.Metrics.WriterCPUUtilization,
+		"reader":  result.Metrics.ReaderCPUUtilization,
+	} {
+		m.cpu.Set(values.Average, labels("role", role, "stat", "average"))
+		m.cpu.Set(values.Maximum, labels("role", role, "stat", "maximum"))
+	}
+	m.connections.Set(result.Metrics.DatabaseConnections.Average, labels("stat", "average"))
+	m.connections.Set(result.Metrics.DatabaseConnections.Maximum, labels("stat", "maximum"))
+
+	for name, alarm := range result.AlarmStates {
+		state := alarm.State
+		if alarm.Error != "" {
+			state = "UNKNOWN"
+		}
+		m.alarms.Set(1, labels("alarm", name, "state", state))
+	}
+
+	return m.registry.String()
+}
@@ -17,6 +17,17 @@ type MetricsCheckerConfig struct {
 	Environment       string
 }
 
+// IdleDetectorConfig holds configuration for the idle-environment detector
+type IdleDetectorConfig struct {
+	IdleHours      int     // how long a cluster must have been idle
+	MaxConnections float64 // connections at or below this count as idle
+	MaxCPU         float64 // CPU percent at or below this counts as idle
+	StopClusters   bool    // call StopDBCluster after removing the readers
+	DryRun         bool    // report only, change nothing
+	EnvironmentTag string  // cluster tag holding the environment name
+	PriceTable     string  // optional JSON price table for the savings report
+}
+
 // LoadGeneratorConfigFromEnv loads load generator configuration from environment variables
 func LoadGeneratorConfigFromEnv() (*LoadGeneratorConfig, error) {
 	connectionString := os.Getenv("MONGODB_CONNECTION_STRING")
@@ -47,6 +58,28 @@ func MetricsCheckerConfigFromEnv() (*MetricsCheckerConfig, error) {
 	}, nil
 }
 
+// IdleDetectorConfigFromEnv loads idle detector configuration from environment variables
+func IdleDetectorConfigFromEnv() (*IdleDetectorConfig, error) {
+	cfg := &IdleDetectorConfig{
+		IdleHours:      GetEnvInt("IDLE_HOURS", 72),
+		MaxConnections: GetEnvFloat("IDLE_MAX_CONNECTIONS", 1),
+		MaxCPU:         GetEnvFloat("IDLE_MAX_CPU", 5),
+		StopClusters:   GetEnvBool("IDLE_STOP_CLUSTERS", false),
+		DryRun:         GetEnvBool("IDLE_DRY_RUN", false),
+		EnvironmentTag: GetEnvString("IDLE_ENVIRONMENT_TAG", "Environment"),
+		PriceTable:     GetEnvString("PRICE_TABLE", ""),
+	}
+
+	// CloudWatch returns at most 1440 hourly datapoints per request
+	if cfg.IdleHours < 1 || cfg.IdleHours > 1440 {
+		return nil, fmt.Errorf("IDLE_HOURS must be between 1 and 1440, got %d", cfg.IdleHours)
+	}
+	if cfg.MaxConnections < 0 || cfg.MaxCPU < 0 || cfg.MaxCPU > 100 {
+		return nil, fmt.Errorf("IDLE_MAX_CONNECTIONS must not be negative and IDLE_MAX_CPU must be in [0, 100]")
+	}
+	return cfg, nil
+}
+
 // GetEnvInt gets an integer environment variable with a default value
 func GetEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -64,3 +97,23 @@ func GetEnvString(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// GetEnvFloat gets a float environment variable with a default value
+func GetEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// GetEnvBool gets a boolean environment variable with a default value
+func GetEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -95,3 +95,20 @@ func TestGetEnvString(t *testing.T) {
 		t.Errorf("Expected 'custom', got '%s'", result)
 	}
 }
+
+func TestIdleDetectorConfigFromEnv(t *testing.T) {
+	os.Clearenv()
+
+	config, err := IdleDetectorConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.IdleHours != 72 || config.StopClusters || config.EnvironmentTag != "Environment" {
+		t.Errorf("Expected defaults of 72 hours, no stop and the Environment tag, got %+v", config)
+	}
+
+	os.Setenv("IDLE_HOURS", "0")
+	if _, err := IdleDetectorConfigFromEnv(); err == nil {
+		t.Error("Expected error for IDLE_HOURS=0")
+	}
+}
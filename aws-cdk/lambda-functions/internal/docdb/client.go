@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -14,6 +15,7 @@ import (
 type Client struct {
 	client           *mongo.Client
 	connectionString string
+	monitor          *event.CommandMonitor
 }
 
 // NewClient creates a new DocumentDB client
@@ -23,6 +25,12 @@ func NewClient(connectionString string) (*Client, error) {
 	}, nil
 }
 
+// SetCommandMonitor sets a monitor for the commands the client runs. It
+// takes effect on Connect.
+func (c *Client) SetCommandMonitor(monitor *event.CommandMonitor) {
+	c.monitor = monitor
+}
+
 // Connect establishes connection to DocumentDB
 func (c *Client) Connect(ctx context.Context) error {
 	// Configure TLS for DocumentDB
@@ -38,6 +46,9 @@ func (c *Client) Connect(ctx context.Context) error {
 		SetServerSelectionTimeout(30 * time.Second).
 		SetMaxPoolSize(50).
 		SetMinPoolSize(5)
+	if c.monitor != nil {
+		clientOptions.SetMonitor(c.monitor)
+	}
 
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
@@ -0,0 +1,390 @@
+// Package idle finds DocumentDB clusters of branch environments that nobody
+// has used for a while and shrinks them: their readers are removed, the
+// autoscaler is paused and, optionally, the cluster is stopped. It backs the
+// idle-detector Lambda and the docdbctl idle command.
+package idle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/docdb"
+	"github.com/aws/aws-sdk-go/service/docdb/docdbiface"
+
+	"docdb-auto-scaling/autoscaler"
+
+	awsClients "docdb-autoscaling-lambdas/internal/aws"
+	"docdb-autoscaling-lambdas/internal/config"
+)
+
+// Cluster tags used by the detector
+const (
+	// IdleSinceTag records when the detector shrank the cluster
+	IdleSinceTag = "autoscaler:idle-since"
+	// ExemptTag set to "true" keeps a cluster out of the detector's hands
+	ExemptTag = "autoscaler:idle-exempt"
+)
+
+// pauseHorizon is how long autoscaling stays paused on a shrunk cluster;
+// Restart resumes it
+const pauseHorizon = 365 * 24 * time.Hour
+
+// hoursPerMonth converts hourly savings to monthly ones
+const hoursPerMonth = 730
+
+// ClusterReport is what the detector found and did for one cluster
+type ClusterReport struct {
+	ClusterIdentifier string   `json:"cluster_identifier"`
+	Environment       string   `json:"environment,omitempty"`
+	Status            string   `json:"status"`
+	MaxConnections    float64  `json:"max_connections"`
+	MaxCPU            float64  `json:"max_cpu"`
+	Idle              bool     `json:"idle"`
+	Reason            string   `json:"reason"`
+	Readers           []string `json:"readers,omitempty"` // readers removed, or to remove in a dry run
+	Stopped           bool     `json:"stopped"`
+	HourlySavings     float64  `json:"hourly_savings"`
+	Error             string   `json:"error,omitempty"`
+
+	readerCost, writerCost float64
+	idleSince              string // IdleSinceTag of a cluster shrunk before
+	paused                 bool   // the cluster carries the autoscaler's PauseTag
+}
+
+// Report is the outcome of one detector run
+type Report struct {
+	GeneratedAt    time.Time       `json:"generated_at"`
+	IdleHours      int             `json:"idle_hours"`
+	DryRun         bool            `json:"dry_run"`
+	StopClusters   bool            `json:"stop_clusters"`
+	Currency       string          `json:"currency"`
+	Clusters       []ClusterReport `json:"clusters"`
+	HourlySavings  float64         `json:"hourly_savings"`
+	MonthlySavings float64         `json:"monthly_savings"`
+	Unpriced       []string        `json:"unpriced,omitempty"` // instance classes left out of the savings
+}
+
+// HasErrors reports whether shrinking any cluster failed
+func (r *Report) HasErrors() bool {
+	for _, c := range r.Clusters {
+		if c.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Detector finds and shrinks idle clusters
+type Detector struct {
+	docdb      docdbiface.DocDBAPI
+	cloudwatch cloudwatchiface.CloudWatchAPI
+	config     *config.IdleDetectorConfig
+	prices     *autoscaler.PriceTable
+	unpriced   map[string]bool
+}
+
+// New creates a detector. Savings are priced from cfg.PriceTable over the
+// built-in price snapshot.
+func New(clients *awsClients.Clients, cfg *config.IdleDetectorConfig) (*Detector, error) {
+	prices, err := autoscaler.LoadPrices(cfg.PriceTable, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Detector{
+		docdb:      clients.DocDB,
+		cloudwatch: clients.CloudWatch,
+		config:     cfg,
+		prices:     prices,
+		unpriced:   make(map[string]bool),
+	}, nil
+}
+
+// Run inspects every DocumentDB cluster in the region and shrinks the idle
+// ones, unless the configuration asks for a dry run
+func (d *Detector) Run(ctx context.Context, now time.Time) (*Report, error) {
+	report := &Report{
+		GeneratedAt:  now,
+		IdleHours:    d.config.IdleHours,
+		DryRun:       d.config.DryRun,
+		StopClusters: d.config.StopClusters,
+		Currency:     d.prices.Currency,
+	}
+	d.unpriced = make(map[string]bool)
+
+	var clusters []*docdb.DBCluster
+	err := d.docdb.DescribeDBClustersPagesWithContext(ctx, &docdb.DescribeDBClustersInput{
+		Filters: []*docdb.Filter{{Name: aws.String("engine"), Values: []*string{aws.String("docdb")}}},
+	}, func(page *docdb.DescribeDBClustersOutput, lastPage bool) bool {
+		clusters = append(clusters, page.DBClusters...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	for _, cluster := range clusters {
+		c := d.inspect(ctx, cluster, now)
+		if c.Idle && !d.config.DryRun {
+			if err := d.shrink(ctx, cluster, &c, now); err != nil {
+				log.Printf("Error shrinking idle cluster %s: %v", c.ClusterIdentifier, err)
+				c.Error = err.Error()
+			}
+		}
+		if c.Idle {
+			c.HourlySavings = c.readerCost
+			if d.config.StopClusters {
+				c.HourlySavings += c.writerCost
+			}
+		}
+		report.HourlySavings += c.HourlySavings
+		report.Clusters = append(report.Clusters, c)
+	}
+
+	report.MonthlySavings = report.HourlySavings * hoursPerMonth
+	for class := range d.unpriced {
+		report.Unpriced = append(report.Unpriced, class)
+	}
+	sort.Strings(report.Unpriced)
+	return report, nil
+}
+
+// inspect decides whether a cluster is an idle branch environment
+func (d *Detector) inspect(ctx context.Context, cluster *docdb.DBCluster, now time.Time) ClusterReport {
+	c := ClusterReport{
+		ClusterIdentifier: aws.StringValue(cluster.DBClusterIdentifier),
+		Status:            aws.StringValue(cluster.Status),
+	}
+
+	tags, err := d.tags(ctx, aws.StringValue(cluster.DBClusterArn))
+	if err != nil {
+		c.Reason, c.Error = "tags unavailable", err.Error()
+		return c
+	}
+	c.Environment = tags[d.config.EnvironmentTag]
+	c.idleSince, c.paused = tags[IdleSinceTag], tags[autoscaler.PauseTag] != ""
+
+	switch {
+	case c.Environment == "":
+		c.Reason = fmt.Sprintf("no %s tag", d.config.EnvironmentTag)
+		return c
+	case autoscaler.ProfileFor(c.Environment) != "ephemeral":
+		c.Reason = fmt.Sprintf("%s is not a branch environment", c.Environment)
+		return c
+	case tags[ExemptTag] == "true":
+		c.Reason = "exempt"
+		return c
+	case c.Status == "stopped":
+		c.Stopped = true
+		c.Reason = "already stopped"
+		return c
+	case c.Status != "available":
+		c.Reason = fmt.Sprintf("cluster is %s", c.Status)
+		return c
+	case cluster.ClusterCreateTime != nil && cluster.ClusterCreateTime.After(now.Add(-d.idlePeriod())):
+		c.Reason = fmt.Sprintf("created less than %d hours ago", d.config.IdleHours)
+		return c
+	}
+
+	if err := d.priceMembers(ctx, cluster, &c); err != nil {
+		c.Reason, c.Error = "instances unavailable", err.Error()
+		return c
+	}
+
+	connections, connectionPoints, err := d.maximum(ctx, c.ClusterIdentifier, "DatabaseConnections", now)
+	if err != nil {
+		c.Reason, c.Error = "metrics unavailable", err.Error()
+		return c
+	}
+	cpu, cpuPoints, err := d.maximum(ctx, c.ClusterIdentifier, "CPUUtilization", now)
+	if err != nil {
+		c.Reason, c.Error = "metrics unavailable", err.Error()
+		return c
+	}
+	c.MaxConnections, c.MaxCPU = connections, cpu
+	// A cluster shrunk before has few datapoints if it was stopped, e.g.
+	// because DocumentDB started it again after seven days
+	shrunk := c.idleSince != "" && c.paused
+	c.Idle, c.Reason = classify(d.config, connections, cpu, min(connectionPoints, cpuPoints), shrunk)
+	if shrunk {
+		c.Reason = fmt.Sprintf("shrunk since %s, %s", c.idleSince, c.Reason)
+	}
+	return c
+}
+
+// classify applies the idle thresholds to the maxima over the idle period.
+// A cluster that was already shrunk needs no datapoints to stay idle.
+func classify(cfg *config.IdleDetectorConfig, maxConnections, maxCPU float64, datapoints int, shrunk bool) (bool, string) {
+	// Missing hours are tolerated, a missing period is not: no data is not proof of no use
+	if datapoints < cfg.IdleHours/2 && !shrunk {
+		return false, fmt.Sprintf("only %d of %d hourly datapoints", datapoints, cfg.IdleHours)
+	}
+	if maxConnections > cfg.MaxConnections {
+		return false, fmt.Sprintf("%.0f connections in the last %d hours", maxConnections, cfg.IdleHours)
+	}
+	if maxCPU > cfg.MaxCPU {
+		return false, fmt.Sprintf("%.1f%% CPU in the last %d hours", maxCPU, cfg.IdleHours)
+	}
+	return true, fmt.Sprintf("at most %.0f connections and %.1f%% CPU in the last %d hours", maxConnections, maxCPU, cfg.IdleHours)
+}
+
+// shrink pauses the autoscaler, removes the readers and optionally stops the
+// cluster. A cluster shrunk before keeps its IdleSinceTag, and is only paused
+// again if readers came back.
+func (d *Detector) shrink(ctx context.Context, cluster *docdb.DBCluster, c *ClusterReport, now time.Time) error {
+	log.Printf("Shrinking idle cluster %s (%s): %s", c.ClusterIdentifier, c.Environment, c.Reason)
+
+	var tags []*docdb.Tag
+	if !c.paused || len(c.Readers) > 0 {
+		tags = append(tags, &docdb.Tag{Key: aws.String(autoscaler.PauseTag), Value: aws.String(now.Add(pauseHorizon).UTC().Format(time.RFC3339))})
+	}
+	if c.idleSince == "" {
+		tags = append(tags, &docdb.Tag{Key: aws.String(IdleSinceTag), Value: aws.String(now.UTC().Format(time.RFC3339))})
+	}
+
+	// Pause first, so that the autoscaler doesn't replace the readers
+	if len(tags) > 0 {
+		_, err := d.docdb.AddTagsToResourceWithContext(ctx, &docdb.AddTagsToResourceInput{
+			ResourceName: cluster.DBClusterArn,
+			Tags:         tags,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to pause autoscaling: %w", err)
+		}
+	}
+
+	for _, reader := range c.Readers {
+		log.Printf("Deleting reader %s of idle cluster %s", reader, c.ClusterIdentifier)
+		_, err := d.docdb.DeleteDBInstanceWithContext(ctx, &docdb.DeleteDBInstanceInput{
+			DBInstanceIdentifier: aws.String(reader),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete reader %s: %w", reader, err)
+		}
+	}
+
+	if d.config.StopClusters {
+		log.Printf("Stopping idle cluster %s", c.ClusterIdentifier)
+		_, err := d.docdb.StopDBClusterWithContext(ctx, &docdb.StopDBClusterInput{
+			DBClusterIdentifier: cluster.DBClusterIdentifier,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to stop cluster: %w", err)
+		}
+		c.Stopped = true
+	}
+	return nil
+}
+
+// Restart undoes shrink: it starts a stopped cluster and resumes
+// autoscaling, which brings readers back as load returns
+func (d *Detector) Restart(ctx context.Context, clusterIdentifier string) error {
+	result, err := d.docdb.DescribeDBClustersWithContext(ctx, &docdb.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(clusterIdentifier),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe cluster: %w", err)
+	}
+	if len(result.DBClusters) == 0 {
+		return fmt.Errorf("cluster %s not found", clusterIdentifier)
+	}
+	cluster := result.DBClusters[0]
+
+	if aws.StringValue(cluster.Status) == "stopped" {
+		log.Printf("Starting cluster %s", clusterIdentifier)
+		_, err := d.docdb.StartDBClusterWithContext(ctx, &docdb.StartDBClusterInput{
+			DBClusterIdentifier: aws.String(clusterIdentifier),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start cluster: %w", err)
+		}
+	}
+
+	_, err = d.docdb.RemoveTagsFromResourceWithContext(ctx, &docdb.RemoveTagsFromResourceInput{
+		ResourceName: cluster.DBClusterArn,
+		TagKeys:      []*string{aws.String(autoscaler.PauseTag), aws.String(IdleSinceTag)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resume autoscaling: %w", err)
+	}
+	log.Printf("Restarted cluster %s", clusterIdentifier)
+	return nil
+}
+
+func (d *Detector) idlePeriod() time.Duration {
+	return time.Duration(d.config.IdleHours) * time.Hour
+}
+
+func (d *Detector) tags(ctx context.Context, arn string) (map[string]string, error) {
+	result, err := d.docdb.ListTagsForResourceWithContext(ctx, &docdb.ListTagsForResourceInput{
+		ResourceName: aws.String(arn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster tags: %w", err)
+	}
+	tags := make(map[string]string, len(result.TagList))
+	for _, tag := range result.TagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+// priceMembers lists the cluster's readers and prices them and the writer
+func (d *Detector) priceMembers(ctx context.Context, cluster *docdb.DBCluster, c *ClusterReport) error {
+	for _, member := range cluster.DBClusterMembers {
+		result, err := d.docdb.DescribeDBInstancesWithContext(ctx, &docdb.DescribeDBInstancesInput{
+			DBInstanceIdentifier: member.DBInstanceIdentifier,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe instance %s: %w", aws.StringValue(member.DBInstanceIdentifier), err)
+		}
+		if len(result.DBInstances) == 0 {
+			continue
+		}
+		instance := result.DBInstances[0]
+
+		class := aws.StringValue(instance.DBInstanceClass)
+		price, ok := d.prices.Price(class)
+		if !ok {
+			d.unpriced[class] = true
+		}
+		if aws.BoolValue(member.IsClusterWriter) {
+			c.writerCost += price
+		} else if aws.StringValue(instance.DBInstanceStatus) != "deleting" {
+			c.Readers = append(c.Readers, aws.StringValue(instance.DBInstanceIdentifier))
+			c.readerCost += price
+		}
+	}
+	return nil
+}
+
+// maximum returns the highest hourly maximum of a cluster metric over the
+// idle period, and the number of hours with data
+func (d *Detector) maximum(ctx context.Context, clusterIdentifier, metricName string, now time.Time) (float64, int, error) {
+	result, err := d.cloudwatch.GetMetricStatisticsWithContext(ctx, &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/DocDB"),
+		MetricName: aws.String(metricName),
+		Dimensions: []*cloudwatch.Dimension{{
+			Name:  aws.String("DBClusterIdentifier"),
+			Value: aws.String(clusterIdentifier),
+		}},
+		StartTime:  aws.Time(now.Add(-d.idlePeriod())),
+		EndTime:    aws.Time(now),
+		Period:     aws.Int64(3600),
+		Statistics: []*string{aws.String("Maximum")},
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get %s: %w", metricName, err)
+	}
+
+	var maximum float64
+	for _, datapoint := range result.Datapoints {
+		maximum = max(maximum, aws.Float64Value(datapoint.Maximum))
+	}
+	return maximum, len(result.Datapoints), nil
+}
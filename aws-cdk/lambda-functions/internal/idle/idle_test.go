@@ -0,0 +1,202 @@
+package idle
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/docdb"
+	"github.com/aws/aws-sdk-go/service/docdb/docdbiface"
+
+	"docdb-auto-scaling/autoscaler"
+
+	"docdb-autoscaling-lambdas/internal/config"
+)
+
+func TestClassify(t *testing.T) {
+	cfg := &config.IdleDetectorConfig{IdleHours: 72, MaxConnections: 1, MaxCPU: 5}
+
+	tests := []struct {
+		connections, cpu float64
+		datapoints       int
+		idle             bool
+		reason           string
+	}{
+		{0, 2.5, 72, true, "at most 0 connections"},
+		{1, 5, 40, true, "at most 1 connections"},
+		{12, 2.5, 72, false, "12 connections"},
+		{0, 35, 72, false, "35.0% CPU"},
+		{0, 0, 10, false, "only 10 of 72 hourly datapoints"},
+	}
+	for _, tt := range tests {
+		idle, reason := classify(cfg, tt.connections, tt.cpu, tt.datapoints, false)
+		if idle != tt.idle || !strings.Contains(reason, tt.reason) {
+			t.Errorf("classify(%g, %g, %d): expected %v (%s), got %v (%s)", tt.connections, tt.cpu, tt.datapoints, tt.idle, tt.reason, idle, reason)
+		}
+	}
+}
+
+func TestClassifyShrunk(t *testing.T) {
+	cfg := &config.IdleDetectorConfig{IdleHours: 72, MaxConnections: 1, MaxCPU: 5}
+	if idle, reason := classify(cfg, 0, 0, 0, true); !idle {
+		t.Errorf("Expected a shrunk cluster without datapoints to stay idle, got %s", reason)
+	}
+	if idle, _ := classify(cfg, 12, 0, 3, true); idle {
+		t.Errorf("Expected a shrunk cluster with connections not to be idle")
+	}
+}
+
+// fakeDocDB serves one cluster's tags and instances and records changes
+type fakeDocDB struct {
+	docdbiface.DocDBAPI
+	tags    map[string]string
+	added   []string
+	deleted []string
+	stopped bool
+}
+
+func (f *fakeDocDB) ListTagsForResourceWithContext(ctx aws.Context, input *docdb.ListTagsForResourceInput, opts ...request.Option) (*docdb.ListTagsForResourceOutput, error) {
+	output := &docdb.ListTagsForResourceOutput{}
+	for key, value := range f.tags {
+		output.TagList = append(output.TagList, &docdb.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return output, nil
+}
+
+func (f *fakeDocDB) DescribeDBInstancesWithContext(ctx aws.Context, input *docdb.DescribeDBInstancesInput, opts ...request.Option) (*docdb.DescribeDBInstancesOutput, error) {
+	return &docdb.DescribeDBInstancesOutput{DBInstances: []*docdb.DBInstance{{
+		DBInstanceIdentifier: input.DBInstanceIdentifier,
+		DBInstanceClass:      aws.String("db.r6g.large"),
+		DBInstanceStatus:     aws.String("available"),
+	}}}, nil
+}
+
+func (f *fakeDocDB) AddTagsToResourceWithContext(ctx aws.Context, input *docdb.AddTagsToResourceInput, opts ...request.Option) (*docdb.AddTagsToResourceOutput, error) {
+	for _, tag := range input.Tags {
+		f.added = append(f.added, aws.StringValue(tag.Key))
+	}
+	return &docdb.AddTagsToResourceOutput{}, nil
+}
+
+func (f *fakeDocDB) DeleteDBInstanceWithContext(ctx aws.Context, input *docdb.DeleteDBInstanceInput, opts ...request.Option) (*docdb.DeleteDBInstanceOutput, error) {
+	f.deleted = append(f.deleted, aws.StringValue(input.DBInstanceIdentifier))
+	return &docdb.DeleteDBInstanceOutput{}, nil
+}
+
+func (f *fakeDocDB) StopDBClusterWithContext(ctx aws.Context, input *docdb.StopDBClusterInput, opts ...request.Option) (*docdb.StopDBClusterOutput, error) {
+	f.stopped = true
+	return &docdb.StopDBClusterOutput{}, nil
+}
+
+// fakeCloudWatch returns the same hourly maximum for every metric
+type fakeCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	datapoints int
+	maximum    float64
+}
+
+func (f *fakeCloudWatch) GetMetricStatisticsWithContext(ctx aws.Context, input *cloudwatch.GetMetricStatisticsInput, opts ...request.Option) (*cloudwatch.GetMetricStatisticsOutput, error) {
+	output := &cloudwatch.GetMetricStatisticsOutput{}
+	for i := 0; i < f.datapoints; i++ {
+		output.Datapoints = append(output.Datapoints, &cloudwatch.Datapoint{Maximum: aws.Float64(f.maximum)})
+	}
+	return output, nil
+}
+
+func newTestDetector(t *testing.T, client *fakeDocDB, metrics *fakeCloudWatch) *Detector {
+	prices, err := autoscaler.LoadPrices("", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return &Detector{
+		docdb:      client,
+		cloudwatch: metrics,
+		config:     &config.IdleDetectorConfig{IdleHours: 72, MaxConnections: 1, MaxCPU: 5, StopClusters: true, EnvironmentTag: "Environment"},
+		prices:     prices,
+		unpriced:   make(map[string]bool),
+	}
+}
+
+func testCluster(status string, created time.Time) *docdb.DBCluster {
+	return &docdb.DBCluster{
+		DBClusterIdentifier: aws.String("feature-x"),
+		DBClusterArn:        aws.String("arn:aws:rds:us-east-1:123456789012:cluster:feature-x"),
+		Status:              aws.String(status),
+		ClusterCreateTime:   aws.Time(created),
+		DBClusterMembers: []*docdb.DBClusterMember{
+			{DBInstanceIdentifier: aws.String("feature-x-writer"), IsClusterWriter: aws.Bool(true)},
+			{DBInstanceIdentifier: aws.String("feature-x-reader"), IsClusterWriter: aws.Bool(false)},
+		},
+	}
+}
+
+func TestInspect(t *testing.T) {
+	now := time.Date(2026, 3, 10, 2, 0, 0, 0, time.UTC)
+	old := now.Add(-30 * 24 * time.Hour)
+	shrunk := map[string]string{
+		"Environment":       "feature-x",
+		IdleSinceTag:        "2026-02-01T02:00:00Z",
+		autoscaler.PauseTag: "2027-02-01T02:00:00Z",
+	}
+
+	tests := []struct {
+		name       string
+		tags       map[string]string
+		status     string
+		created    time.Time
+		datapoints int
+		maximum    float64
+		idle       bool
+		reason     string
+	}{
+		{"no environment", map[string]string{}, "available", old, 72, 0, false, "no Environment tag"},
+		{"prod", map[string]string{"Environment": "prod"}, "available", old, 72, 0, false, "prod is not a branch environment"},
+		{"staging", map[string]string{"Environment": "staging"}, "available", old, 72, 0, false, "staging is not a branch environment"},
+		{"exempt", map[string]string{"Environment": "feature-x", ExemptTag: "true"}, "available", old, 72, 0, false, "exempt"},
+		{"modifying", map[string]string{"Environment": "feature-x"}, "modifying", old, 72, 0, false, "cluster is modifying"},
+		{"stopped", map[string]string{"Environment": "feature-x"}, "stopped", old, 0, 0, false, "already stopped"},
+		{"new", map[string]string{"Environment": "feature-x"}, "available", now.Add(-time.Hour), 72, 0, false, "created less than 72 hours ago"},
+		{"idle", map[string]string{"Environment": "feature-x"}, "available", old, 72, 0, true, "at most 0 connections"},
+		{"busy", map[string]string{"Environment": "feature-x"}, "available", old, 72, 30, false, "30 connections"},
+		{"no data", map[string]string{"Environment": "feature-x"}, "available", old, 0, 0, false, "only 0 of 72 hourly datapoints"},
+		{"restarted by DocumentDB", shrunk, "available", old, 0, 0, true, "shrunk since 2026-02-01T02:00:00Z"},
+	}
+	for _, tt := range tests {
+		d := newTestDetector(t, &fakeDocDB{tags: tt.tags}, &fakeCloudWatch{datapoints: tt.datapoints, maximum: tt.maximum})
+		c := d.inspect(context.Background(), testCluster(tt.status, tt.created), now)
+		if c.Idle != tt.idle || !strings.Contains(c.Reason, tt.reason) {
+			t.Errorf("%s: expected %v (%s), got %v (%s)", tt.name, tt.idle, tt.reason, c.Idle, c.Reason)
+		}
+	}
+}
+
+func TestShrinkAgain(t *testing.T) {
+	now := time.Date(2026, 3, 10, 2, 0, 0, 0, time.UTC)
+	client := &fakeDocDB{}
+	d := newTestDetector(t, client, &fakeCloudWatch{})
+	cluster := testCluster("available", now.Add(-30*24*time.Hour))
+
+	// A cluster DocumentDB restarted keeps its tags and has no readers left
+	c := ClusterReport{ClusterIdentifier: "feature-x", idleSince: "2026-02-01T02:00:00Z", paused: true}
+	if err := d.shrink(context.Background(), cluster, &c, now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(client.added) != 0 || len(client.deleted) != 0 || !client.stopped {
+		t.Errorf("Expected only a stop, got tags %v and deletes %v", client.added, client.deleted)
+	}
+
+	// Readers that came back are removed and the pause renewed, but the
+	// cluster stays idle since the first shrink
+	c.Readers = []string{"feature-x-reader"}
+	if err := d.shrink(context.Background(), cluster, &c, now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(client.added) != 1 || client.added[0] != autoscaler.PauseTag || len(client.deleted) != 1 {
+		t.Errorf("Expected the pause renewed and one delete, got tags %v and deletes %v", client.added, client.deleted)
+	}
+}
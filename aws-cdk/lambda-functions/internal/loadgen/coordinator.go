@@ -0,0 +1,253 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"docdb-autoscaling-lambdas/internal/docdb"
+)
+
+// InvocationResult summarizes one copy of a fanned-out run
+type InvocationResult struct {
+	Invocation      int     `json:"invocation"`
+	FirstThread     int     `json:"first_thread"`
+	TotalOperations int64   `json:"total_operations"`
+	ErrorRate       float64 `json:"error_rate"`
+	Error           string  `json:"error,omitempty"` // the invocation itself failed
+}
+
+// Coordinate fans a load test out to request.Invocations copies run by
+// invoker, each with a slice of the rate and seed and its own threads, all
+// starting at the same time. It merges their results into one, as if a
+// single generator had run every thread, and tears the test data down once
+// every copy has finished. The copies stop in time for the coordinator's own
+// deadline; if they stopped early, the result has a checkpoint that
+// continues every copy.
+func Coordinate(ctx context.Context, client *docdb.Client, invoker Invoker, request Request) Result {
+	startAt := time.Now().Add(time.Duration(request.StartDelaySeconds) * time.Second)
+	requests := split(request, startAt)
+	if stop, ok := request.stopTime(ctx); ok {
+		// The copies' results still have to reach the coordinator
+		stop = stop.Add(-deadlineMargin)
+		for i := range requests {
+			requests[i].StopAt = &stop
+		}
+	}
+	log.Printf("Fanning out to %d invocations of %d threads, starting at %s",
+		len(requests), request.NumThreads, startAt.Format(time.RFC3339))
+
+	results := make([]Result, len(requests))
+	errs := make([]error, len(requests))
+	var wg sync.WaitGroup
+	for i, r := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = invoker.Invoke(ctx, r)
+			if errs[i] != nil {
+				log.Printf("Warning: invocation %d failed: %v", i, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := merge(request, requests, results, errs)
+	if result.Checkpoint != "" {
+		result.Message += fmt.Sprintf("; stopped for the deadline with %.0f seconds left, continue from the checkpoint", result.RemainingSeconds)
+		return result
+	}
+
+	// Every copy's workers, to find the test data they wrote
+	workers := make([]*worker, request.Invocations*request.NumThreads)
+	for i := range workers {
+		workers[i] = newWorker(request.FirstThread+i, client, request)
+	}
+	result.Teardown = teardown(ctx, request, workers)
+	return result
+}
+
+// split divides a request among its invocations. Rates and seed documents
+// are shared out; threads aren't, so each copy runs request.NumThreads.
+// Copies keep their test data for the coordinator to tear down, and continue
+// from their own part of a checkpoint.
+func split(request Request, startAt time.Time) []Request {
+	from, _ := parseCheckpoint(request.Checkpoint) // validated
+	n := request.Invocations
+	requests := make([]Request, n)
+	for i := range requests {
+		r := request
+		r.Invocations = 0
+		r.StartDelaySeconds = 0
+		r.StartAt = &startAt
+		r.FirstThread = request.FirstThread + i*request.NumThreads
+		r.IncludeHistograms = true
+		r.Teardown = TeardownKeep
+		r.Checkpoint = ""
+		if from != nil {
+			r.Checkpoint = from.Invocations[i]
+		}
+		r.TargetRate = request.TargetRate / float64(n)
+		if request.Profile.byRate() {
+			r.Profile = request.Profile.scaled(1 / float64(n))
+		}
+		if request.Seed != nil {
+			seed := *request.Seed
+			seed.Documents = request.Seed.share(i, n)
+			r.Seed = &seed
+		}
+		requests[i] = r
+	}
+	return requests
+}
+
+// merge combines the results of the invocations. Latency percentiles come
+// from the merged histograms, not from averaging the copies' percentiles.
+func merge(request Request, requests []Request, results []Result, errs []error) Result {
+	result := Result{TestParameters: request, ThreadResults: []ThreadResult{}}
+	histograms := make(map[string]*Histogram)
+	var failures [][]ErrorResult
+	servers := make(map[[2]string]int64)
+
+	for i, r := range results {
+		invocation := InvocationResult{
+			Invocation:      i,
+			FirstThread:     requests[i].FirstThread,
+			TotalOperations: r.TotalOperations,
+			ErrorRate:       r.ErrorRate,
+		}
+		if errs[i] != nil {
+			invocation.Error = truncate(errs[i].Error())
+		}
+		result.Invocations = append(result.Invocations, invocation)
+
+		result.ThreadResults = append(result.ThreadResults, r.ThreadResults...)
+		result.Rate = mergeRates(result.Rate, r.Rate)
+		result.Phases = mergePhases(result.Phases, r.Phases)
+		result.LatencySeries = mergeSeries(result.LatencySeries, r.LatencySeries)
+		result.Seed = mergeSeeds(result.Seed, r.Seed)
+		mergeHistograms(histograms, r.Histograms)
+		failures = append(failures, r.Errors)
+		for _, server := range r.Servers {
+			servers[[2]string{server.Operation, server.Address}] += server.Commands
+		}
+	}
+
+	result.Latency = summarize(histograms)
+	if request.IncludeHistograms {
+		result.Histograms = histograms
+	}
+	for i := range result.LatencySeries {
+		result.LatencySeries[i].Operations = summarize(result.LatencySeries[i].Histograms)
+		if !request.IncludeHistograms {
+			result.LatencySeries[i].Histograms = nil
+		}
+	}
+	result.Errors = combineErrors(failures...)
+	for key, commands := range servers {
+		result.Servers = append(result.Servers, ServerResult{Operation: key[0], Address: key[1], Commands: commands})
+	}
+	sortServers(result.Servers)
+
+	result.Checkpoint, result.RemainingSeconds = mergeCheckpoints(results)
+	result.total()
+	return result
+}
+
+// mergeCheckpoints combines the checkpoints of copies that stopped for the
+// deadline. A copy that finished or failed continues from where the others
+// stopped, without its workers' state.
+func mergeCheckpoints(results []Result) (string, float64) {
+	var elapsed, remaining float64
+	for _, r := range results {
+		if c, _ := parseCheckpoint(r.Checkpoint); c != nil {
+			elapsed = math.Max(elapsed, c.Elapsed)
+			remaining = math.Max(remaining, r.RemainingSeconds)
+		}
+	}
+	if remaining == 0 {
+		return "", 0
+	}
+
+	merged := checkpoint{Elapsed: elapsed, Invocations: make([]string, len(results))}
+	for i, r := range results {
+		merged.Invocations[i] = r.Checkpoint
+		if r.Checkpoint == "" {
+			merged.Invocations[i] = checkpoint{Elapsed: elapsed}.token()
+		}
+	}
+	return merged.token(), remaining
+}
+
+// mergeRates adds up the rates of two invocations
+func mergeRates(merged, rate *RateResult) *RateResult {
+	if rate == nil {
+		return merged
+	}
+	if merged == nil {
+		copied := *rate
+		return &copied
+	}
+	merged.RequestedRate += rate.RequestedRate
+	merged.AchievedRate += rate.AchievedRate
+	merged.Scheduled += rate.Scheduled
+	merged.Missed += rate.Missed
+	merged.Late += rate.Late
+	merged.MaxLagMs = math.Max(merged.MaxLagMs, rate.MaxLagMs)
+	return merged
+}
+
+// mergePhases adds up the operations and rates of each phase
+func mergePhases(merged, phases []PhaseResult) []PhaseResult {
+	for i, phase := range phases {
+		if i == len(merged) {
+			merged = append(merged, PhaseResult{Shape: phase.Shape, StartSeconds: phase.StartSeconds, EndSeconds: phase.EndSeconds})
+		}
+		merged[i].Operations += phase.Operations
+		merged[i].RequestedRate += phase.RequestedRate
+		merged[i].AchievedRate += phase.AchievedRate
+	}
+	return merged
+}
+
+// mergeSeries merges the histograms and servers of each interval. The
+// copies start together, so their intervals line up.
+func mergeSeries(merged, intervals []LatencyInterval) []LatencyInterval {
+	for i, in := range intervals {
+		if i == len(merged) {
+			merged = append(merged, LatencyInterval{
+				Start:        in.Start,
+				StartSeconds: in.StartSeconds,
+				Histograms:   make(map[string]*Histogram),
+			})
+		}
+		mergeHistograms(merged[i].Histograms, in.Histograms)
+		for address, commands := range in.Servers {
+			if merged[i].Servers == nil {
+				merged[i].Servers = make(map[string]int64)
+			}
+			merged[i].Servers[address] += commands
+		}
+	}
+	return merged
+}
+
+// mergeSeeds adds up the documents of two invocations' seeds, which ran
+// concurrently
+func mergeSeeds(merged, seed *SeedResult) *SeedResult {
+	if seed == nil {
+		return merged
+	}
+	if merged == nil {
+		merged = &SeedResult{Indexes: seed.Indexes}
+	}
+	merged.Documents += seed.Documents
+	merged.Seconds = math.Max(merged.Seconds, seed.Seconds)
+	if merged.Error == "" {
+		merged.Error = seed.Error
+	}
+	return merged
+}
@@ -0,0 +1,83 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeInvoker stands in for the load generator, reporting each copy's slice
+// of a known latency distribution
+type fakeInvoker struct {
+	mu       sync.Mutex
+	requests []Request
+}
+
+func (f *fakeInvoker) Invoke(ctx context.Context, request Request) (Result, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	f.mu.Unlock()
+	if request.FirstThread == 8 {
+		return Result{}, errors.New("function timed out")
+	}
+
+	// The copy of thread 0 is fast, the others slow
+	latency := 3 * time.Millisecond
+	if request.FirstThread == 0 {
+		latency = time.Millisecond
+	}
+	h := &Histogram{}
+	for i := 0; i < 100; i++ {
+		h.Record(latency)
+	}
+	result := Result{
+		ThreadResults: []ThreadResult{{ThreadID: request.FirstThread, OperationsCompleted: 100, Errors: 1, Error: "1 operations failed"}},
+		Rate:          &RateResult{RequestedRate: request.TargetRate, AchievedRate: request.TargetRate, Scheduled: 100},
+		Errors:        []ErrorResult{{Operation: OpFind, Class: ClassTimeout, Count: 1}},
+		Histograms:    map[string]*Histogram{OpFind: h},
+		Seed:          &SeedResult{Documents: request.Seed.Documents},
+	}
+	result.total()
+	return result, nil
+}
+
+func TestCoordinate(t *testing.T) {
+	request := Request{Invocations: 3, NumThreads: 4, TargetRate: 300, Seed: &Seed{Documents: 10}}
+	request.SetDefaults()
+	invoker := &fakeInvoker{}
+	result := Coordinate(context.Background(), nil, invoker, request)
+
+	if len(invoker.requests) != 3 {
+		t.Fatalf("Expected 3 invocations, got %d", len(invoker.requests))
+	}
+	for _, r := range invoker.requests {
+		if r.TargetRate != 100 || r.Invocations != 0 || !r.IncludeHistograms {
+			t.Errorf("Expected a copy at 100 ops/sec with histograms, got %+v", r)
+		}
+		if r.StartAt == nil || !r.StartAt.Equal(*invoker.requests[0].StartAt) {
+			t.Errorf("Expected every copy to start at the same time, got %v", r.StartAt)
+		}
+	}
+
+	// Two copies ran, one failed
+	if result.TotalOperations != 200 || len(result.ThreadResults) != 2 {
+		t.Errorf("Expected 200 operations from 2 threads, got %d from %d", result.TotalOperations, len(result.ThreadResults))
+	}
+	if result.Seed == nil || result.Seed.Documents != 7 {
+		t.Errorf("Expected the 7 documents the two copies seeded, got %+v", result.Seed)
+	}
+	if result.Rate.RequestedRate != 200 || result.Rate.Scheduled != 200 {
+		t.Errorf("Expected the rates to add up, got %+v", result.Rate)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Count != 2 {
+		t.Errorf("Expected 2 find timeouts, got %+v", result.Errors)
+	}
+	if find := result.Latency[OpFind]; find.Count != 200 || find.P50Ms > 1.01 || find.P90Ms < 2.99 {
+		t.Errorf("Expected p50 of 1ms and p90 of 3ms from the merged histograms, got %+v", find)
+	}
+	if result.Invocations[2].Error == "" || !result.HasErrors() {
+		t.Errorf("Expected the failed invocation to be reported, got %+v", result.Invocations)
+	}
+}
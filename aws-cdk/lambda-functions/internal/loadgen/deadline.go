@@ -0,0 +1,115 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// deadlineMargin is how long before the context's deadline the measured run
+// stops, leaving time to return the result
+const deadlineMargin = 20 * time.Second
+
+// checkpoint is where a run that stopped for a deadline left off. Requests
+// carry it as an opaque token.
+type checkpoint struct {
+	Elapsed     float64  `json:"elapsed"`               // seconds of the measured run done
+	IDs         []string `json:"ids,omitempty"`         // worker ids by thread, which key template documents
+	Inserted    []int64  `json:"inserted,omitempty"`    // documents each thread inserted
+	Invocations []string `json:"invocations,omitempty"` // checkpoints of a coordinator's copies
+}
+
+// token encodes the checkpoint
+func (c checkpoint) token() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseCheckpoint decodes a checkpoint token, or returns nil for ""
+func parseCheckpoint(token string) (*checkpoint, error) {
+	if token == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint: invalid token")
+	}
+	var c checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("checkpoint: invalid token")
+	}
+	if len(c.IDs) != len(c.Inserted) || c.Elapsed < 0 {
+		return nil, fmt.Errorf("checkpoint: invalid token")
+	}
+	return &c, nil
+}
+
+// elapsed is how much of the measured run the checkpoint covers; nil for a
+// new run
+func (c *checkpoint) elapsed() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return time.Duration(c.Elapsed * float64(time.Second))
+}
+
+// validateCheckpoint checks that the checkpoint fits the request
+func (r *Request) validateCheckpoint() error {
+	c, err := parseCheckpoint(r.Checkpoint)
+	if err != nil || c == nil {
+		return err
+	}
+	if r.Invocations > 1 && len(c.Invocations) != r.Invocations {
+		return fmt.Errorf("checkpoint is for %d invocations, not %d", len(c.Invocations), r.Invocations)
+	}
+	if r.Invocations <= 1 && len(c.IDs) > 0 && len(c.IDs) != r.NumThreads {
+		return fmt.Errorf("checkpoint is for %d threads, not %d", len(c.IDs), r.NumThreads)
+	}
+	return nil
+}
+
+// duration is how long the whole measured run lasts, across invocations
+func (r *Request) duration() time.Duration {
+	if len(r.Profile) > 0 {
+		return r.Profile.duration()
+	}
+	return time.Duration(r.DurationMinutes) * time.Minute
+}
+
+// stopTime is when the measured run must stop: deadlineMargin before the
+// context's deadline, or StopAt if that's earlier. It reports false if
+// neither is set.
+func (r *Request) stopTime(ctx context.Context) (time.Time, bool) {
+	var stop time.Time
+	if deadline, ok := ctx.Deadline(); ok {
+		stop = deadline.Add(-deadlineMargin)
+	}
+	if r.StopAt != nil && (stop.IsZero() || r.StopAt.Before(stop)) {
+		stop = *r.StopAt
+	}
+	return stop, !stop.IsZero()
+}
+
+// resume restores the workers' ids and insert counters from a checkpoint
+func resume(workers []*worker, c *checkpoint) {
+	if c == nil {
+		return
+	}
+	for i, w := range workers {
+		if i < len(c.IDs) {
+			w.id, w.inserted = c.IDs[i], c.Inserted[i]
+		}
+	}
+}
+
+// checkpointOf records where workers stopped, elapsed into the measured run
+func checkpointOf(workers []*worker, elapsed time.Duration) checkpoint {
+	c := checkpoint{Elapsed: elapsed.Seconds()}
+	for _, w := range workers {
+		c.IDs = append(c.IDs, w.id)
+		c.Inserted = append(c.Inserted, w.inserted)
+	}
+	return c
+}
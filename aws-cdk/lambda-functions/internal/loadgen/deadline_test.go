@@ -0,0 +1,110 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckpointToken(t *testing.T) {
+	workers := []*worker{{id: "a1", inserted: 10}, {id: "b2", inserted: 20}}
+	c, err := parseCheckpoint(checkpointOf(workers, 90*time.Second).token())
+	if err != nil {
+		t.Fatalf("Expected a valid checkpoint, got %v", err)
+	}
+	if c.elapsed() != 90*time.Second {
+		t.Errorf("Expected 90s elapsed, got %v", c.elapsed())
+	}
+
+	resumed := []*worker{{}, {}}
+	resume(resumed, c)
+	if resumed[1].id != "b2" || resumed[1].inserted != 20 {
+		t.Errorf("Expected thread 1 to resume as b2 after 20 inserts, got %s after %d", resumed[1].id, resumed[1].inserted)
+	}
+
+	for _, token := range []string{"not base64!", "bm90IGpzb24"} {
+		if _, err := parseCheckpoint(token); err == nil {
+			t.Errorf("Expected %q to be rejected", token)
+		}
+	}
+}
+
+func TestValidateCheckpoint(t *testing.T) {
+	token := checkpointOf([]*worker{{id: "a1"}, {id: "b2"}}, time.Minute).token()
+	request := Request{NumThreads: 3, Checkpoint: token}
+	request.SetDefaults()
+	if err := request.Validate(); err == nil {
+		t.Errorf("Expected a checkpoint of 2 threads to be rejected for 3")
+	}
+}
+
+func TestStopTime(t *testing.T) {
+	request := Request{}
+	if _, ok := request.stopTime(context.Background()); ok {
+		t.Errorf("Expected no stop time without a deadline")
+	}
+
+	deadline := time.Now().Add(15 * time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if stop, _ := request.stopTime(ctx); !stop.Equal(deadline.Add(-deadlineMargin)) {
+		t.Errorf("Expected to stop %v before the deadline, got %v", deadlineMargin, time.Until(stop))
+	}
+
+	earlier := time.Now().Add(time.Minute)
+	request.StopAt = &earlier
+	if stop, _ := request.stopTime(ctx); !stop.Equal(earlier) {
+		t.Errorf("Expected to stop at the earlier StopAt, got %v", stop)
+	}
+}
+
+// stoppingInvoker stands in for copies that stop for the deadline, except
+// one that fails
+type stoppingInvoker struct {
+	requests chan Request
+}
+
+func (s stoppingInvoker) Invoke(ctx context.Context, request Request) (Result, error) {
+	s.requests <- request
+	if request.FirstThread == 2 {
+		return Result{}, context.DeadlineExceeded
+	}
+	workers := []*worker{{id: "w", inserted: 5}, {id: "x", inserted: 6}}
+	return Result{Checkpoint: checkpointOf(workers, 10*time.Minute).token(), RemainingSeconds: 300}, nil
+}
+
+func TestCoordinateStopsAndContinues(t *testing.T) {
+	deadline := time.Now().Add(15 * time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	request := Request{Invocations: 2, NumThreads: 2, DurationMinutes: 15}
+	request.SetDefaults()
+	invoker := stoppingInvoker{requests: make(chan Request, 2)}
+	result := Coordinate(ctx, nil, invoker, request)
+
+	for i := 0; i < 2; i++ {
+		if r := <-invoker.requests; r.StopAt == nil || !r.StopAt.Before(deadline.Add(-deadlineMargin)) {
+			t.Errorf("Expected copies to stop before the coordinator, got %v", r.StopAt)
+		}
+	}
+	if !result.Stopped() || result.RemainingSeconds != 300 || result.Teardown != nil {
+		t.Fatalf("Expected a checkpoint with 300 seconds left and no teardown, got %+v", result)
+	}
+
+	// Continuing gives each copy its own checkpoint, and the failed one
+	// where the others stopped
+	request.Checkpoint = result.Checkpoint
+	if err := request.Validate(); err != nil {
+		t.Fatalf("Expected the checkpoint to be valid, got %v", err)
+	}
+	requests := split(request, time.Now())
+	first, _ := parseCheckpoint(requests[0].Checkpoint)
+	second, _ := parseCheckpoint(requests[1].Checkpoint)
+	if first == nil || len(first.IDs) != 2 || first.Inserted[1] != 6 {
+		t.Errorf("Expected the first copy to resume its workers, got %+v", first)
+	}
+	if second == nil || second.elapsed() != 10*time.Minute || len(second.IDs) != 0 {
+		t.Errorf("Expected the failed copy to resume 10 minutes in with new workers, got %+v", second)
+	}
+}
@@ -0,0 +1,163 @@
+package loadgen
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Error classes
+const (
+	ClassServerSelection = "server_selection" // no suitable member, e.g. while the writer fails over
+	ClassNotPrimary      = "not_primary"      // a write or primary read reached a member that isn't the writer
+	ClassWriteConflict   = "write_conflict"
+	ClassNetwork         = "network"
+	ClassTimeout         = "timeout"
+	ClassOther           = "other"
+)
+
+// Server error codes of each class
+var (
+	// NotWritablePrimary, NotPrimaryNoSecondaryOk, NotPrimaryOrSecondary,
+	// PrimarySteppedDown and InterruptedDueToReplStateChange
+	notPrimaryCodes   = []int{10107, 13435, 13436, 189, 11602}
+	writeConflictCode = 112
+)
+
+// maxErrorSamples is how many messages are kept per operation type and class
+const maxErrorSamples = 3
+
+// maxSampleLength truncates sampled messages, which for server selection
+// errors include the whole topology
+const maxSampleLength = 300
+
+// ErrorResult counts the failures of one operation type in one class
+type ErrorResult struct {
+	Operation string   `json:"operation"`
+	Class     string   `json:"class"`
+	Count     int64    `json:"count"`
+	Samples   []string `json:"samples,omitempty"` // the first few messages
+}
+
+// classify returns the class of an operation error. Server selection is
+// checked first because it wraps timeouts.
+func classify(err error) string {
+	var serverErr mongo.ServerError
+	switch {
+	case errors.As(err, &topology.ServerSelectionError{}) || errors.Is(err, topology.ErrServerSelectionTimeout):
+		return ClassServerSelection
+	case errors.As(err, &serverErr) && hasAnyCode(serverErr, notPrimaryCodes):
+		return ClassNotPrimary
+	case errors.As(err, &serverErr) && serverErr.HasErrorCode(writeConflictCode):
+		return ClassWriteConflict
+	case mongo.IsNetworkError(err):
+		return ClassNetwork
+	case mongo.IsTimeout(err):
+		return ClassTimeout
+	}
+	return ClassOther
+}
+
+func hasAnyCode(err mongo.ServerError, codes []int) bool {
+	for _, code := range codes {
+		if err.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCounts holds one worker's errors by operation type and class
+type errorCounts struct {
+	results map[[2]string]*ErrorResult
+	total   int64
+	last    string
+}
+
+func newErrorCounts() *errorCounts {
+	return &errorCounts{results: make(map[[2]string]*ErrorResult)}
+}
+
+// record counts a failed operation
+func (e *errorCounts) record(op string, err error) {
+	class := classify(err)
+	result, ok := e.results[[2]string{op, class}]
+	if !ok {
+		result = &ErrorResult{Operation: op, Class: class}
+		e.results[[2]string{op, class}] = result
+	}
+	result.Count++
+	e.total++
+	e.last = truncate(err.Error())
+	if len(result.Samples) < maxErrorSamples {
+		result.Samples = append(result.Samples, e.last)
+	}
+}
+
+// summary describes the worker's errors for its ThreadResult
+func (e *errorCounts) summary() string {
+	if e.total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d operations failed, last: %s", e.total, e.last)
+}
+
+// mergeErrors merges the workers' errors, sorted by operation type and class
+func mergeErrors(counts []*errorCounts) []ErrorResult {
+	var lists [][]ErrorResult
+	for _, c := range counts {
+		if c == nil {
+			continue
+		}
+		list := make([]ErrorResult, 0, len(c.results))
+		for _, result := range c.results {
+			list = append(list, *result)
+		}
+		lists = append(lists, list)
+	}
+	return combineErrors(lists...)
+}
+
+// combineErrors merges lists of errors, e.g. of several invocations, sorted
+// by operation type and class
+func combineErrors(lists ...[]ErrorResult) []ErrorResult {
+	merged := make(map[[2]string]*ErrorResult)
+	for _, list := range lists {
+		for _, result := range list {
+			key := [2]string{result.Operation, result.Class}
+			m, ok := merged[key]
+			if !ok {
+				m = &ErrorResult{Operation: result.Operation, Class: result.Class}
+				merged[key] = m
+			}
+			m.Count += result.Count
+			for _, sample := range result.Samples {
+				if len(m.Samples) < maxErrorSamples {
+					m.Samples = append(m.Samples, sample)
+				}
+			}
+		}
+	}
+
+	results := make([]ErrorResult, 0, len(merged))
+	for _, result := range merged {
+		results = append(results, *result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Operation != results[j].Operation {
+			return results[i].Operation < results[j].Operation
+		}
+		return results[i].Class < results[j].Class
+	})
+	return results
+}
+
+func truncate(message string) string {
+	if len(message) <= maxSampleLength {
+		return message
+	}
+	return message[:maxSampleLength] + "..."
+}
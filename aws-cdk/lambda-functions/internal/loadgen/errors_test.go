@@ -0,0 +1,75 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{topology.ServerSelectionError{Wrapped: topology.ErrServerSelectionTimeout}, ClassServerSelection},
+		{mongo.CommandError{Code: 10107, Message: "not writable primary"}, ClassNotPrimary},
+		{mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11602}}}, ClassNotPrimary},
+		{mongo.CommandError{Code: 112, Message: "WriteConflict"}, ClassWriteConflict},
+		{mongo.CommandError{Labels: []string{"NetworkError"}, Message: "connection reset"}, ClassNetwork},
+		{fmt.Errorf("find: %w", context.DeadlineExceeded), ClassTimeout},
+		{errors.New("something else"), ClassOther},
+	}
+	for _, test := range tests {
+		if got := classify(test.err); got != test.want {
+			t.Errorf("Expected %v to be classed %s, got %s", test.err, test.want, got)
+		}
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	first, second := newErrorCounts(), newErrorCounts()
+	for i := 0; i < 4; i++ {
+		first.record(OpInsert, mongo.CommandError{Code: 112, Message: fmt.Sprintf("conflict %d", i)})
+	}
+	second.record(OpInsert, mongo.CommandError{Code: 112, Message: "conflict 4"})
+	second.record(OpFind, context.DeadlineExceeded)
+
+	merged := mergeErrors([]*errorCounts{first, second, nil})
+	if len(merged) != 2 {
+		t.Fatalf("Expected 2 operation and class pairs, got %+v", merged)
+	}
+	if merged[0].Operation != OpFind || merged[0].Class != ClassTimeout || merged[0].Count != 1 {
+		t.Errorf("Expected 1 find timeout first, got %+v", merged[0])
+	}
+	if merged[1].Count != 5 || len(merged[1].Samples) != maxErrorSamples {
+		t.Errorf("Expected 5 insert write conflicts with %d samples, got %+v", maxErrorSamples, merged[1])
+	}
+	if summary := second.summary(); summary != "2 operations failed, last: context deadline exceeded" {
+		t.Errorf("Expected a summary of the last error, got %q", summary)
+	}
+}
+
+func TestNewResultErrorRate(t *testing.T) {
+	w := &worker{failures: newErrorCounts()}
+	for i := 0; i < 10; i++ {
+		w.failures.record(OpFind, context.DeadlineExceeded)
+	}
+	results := []ThreadResult{{OperationsCompleted: 90, Errors: 10, Error: w.failures.summary()}}
+
+	result := newResult(Request{MaxErrorRate: 0.2}, []*worker{w}, results, nil)
+	if result.ErrorRate != 0.1 {
+		t.Errorf("Expected an error rate of 0.1, got %g", result.ErrorRate)
+	}
+	if result.Failed() || !result.HasErrors() {
+		t.Errorf("Expected a partial success under the threshold, got %q", result.Message)
+	}
+
+	result = newResult(Request{MaxErrorRate: 0.05}, []*worker{w}, results, nil)
+	if !result.Failed() {
+		t.Errorf("Expected the run to fail over the threshold, got %q", result.Message)
+	}
+}
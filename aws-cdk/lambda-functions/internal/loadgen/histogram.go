@@ -0,0 +1,183 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the precision of a Histogram: each power of two is
+// split into 2^subBucketBits buckets, so a recorded value is off by less
+// than 1%
+const subBucketBits = 7
+
+const subBuckets = 1 << subBucketBits
+
+// Histogram counts latencies in microseconds in log-linear buckets, like an
+// HDR histogram: constant relative precision from a microsecond to hours in
+// a few thousand counters, and histograms merge by adding counts. The zero
+// value is empty and ready to use; it is not safe for concurrent use.
+type Histogram struct {
+	counts []int64
+	total  int64
+	sum    int64
+	max    int64
+}
+
+// bucketOf returns the bucket of a value
+func bucketOf(v int64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits - 1
+	return (shift+1)*subBuckets + int(v>>shift) - subBuckets
+}
+
+// highestIn returns the largest value that falls into bucket
+func highestIn(bucket int) int64 {
+	if bucket < subBuckets {
+		return int64(bucket)
+	}
+	shift := bucket/subBuckets - 1
+	lowest := int64(bucket%subBuckets+subBuckets) << shift
+	return lowest + int64(1)<<shift - 1
+}
+
+// Record adds one latency
+func (h *Histogram) Record(d time.Duration) {
+	v := max(d.Microseconds(), 0)
+	bucket := bucketOf(v)
+	if bucket >= len(h.counts) {
+		grown := make([]int64, bucket+1)
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	h.counts[bucket]++
+	h.total++
+	h.sum += v
+	h.max = max(h.max, v)
+}
+
+// Merge adds the counts of other
+func (h *Histogram) Merge(other *Histogram) {
+	if len(other.counts) > len(h.counts) {
+		grown := make([]int64, len(other.counts))
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	for bucket, count := range other.counts {
+		h.counts[bucket] += count
+	}
+	h.total += other.total
+	h.sum += other.sum
+	h.max = max(h.max, other.max)
+}
+
+// Count returns the number of recorded latencies
+func (h *Histogram) Count() int64 {
+	return h.total
+}
+
+// Max returns the largest recorded latency
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// Mean returns the mean recorded latency
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum/h.total) * time.Microsecond
+}
+
+// Quantile returns the latency at or below which the fraction q of the
+// recorded latencies fall, e.g. 0.99 for p99
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.total)))
+	rank = min(max(rank, 1), h.total)
+
+	var seen int64
+	for bucket, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			return time.Duration(min(highestIn(bucket), h.max)) * time.Microsecond
+		}
+	}
+	return h.Max()
+}
+
+// histogramJSON is a Histogram on the wire: the non-empty buckets as
+// [bucket, count] pairs
+type histogramJSON struct {
+	Buckets [][2]int64 `json:"buckets"`
+	Total   int64      `json:"total"`
+	Sum     int64      `json:"sum"`
+	Max     int64      `json:"max"`
+}
+
+// MarshalJSON encodes the histogram so that another process can merge it
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	wire := histogramJSON{Buckets: [][2]int64{}, Total: h.total, Sum: h.sum, Max: h.max}
+	for bucket, count := range h.counts {
+		if count > 0 {
+			wire.Buckets = append(wire.Buckets, [2]int64{int64(bucket), count})
+		}
+	}
+	return json.Marshal(wire)
+}
+
+// UnmarshalJSON decodes a histogram encoded by MarshalJSON
+func (h *Histogram) UnmarshalJSON(data []byte) error {
+	var wire histogramJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*h = Histogram{total: wire.Total, sum: wire.Sum, max: wire.Max}
+	for _, pair := range wire.Buckets {
+		bucket, count := pair[0], pair[1]
+		if bucket < 0 || bucket > int64(bucketOf(math.MaxInt64)) {
+			return fmt.Errorf("histogram bucket %d out of range", bucket)
+		}
+		if int(bucket) >= len(h.counts) {
+			grown := make([]int64, bucket+1)
+			copy(grown, h.counts)
+			h.counts = grown
+		}
+		h.counts[bucket] += count
+	}
+	return nil
+}
+
+// LatencySummary describes the latencies of one operation type
+type LatencySummary struct {
+	Count  int64   `json:"count"`
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	P999Ms float64 `json:"p99_9_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// Summary reports the percentiles of the histogram
+func (h *Histogram) Summary() LatencySummary {
+	return LatencySummary{
+		Count:  h.total,
+		MeanMs: milliseconds(h.Mean()),
+		P50Ms:  milliseconds(h.Quantile(0.5)),
+		P90Ms:  milliseconds(h.Quantile(0.9)),
+		P99Ms:  milliseconds(h.Quantile(0.99)),
+		P999Ms: milliseconds(h.Quantile(0.999)),
+		MaxMs:  milliseconds(h.Max()),
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,93 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	var h Histogram
+	// 1ms to 1000ms in 1ms steps
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, 500 * time.Millisecond},
+		{0.9, 900 * time.Millisecond},
+		{0.99, 990 * time.Millisecond},
+		{0.999, 999 * time.Millisecond},
+		{1, 1000 * time.Millisecond},
+	}
+	for _, test := range tests {
+		got := h.Quantile(test.q)
+		if diff := math.Abs(float64(got-test.want)) / float64(test.want); diff > 0.01 {
+			t.Errorf("Expected p%g within 1%% of %v, got %v", test.q*100, test.want, got)
+		}
+	}
+	if h.Count() != 1000 {
+		t.Errorf("Expected 1000 latencies, got %d", h.Count())
+	}
+	if h.Max() != time.Second {
+		t.Errorf("Expected max 1s, got %v", h.Max())
+	}
+	if h.Mean() != 500500*time.Microsecond {
+		t.Errorf("Expected mean 500.5ms, got %v", h.Mean())
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	var fast, slow Histogram
+	for i := 0; i < 90; i++ {
+		fast.Record(2 * time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		slow.Record(3 * time.Second)
+	}
+
+	var merged Histogram
+	merged.Merge(&fast)
+	merged.Merge(&slow)
+
+	if merged.Count() != 100 {
+		t.Errorf("Expected 100 latencies, got %d", merged.Count())
+	}
+	if p90 := merged.Quantile(0.9); math.Abs(float64(p90-2*time.Millisecond)) > float64(20*time.Microsecond) {
+		t.Errorf("Expected p90 of about 2ms, got %v", p90)
+	}
+	if p99 := merged.Quantile(0.99); math.Abs(float64(p99-3*time.Second)) > float64(30*time.Millisecond) {
+		t.Errorf("Expected p99 of about 3s, got %v", p99)
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	var h Histogram
+	summary := h.Summary()
+	if summary != (LatencySummary{}) {
+		t.Errorf("Expected an empty summary, got %+v", summary)
+	}
+}
+
+func TestHistogramJSON(t *testing.T) {
+	var h Histogram
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	data, err := json.Marshal(&h)
+	if err != nil {
+		t.Fatalf("Expected the histogram to marshal, got %v", err)
+	}
+	var decoded Histogram
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected the histogram to unmarshal, got %v", err)
+	}
+	if decoded.Summary() != h.Summary() {
+		t.Errorf("Expected %+v after a round trip, got %+v", h.Summary(), decoded.Summary())
+	}
+}
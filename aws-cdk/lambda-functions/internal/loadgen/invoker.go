@@ -0,0 +1,77 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+
+	"docdb-autoscaling-lambdas/internal/docdb"
+)
+
+// Invoker runs one copy of a load test for a coordinator
+type Invoker interface {
+	Invoke(ctx context.Context, request Request) (Result, error)
+}
+
+// LocalInvoker runs copies in this process, sharing one client
+type LocalInvoker struct {
+	Client *docdb.Client
+}
+
+// Invoke runs the request
+func (l LocalInvoker) Invoke(ctx context.Context, request Request) (Result, error) {
+	return Run(ctx, l.Client, request), nil
+}
+
+// LambdaInvoker runs each copy in an invocation of the load-generator
+// function
+type LambdaInvoker struct {
+	Lambda       lambdaiface.LambdaAPI
+	FunctionName string
+}
+
+// response is the load-generator function's response
+type response struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+// Invoke invokes the function and waits for its result. A run that failed
+// its error rate still returns its result; a request the function rejected
+// doesn't.
+func (l LambdaInvoker) Invoke(ctx context.Context, request Request) (Result, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	output, err := l.Lambda.InvokeWithContext(ctx, &lambda.InvokeInput{
+		FunctionName: aws.String(l.FunctionName),
+		Payload:      payload,
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to invoke %s: %w", l.FunctionName, err)
+	}
+	if output.FunctionError != nil {
+		return Result{}, fmt.Errorf("%s failed (%s): %s", l.FunctionName, aws.StringValue(output.FunctionError), truncate(string(output.Payload)))
+	}
+
+	var resp response
+	if err := json.Unmarshal(output.Payload, &resp); err != nil {
+		return Result{}, fmt.Errorf("invalid response from %s: %w", l.FunctionName, err)
+	}
+	var body struct {
+		Result
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		return Result{}, fmt.Errorf("invalid result from %s (status %d): %w", l.FunctionName, resp.StatusCode, err)
+	}
+	if body.Error != "" {
+		return Result{}, fmt.Errorf("%s: %s (status %d)", body.Message, body.Error, resp.StatusCode)
+	}
+	return body.Result, nil
+}
@@ -0,0 +1,169 @@
+package loadgen
+
+import (
+	"sync"
+	"time"
+)
+
+// LatencyInterval is the latency of every worker during one interval, so
+// that it can be lined up with scaling events
+type LatencyInterval struct {
+	Start        time.Time                 `json:"start"`
+	StartSeconds float64                   `json:"start_seconds"` // offset from the start of the run
+	Operations   map[string]LatencySummary `json:"operations"`
+	Servers      map[string]int64          `json:"servers,omitempty"`    // commands by member address, with CommandMonitor
+	Histograms   map[string]*Histogram     `json:"histograms,omitempty"` // by operation type, for a coordinator to merge
+}
+
+// recorder holds one worker's latencies by operation type
+type recorder struct {
+	totals map[string]*Histogram
+	series *series
+}
+
+func newRecorder(s *series) *recorder {
+	return &recorder{totals: make(map[string]*Histogram), series: s}
+}
+
+// record adds the latency of an operation that started at start; a nil
+// recorder records nothing
+func (r *recorder) record(op string, start time.Time, d time.Duration) {
+	if r == nil {
+		return
+	}
+	h, ok := r.totals[op]
+	if !ok {
+		h = &Histogram{}
+		r.totals[op] = h
+	}
+	h.Record(d)
+	r.series.record(op, start, d)
+}
+
+// series is the latency and the members serving commands per interval,
+// shared by the workers of a run
+type series struct {
+	mu        sync.Mutex
+	start     time.Time
+	interval  time.Duration
+	intervals []interval
+	served    map[[2]string]int64 // commands by operation type and address
+}
+
+// interval is one interval of a series
+type interval struct {
+	latencies map[string]*Histogram
+	servers   map[string]int64
+}
+
+func newSeries(length time.Duration) *series {
+	return &series{start: time.Now(), interval: length, served: make(map[[2]string]int64)}
+}
+
+// at returns the interval t falls into, or nil before the start. The caller
+// holds s.mu.
+func (s *series) at(t time.Time) *interval {
+	i := int(t.Sub(s.start) / s.interval)
+	if i < 0 {
+		return nil
+	}
+	for len(s.intervals) <= i {
+		s.intervals = append(s.intervals, interval{latencies: make(map[string]*Histogram), servers: make(map[string]int64)})
+	}
+	return &s.intervals[i]
+}
+
+func (s *series) record(op string, at time.Time, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in := s.at(at)
+	if in == nil {
+		return
+	}
+	h, ok := in.latencies[op]
+	if !ok {
+		h = &Histogram{}
+		in.latencies[op] = h
+	}
+	h.Record(d)
+}
+
+// serve counts a command of op that address served
+func (s *series) serve(op, address string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.served[[2]string{op, address}]++
+	if in := s.at(at); in != nil {
+		in.servers[address]++
+	}
+}
+
+// servers returns the commands each member served, by operation type
+func (s *series) servers() []ServerResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []ServerResult
+	for key, commands := range s.served {
+		results = append(results, ServerResult{Operation: key[0], Address: key[1], Commands: commands})
+	}
+	sortServers(results)
+	return results
+}
+
+// results summarizes each interval, and with histograms includes the
+// histograms themselves
+func (s *series) results(histograms bool) []LatencyInterval {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]LatencyInterval, len(s.intervals))
+	for i, in := range s.intervals {
+		offset := time.Duration(i) * s.interval
+		results[i] = LatencyInterval{
+			Start:        s.start.Add(offset).UTC(),
+			StartSeconds: offset.Seconds(),
+			Operations:   make(map[string]LatencySummary, len(in.latencies)),
+		}
+		for op, h := range in.latencies {
+			results[i].Operations[op] = h.Summary()
+		}
+		if len(in.servers) > 0 {
+			results[i].Servers = in.servers
+		}
+		if histograms {
+			results[i].Histograms = in.latencies
+		}
+	}
+	return results
+}
+
+// mergeLatencies merges the workers' histograms by operation type
+func mergeLatencies(recorders []*recorder) map[string]*Histogram {
+	merged := make(map[string]*Histogram)
+	for _, r := range recorders {
+		if r != nil {
+			mergeHistograms(merged, r.totals)
+		}
+	}
+	return merged
+}
+
+// mergeHistograms adds histograms by operation type into merged
+func mergeHistograms(merged, histograms map[string]*Histogram) {
+	for op, h := range histograms {
+		if merged[op] == nil {
+			merged[op] = &Histogram{}
+		}
+		merged[op].Merge(h)
+	}
+}
+
+// summarize reports the percentiles of each operation type
+func summarize(histograms map[string]*Histogram) map[string]LatencySummary {
+	summaries := make(map[string]LatencySummary, len(histograms))
+	for op, h := range histograms {
+		summaries[op] = h.Summary()
+	}
+	return summaries
+}
@@ -0,0 +1,476 @@
+// Package loadgen generates read and write load against DocumentDB. It backs
+// the load-generator Lambda and the docdbctl loadtest command.
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"docdb-autoscaling-lambdas/internal/docdb"
+)
+
+// Request describes a load test. With a TargetRate, operations are issued
+// at that rate whatever their latency, and NumThreads caps how many run at
+// once; without one, every thread runs operations back to back. A Profile
+// varies the rate or the number of threads over time instead. A Mix weighs
+// the operation types, replacing OperationType, and a Workload template
+// shapes documents and queries like one of the back-end's collections. A
+// Seed writes documents before the measured run, and Teardown says what
+// happens to them after it. With Invocations, a coordinator splits the load
+// across that many copies of the load generator.
+type Request struct {
+	Action          string          `json:"action,omitempty"` // "run" or "cleanup"; default run
+	DurationMinutes int             `json:"durationMinutes"`
+	NumThreads      int             `json:"numThreads"`
+	OperationType   string          `json:"operationType"` // "read", "write", "mixed"
+	Mix             Mix             `json:"mix,omitempty"`
+	Workload        string          `json:"workload,omitempty"`        // a template, e.g. "points"; default synthetic documents
+	ReadPreferences ReadPreferences `json:"readPreferences,omitempty"` // by read operation type; default the connection string's
+	TargetRate      float64         `json:"targetRate,omitempty"`      // operations per second across all threads
+	LateAfterMs     float64         `json:"lateAfterMs,omitempty"`     // lag behind schedule that counts as late
+	Profile         Profile         `json:"profile,omitempty"`
+	IntervalSeconds int             `json:"intervalSeconds,omitempty"` // length of each latency interval
+	MaxErrorRate    float64         `json:"maxErrorRate,omitempty"`    // fraction of operations that may fail, 0 for any
+	Seed            *Seed           `json:"seed,omitempty"`
+	Teardown        string          `json:"teardown,omitempty"` // "keep", "drop" or "ttl"; default keep
+	TTLHours        int             `json:"ttlHours,omitempty"` // how long a ttl teardown keeps documents
+
+	Invocations       int        `json:"invocations,omitempty"`       // copies to fan out to, each with a slice of the rate
+	StartDelaySeconds int        `json:"startDelaySeconds,omitempty"` // time the copies get to start and seed
+	StartAt           *time.Time `json:"startAt,omitempty"`           // when the measured run starts, set by a coordinator
+	FirstThread       int        `json:"firstThread,omitempty"`       // ID of the first thread, set by a coordinator
+	IncludeHistograms bool       `json:"includeHistograms,omitempty"` // for a coordinator to merge
+	StopAt            *time.Time `json:"stopAt,omitempty"`            // when the measured run must stop, e.g. set by a coordinator
+	Checkpoint        string     `json:"checkpoint,omitempty"`        // continues a run stopped for a deadline
+}
+
+// SetDefaults fills in unset fields: 5 minutes, 5 threads, read load without
+// a mix or workload, operations count as late 10ms behind schedule,
+// latency is reported every 10 seconds, and the test data is kept, or
+// expires after 24 hours with a ttl teardown. A profile sets the duration,
+// and the threads if it sets threads rather than rates.
+func (r *Request) SetDefaults() {
+	if r.Action == "" {
+		r.Action = ActionRun
+	}
+	if len(r.Profile) > 0 {
+		r.DurationMinutes = int(math.Ceil(r.Profile.duration().Minutes()))
+		if !r.Profile.byRate() {
+			r.NumThreads = r.Profile.maxThreads()
+		}
+	}
+	if r.DurationMinutes <= 0 {
+		r.DurationMinutes = 5
+	}
+	if r.NumThreads <= 0 {
+		r.NumThreads = 5
+	}
+	if r.OperationType == "" && len(r.Mix) == 0 && r.Workload == "" {
+		r.OperationType = "read"
+	}
+	if r.LateAfterMs <= 0 {
+		r.LateAfterMs = 10
+	}
+	if r.IntervalSeconds <= 0 {
+		r.IntervalSeconds = 10
+	}
+	if r.Teardown == "" {
+		r.Teardown = TeardownKeep
+	}
+	if r.TTLHours <= 0 {
+		r.TTLHours = 24
+	}
+	if r.Invocations > 1 && r.StartDelaySeconds <= 0 {
+		r.StartDelaySeconds = 30
+	}
+}
+
+// Validate checks a request after SetDefaults
+func (r *Request) Validate() error {
+	switch r.Action {
+	case ActionRun:
+	case ActionCleanup:
+		return nil
+	default:
+		return fmt.Errorf("action must be run or cleanup, got %q", r.Action)
+	}
+	switch r.Teardown {
+	case TeardownKeep, TeardownDrop, TeardownTTL:
+	default:
+		return fmt.Errorf("teardown must be keep, drop or ttl, got %q", r.Teardown)
+	}
+	if r.Invocations < 0 || r.FirstThread < 0 {
+		return fmt.Errorf("invocations and firstThread must not be negative")
+	}
+	if err := r.validateCheckpoint(); err != nil {
+		return err
+	}
+	if r.Seed != nil {
+		if err := r.Seed.validate(); err != nil {
+			return err
+		}
+	}
+	if r.Workload != "" {
+		if templates[r.Workload] == nil {
+			return fmt.Errorf("workload must be one of %s, got %q", strings.Join(templateNames(), ", "), r.Workload)
+		}
+		if r.OperationType != "" {
+			return fmt.Errorf("set either operationType or workload, not both; a workload takes a mix")
+		}
+	}
+	if len(r.Mix) > 0 {
+		if r.OperationType != "" {
+			return fmt.Errorf("set either operationType or mix, not both")
+		}
+		if err := r.Mix.validate(); err != nil {
+			return err
+		}
+	} else if _, ok := operationTypeMixes[r.OperationType]; !ok && r.Workload == "" {
+		return fmt.Errorf("operationType must be read, write or mixed, got %q", r.OperationType)
+	}
+	for op, weight := range r.mix() {
+		if wl := r.workload(); weight > 0 && wl.ops[op] == nil {
+			return fmt.Errorf("workload %s doesn't support %s: it supports %s", r.Workload, op, strings.Join(wl.supports(), ", "))
+		}
+	}
+	if err := r.ReadPreferences.validate(); err != nil {
+		return err
+	}
+	if r.MaxErrorRate < 0 || r.MaxErrorRate > 1 {
+		return fmt.Errorf("maxErrorRate must be between 0 and 1, got %g", r.MaxErrorRate)
+	}
+	if r.TargetRate < 0 {
+		return fmt.Errorf("targetRate must not be negative, got %g", r.TargetRate)
+	}
+	if len(r.Profile) > 0 {
+		if r.TargetRate > 0 {
+			return fmt.Errorf("set either targetRate or profile, not both")
+		}
+		return r.Profile.validate()
+	}
+	return nil
+}
+
+// mix returns the Mix, or the default of the Workload or OperationType
+func (r *Request) mix() Mix {
+	if len(r.Mix) > 0 {
+		return r.Mix
+	}
+	if r.Workload != "" {
+		return r.workload().mix
+	}
+	return operationTypeMixes[r.OperationType]
+}
+
+// workload returns the Workload template, or the synthetic workload
+func (r *Request) workload() *workload {
+	if wl := templates[r.Workload]; wl != nil {
+		return wl
+	}
+	return syntheticWorkload
+}
+
+// interval is the length of each latency interval
+func (r *Request) interval() time.Duration {
+	if r.IntervalSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(r.IntervalSeconds) * time.Second
+}
+
+// Result represents the detailed result
+type Result struct {
+	Message          string                    `json:"message"`
+	TotalOperations  int64                     `json:"total_operations"`
+	ThreadResults    []ThreadResult            `json:"thread_results"`
+	Rate             *RateResult               `json:"rate,omitempty"`           // only for a TargetRate or rate profile
+	Phases           []PhaseResult             `json:"phases,omitempty"`         // only for a profile
+	Latency          map[string]LatencySummary `json:"latency,omitempty"`        // by operation type
+	LatencySeries    []LatencyInterval         `json:"latency_series,omitempty"` // every IntervalSeconds
+	Errors           []ErrorResult             `json:"errors,omitempty"`
+	Servers          []ServerResult            `json:"servers,omitempty"` // with CommandMonitor
+	Seed             *SeedResult               `json:"seed,omitempty"`
+	Teardown         *TeardownResult           `json:"teardown,omitempty"`
+	Invocations      []InvocationResult        `json:"invocations,omitempty"` // only for a coordinator
+	Histograms       map[string]*Histogram     `json:"histograms,omitempty"`  // with IncludeHistograms
+	Checkpoint       string                    `json:"checkpoint,omitempty"`  // if the run stopped for a deadline
+	RemainingSeconds float64                   `json:"remaining_seconds,omitempty"`
+	ErrorRate        float64                   `json:"error_rate"` // failed fraction of attempted operations
+	TestParameters   Request                   `json:"test_parameters"`
+}
+
+// HasErrors reports whether any thread or invocation failed
+func (r Result) HasErrors() bool {
+	for _, threadResult := range r.ThreadResults {
+		if threadResult.Error != "" {
+			return true
+		}
+	}
+	for _, invocation := range r.Invocations {
+		if invocation.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Stopped reports whether the run stopped for a deadline before its
+// duration, and can continue from Checkpoint
+func (r Result) Stopped() bool {
+	return r.Checkpoint != ""
+}
+
+// Failed reports whether more operations failed than MaxErrorRate allows
+func (r Result) Failed() bool {
+	return r.TestParameters.MaxErrorRate > 0 && r.ErrorRate > r.TestParameters.MaxErrorRate
+}
+
+// ThreadResult represents the result from a single thread
+type ThreadResult struct {
+	ThreadID            int     `json:"thread_id"`
+	OperationsCompleted int64   `json:"operations_completed"`
+	DurationSeconds     float64 `json:"duration_seconds"`
+	Errors              int64   `json:"errors,omitempty"` // failed operations
+	Error               string  `json:"error,omitempty"`
+}
+
+// Run seeds the test data, generates load with request.NumThreads
+// concurrent workers sharing client until the duration expires, and tears
+// the data down. If the context's deadline or StopAt comes first, the run
+// stops short of it and returns what it did with a checkpoint to continue
+// from, keeping the test data.
+func Run(ctx context.Context, client *docdb.Client, request Request) Result {
+	from, _ := parseCheckpoint(request.Checkpoint) // validated
+	stop, hasStop := request.stopTime(ctx)
+	runCtx, cancel := ctx, context.CancelFunc(func() {})
+	if hasStop {
+		// Cuts off operations still running well after the stop
+		runCtx, cancel = context.WithDeadline(ctx, stop.Add(deadlineMargin/2))
+	}
+	defer cancel()
+
+	workers, seed := prepare(runCtx, client, request, from)
+	if request.StartAt != nil {
+		if late := time.Since(*request.StartAt); late > 0 {
+			log.Printf("Warning: starting %.1f seconds after the coordinated start", late.Seconds())
+		}
+		sleepUntil(runCtx, *request.StartAt)
+	}
+
+	offset := from.elapsed()
+	duration := max(request.duration()-offset, 0)
+	stopped := hasStop && time.Until(stop) < duration
+	if stopped {
+		duration = max(time.Until(stop), 0)
+		log.Printf("Warning: %.0f seconds of the run left, but only %.0f before the deadline; returning a checkpoint",
+			(request.duration() - offset).Seconds(), duration.Seconds())
+	}
+
+	var result Result
+	start := time.Now()
+	switch {
+	case len(request.Profile) > 0 && request.Profile.byRate():
+		rateAt := func(elapsed time.Duration) float64 { return request.Profile.rateAt(offset + elapsed) }
+		result = runAtRate(runCtx, request, workers, rateAt, duration, offset)
+	case len(request.Profile) > 0:
+		threadsAt := func(elapsed time.Duration) int { return request.Profile.threadsAt(offset + elapsed) }
+		result = runClosedLoop(runCtx, request, workers, threadsAt, duration, offset)
+	case request.TargetRate > 0:
+		rate := request.TargetRate
+		result = runAtRate(runCtx, request, workers, func(time.Duration) float64 { return rate }, duration, offset)
+	default:
+		result = runClosedLoop(runCtx, request, workers, nil, duration, offset)
+	}
+	result.Seed = seed
+
+	if stopped {
+		done := offset + min(time.Since(start), duration)
+		result.Checkpoint = checkpointOf(workers, done).token()
+		result.RemainingSeconds = (request.duration() - done).Seconds()
+		result.Message += fmt.Sprintf("; stopped for the deadline with %.0f seconds left, continue from the checkpoint", result.RemainingSeconds)
+		return result
+	}
+	result.Teardown = teardown(ctx, request, workers)
+	return result
+}
+
+// runClosedLoop runs the workers back to back. With threadsAt, only the
+// first threadsAt(elapsed) of them are active. offset is how much of the
+// run earlier invocations did.
+func runClosedLoop(ctx context.Context, request Request, workers []*worker, threadsAt func(time.Duration) int, duration, offset time.Duration) Result {
+	phases := newProgress(request.Profile, offset)
+	latencies := newSeries(request.interval())
+	start := time.Now()
+
+	// Run the workers concurrently
+	var wg sync.WaitGroup
+	results := make([]ThreadResult, len(workers))
+
+	for i, w := range workers {
+		w.phases, w.latency = phases, newRecorder(latencies)
+		if threadsAt != nil {
+			w.active = func() bool { return i < threadsAt(time.Since(start)) }
+		}
+
+		wg.Add(1)
+		go func(i int, w *worker) {
+			defer wg.Done()
+			results[i] = w.generateLoad(ctx, duration)
+			log.Printf("Thread %d completed: %+v", w.threadID, results[i])
+		}(i, w)
+	}
+
+	// Wait for all workers to complete
+	wg.Wait()
+
+	result := newResult(request, workers, results, nil)
+	result.Phases = phases.results()
+	result.LatencySeries = latencies.results(request.IncludeHistograms)
+	result.Servers = latencies.servers()
+	return result
+}
+
+// newResult merges the workers' latencies and errors and totals the thread
+// results
+func newResult(request Request, workers []*worker, results []ThreadResult, rate *RateResult) Result {
+	recorders := make([]*recorder, len(workers))
+	failures := make([]*errorCounts, len(workers))
+	for i, w := range workers {
+		recorders[i], failures[i] = w.latency, w.failures
+	}
+
+	histograms := mergeLatencies(recorders)
+	result := Result{
+		ThreadResults:  results,
+		Rate:           rate,
+		Latency:        summarize(histograms),
+		Errors:         mergeErrors(failures),
+		TestParameters: request,
+	}
+	if request.IncludeHistograms {
+		result.Histograms = histograms
+	}
+	result.total()
+	return result
+}
+
+// total adds up the operations and failures of the thread results, and
+// sets the error rate and message
+func (r *Result) total() {
+	var failed int64
+	r.TotalOperations = 0
+	for _, result := range r.ThreadResults {
+		r.TotalOperations += result.OperationsCompleted
+		failed += result.Errors
+	}
+	r.ErrorRate = 0
+	if attempted := r.TotalOperations + failed; attempted > 0 {
+		r.ErrorRate = float64(failed) / float64(attempted)
+	}
+
+	switch {
+	case r.Failed():
+		r.Message = fmt.Sprintf("Load generation failed: %.1f%% of operations failed, more than the %.1f%% allowed",
+			r.ErrorRate*100, r.TestParameters.MaxErrorRate*100)
+	case r.HasErrors():
+		r.Message = "Load generation completed with some errors"
+	default:
+		r.Message = "Load generation completed"
+	}
+}
+
+// worker handles load generation for a single thread
+type worker struct {
+	threadID      int
+	id            string // unique to the worker and run
+	client        *docdb.Client
+	workload      *workload
+	readPrefs     ReadPreferences
+	documentBytes int                          // of synthetic documents
+	routes        map[string]*mongo.Collection // by operation type, with its read preference
+	ops           *picker
+	rand          *rand.Rand
+	inserted      int64       // documents inserted, which are numbered
+	phases        *progress   // nil without a profile
+	active        func() bool // nil if always active
+	latency       *recorder
+	failures      *errorCounts
+}
+
+func newWorker(threadID int, client *docdb.Client, request Request) *worker {
+	random := rand.New(rand.NewSource(time.Now().UnixNano() + int64(threadID)))
+	return &worker{
+		threadID:      threadID,
+		id:            strconv.FormatInt(random.Int63(), 36),
+		client:        client,
+		workload:      request.workload(),
+		readPrefs:     request.ReadPreferences,
+		documentBytes: request.documentBytes(),
+		ops:           request.mix().picker(),
+		rand:          random,
+		failures:      newErrorCounts(),
+	}
+}
+
+// inactivePoll is how often an inactive worker checks whether to resume
+const inactivePoll = 100 * time.Millisecond
+
+// generateLoad performs the actual load generation
+func (w *worker) generateLoad(ctx context.Context, duration time.Duration) ThreadResult {
+	startTime := time.Now()
+	var operationsCount int64
+
+	collection := w.collection()
+
+	// Generate load until duration expires
+	endTime := startTime.Add(duration)
+	for time.Now().Before(endTime) && ctx.Err() == nil {
+		if w.active != nil && !w.active() {
+			time.Sleep(inactivePoll)
+			continue
+		}
+
+		op := w.ops.next()
+		if err := w.perform(ctx, collection, op); err != nil {
+			w.fail(ctx, op, err)
+		} else {
+			operationsCount++
+			w.phases.record()
+		}
+
+		// Small delay to control load intensity
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return w.result(operationsCount, startTime)
+}
+
+// fail counts a failed operation. Operations cut off because the run is
+// stopping don't count.
+func (w *worker) fail(ctx context.Context, op string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	log.Printf("%s error in thread %d: %v", op, w.threadID, err)
+	w.failures.record(op, err)
+}
+
+// result reports the worker's operations since startTime
+func (w *worker) result(operationsCount int64, startTime time.Time) ThreadResult {
+	return ThreadResult{
+		ThreadID:            w.threadID,
+		OperationsCompleted: operationsCount,
+		DurationSeconds:     time.Since(startTime).Seconds(),
+		Errors:              w.failures.total,
+		Error:               w.failures.summary(),
+	}
+}
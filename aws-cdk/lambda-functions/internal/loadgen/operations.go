@@ -0,0 +1,290 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Operation types
+const (
+	OpFind      = "find"  // indexed point lookup of one document
+	OpRange     = "range" // indexed range scan of up to 100 documents
+	OpInsert    = "insert"
+	OpUpdate    = "update"    // $inc of one document's hits
+	OpDelete    = "delete"    // one document
+	OpAggregate = "aggregate" // $group of the thread's documents
+)
+
+// operations lists the operation types in the order a picker interleaves them
+var operations = []string{OpFind, OpRange, OpInsert, OpUpdate, OpDelete, OpAggregate}
+
+// rangeLimit is how many documents a range scan reads
+const rangeLimit = 100
+
+// Mix weighs operation types, e.g. {"find": 60, "insert": 20, "update": 15,
+// "aggregate": 5} for 60% point lookups
+type Mix map[string]int
+
+// operationTypeMixes are the mixes of the operation types
+var operationTypeMixes = map[string]Mix{
+	"read":  {OpRange: 1},
+	"write": {OpInsert: 1},
+	"mixed": {OpInsert: 1, OpRange: 1},
+}
+
+// validate checks the operation types and weights
+func (m Mix) validate() error {
+	total := 0
+	for op, weight := range m {
+		known := false
+		for _, o := range operations {
+			known = known || op == o
+		}
+		if !known {
+			return fmt.Errorf("mix: unknown operation %q: must be one of %s", op, strings.Join(operations, ", "))
+		}
+		if weight < 0 {
+			return fmt.Errorf("mix: weight of %s must not be negative, got %d", op, weight)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("mix: needs an operation with a positive weight")
+	}
+	return nil
+}
+
+// String formats the mix as op=weight pairs
+func (m Mix) String() string {
+	var pairs []string
+	for _, op := range operations {
+		if m[op] > 0 {
+			pairs = append(pairs, fmt.Sprintf("%s=%d", op, m[op]))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseMix parses op=weight pairs separated by commas, e.g.
+// "find=60,insert=20,update=15,aggregate=5"
+func ParseMix(s string) (Mix, error) {
+	mix := make(Mix)
+	for _, pair := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("mix: %q is not op=weight", pair)
+		}
+		var w int
+		if _, err := fmt.Sscanf(weight, "%d", &w); err != nil {
+			return nil, fmt.Errorf("mix: weight of %s must be a number, got %q", op, weight)
+		}
+		mix[op] = w
+	}
+	return mix, mix.validate()
+}
+
+// picker chooses operations in proportion to their weights, interleaved as
+// evenly as possible (smooth weighted round robin)
+type picker struct {
+	ops     []string
+	weights []int
+	current []int
+	total   int
+}
+
+func (m Mix) picker() *picker {
+	p := &picker{}
+	for _, op := range operations {
+		if m[op] > 0 {
+			p.ops = append(p.ops, op)
+			p.weights = append(p.weights, m[op])
+			p.total += m[op]
+		}
+	}
+	p.current = make([]int, len(p.ops))
+	return p
+}
+
+// next returns the operation to run next
+func (p *picker) next() string {
+	best := 0
+	for i := range p.ops {
+		p.current[i] += p.weights[i]
+		if p.current[i] > p.current[best] {
+			best = i
+		}
+	}
+	p.current[best] -= p.total
+	return p.ops[best]
+}
+
+// operation runs one operation of a workload
+type operation func(ctx context.Context, w *worker, collection *mongo.Collection) error
+
+// workload is a collection, its indexes, the documents inserted into it and
+// the query each operation type runs against it
+type workload struct {
+	database   string // "" for a database per thread
+	collection string
+	mix        Mix // default, if any
+	indexes    []mongo.IndexModel
+	document   func(w *worker) bson.M                                                            // the worker's next document, if the workload inserts
+	seed       func(ctx context.Context, w *worker, c *mongo.Collection, n int64) (int64, error) // instead of inserting documents
+	ops        map[string]operation
+}
+
+// supports lists the operation types of the workload
+func (wl *workload) supports() []string {
+	var ops []string
+	for _, op := range operations {
+		if wl.ops[op] != nil {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// syntheticWorkload writes numbered documents, 1KB by default, into a
+// database per thread
+var syntheticWorkload = &workload{
+	collection: "load_test",
+	indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "thread_id", Value: 1}, {Key: "counter", Value: 1}}},
+	},
+	document: func(w *worker) bson.M {
+		now := time.Now()
+		return bson.M{
+			"thread_id": w.threadID,
+			"timestamp": now.Unix(),
+			"createdAt": now.UTC(), // for a TTL teardown
+			"data":      strings.Repeat("x", w.documentBytes),
+			"counter":   w.inserted,
+			"hits":      0,
+		}
+	},
+	ops: map[string]operation{
+		OpFind: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			return ignoreNoDocuments(c.FindOne(ctx, w.someDocument()).Err())
+		},
+		OpRange: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			filter := w.someDocument()
+			filter["counter"] = bson.M{"$gte": filter["counter"]}
+			cursor, err := c.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "counter", Value: 1}}).SetLimit(rangeLimit))
+			return drain(ctx, cursor, err)
+		},
+		OpInsert: insertDocument,
+		OpUpdate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.UpdateOne(ctx, w.someDocument(), bson.M{"$inc": bson.M{"hits": 1}})
+			return err
+		},
+		OpDelete: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.DeleteOne(ctx, w.someDocument())
+			return err
+		},
+		// Totals the hits of the thread's documents in ten groups
+		OpAggregate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"thread_id": w.threadID}}},
+				{{Key: "$group", Value: bson.M{
+					"_id":   bson.M{"$mod": bson.A{"$counter", 10}},
+					"count": bson.M{"$sum": 1},
+					"hits":  bson.M{"$sum": "$hits"},
+				}}},
+			})
+			return drain(ctx, cursor, err)
+		},
+	},
+}
+
+// collection returns the worker's collection
+func (w *worker) collection() *mongo.Collection {
+	if w.workload.database == "" {
+		return w.client.Collection(fmt.Sprintf("test_db_%d", w.threadID), w.workload.collection)
+	}
+	return w.client.Collection(w.workload.database, w.workload.collection)
+}
+
+// ensureIndexes creates the indexes the workload's queries use, and any
+// extra ones
+func (w *worker) ensureIndexes(ctx context.Context, collection *mongo.Collection, extra []mongo.IndexModel) {
+	indexes := append(append([]mongo.IndexModel{}, w.workload.indexes...), extra...)
+	if len(indexes) == 0 {
+		return
+	}
+	if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil && ctx.Err() == nil {
+		log.Printf("Warning: thread %d failed to create indexes: %v", w.threadID, err)
+	}
+}
+
+// perform runs one operation of type op, recording its latency if it
+// succeeds
+func (w *worker) perform(ctx context.Context, collection *mongo.Collection, op string) error {
+	start := time.Now()
+	run := w.workload.ops[op]
+	if run == nil {
+		return fmt.Errorf("unknown operation %q", op)
+	}
+	if err := run(w.withOperation(ctx, op), w, w.routed(collection, op)); err != nil {
+		return err
+	}
+
+	w.latency.record(op, start, time.Since(start))
+	return nil
+}
+
+// someDocument filters one of the documents the worker inserted
+func (w *worker) someDocument() bson.M {
+	return bson.M{"thread_id": w.threadID, "counter": w.someNumber()}
+}
+
+// someNumber returns the number of one of the documents the worker inserted
+func (w *worker) someNumber() int64 {
+	return w.rand.Int63n(max(w.inserted, 1))
+}
+
+// insertDocument inserts the worker's next document
+func insertDocument(ctx context.Context, w *worker, c *mongo.Collection) error {
+	return w.insert(ctx, c, w.workload.document(w))
+}
+
+// insert inserts the worker's next document
+func (w *worker) insert(ctx context.Context, collection *mongo.Collection, doc bson.M) error {
+	if _, err := collection.InsertOne(ctx, doc); err != nil {
+		return err
+	}
+	w.inserted++
+	return nil
+}
+
+// drain reads every document of a cursor, as a client would
+func drain(ctx context.Context, cursor *mongo.Cursor, err error) error {
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var result bson.M
+		if err := cursor.Decode(&result); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// ignoreNoDocuments treats a lookup that found nothing as a success: the
+// document was deleted or not yet written
+func ignoreNoDocuments(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package loadgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPickerKeepsProportions(t *testing.T) {
+	p := Mix{OpFind: 60, OpInsert: 20, OpUpdate: 15, OpAggregate: 5}.picker()
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		counts[p.next()]++
+	}
+
+	want := map[string]int{OpFind: 600, OpInsert: 200, OpUpdate: 150, OpAggregate: 50}
+	for op, count := range want {
+		if counts[op] != count {
+			t.Errorf("Expected %d %s operations of 1000, got %d", count, op, counts[op])
+		}
+	}
+}
+
+func TestPickerInterleaves(t *testing.T) {
+	p := operationTypeMixes["mixed"].picker()
+	var ops []string
+	for i := 0; i < 4; i++ {
+		ops = append(ops, p.next())
+	}
+	if got := strings.Join(ops, ","); got != "range,insert,range,insert" {
+		t.Errorf("Expected mixed load to alternate, got %s", got)
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("find=60, insert=20,aggregate=0")
+	if err != nil {
+		t.Fatalf("Expected a valid mix, got %v", err)
+	}
+	if mix.String() != "find=60,insert=20" {
+		t.Errorf("Expected find=60,insert=20, got %s", mix)
+	}
+
+	for _, s := range []string{"find", "find=x", "scan=10", "find=-1", "find=0"} {
+		if _, err := ParseMix(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestValidateMix(t *testing.T) {
+	request := Request{Mix: Mix{OpFind: 1}}
+	request.SetDefaults()
+	if err := request.Validate(); err != nil {
+		t.Errorf("Expected a mix without an operation type to be valid, got %v", err)
+	}
+	if request.OperationType != "" {
+		t.Errorf("Expected no default operation type with a mix, got %q", request.OperationType)
+	}
+
+	request.OperationType = "read"
+	if err := request.Validate(); err == nil {
+		t.Errorf("Expected a mix and an operation type to be rejected")
+	}
+
+	request = Request{OperationType: "scan"}
+	request.SetDefaults()
+	if err := request.Validate(); err == nil {
+		t.Errorf("Expected an unknown operation type to be rejected")
+	}
+}
+
+func TestTemplatesSupportTheirMix(t *testing.T) {
+	for name, wl := range templates {
+		for op := range wl.mix {
+			if wl.ops[op] == nil {
+				t.Errorf("Expected workload %s to support %s in its default mix", name, op)
+			}
+		}
+		if len(wl.indexes) == 0 {
+			t.Errorf("Expected workload %s to index its queries", name)
+		}
+	}
+}
+
+func TestValidateWorkload(t *testing.T) {
+	request := Request{Workload: WorkloadPoints}
+	request.SetDefaults()
+	if err := request.Validate(); err != nil {
+		t.Errorf("Expected the points workload to be valid, got %v", err)
+	}
+	if request.mix()[OpAggregate] == 0 {
+		t.Errorf("Expected the points workload to run the leaderboard by default, got %s", request.mix())
+	}
+
+	tests := []struct {
+		name    string
+		request Request
+	}{
+		{"unknown workload", Request{Workload: "videos"}},
+		{"operation type", Request{Workload: WorkloadUsers, OperationType: "read"}},
+		{"unsupported operation", Request{Workload: WorkloadBadWords, Mix: Mix{OpFind: 9, OpDelete: 1}}},
+	}
+	for _, test := range tests {
+		test.request.SetDefaults()
+		if err := test.request.Validate(); err == nil {
+			t.Errorf("Expected %s to be rejected", test.name)
+		}
+	}
+}
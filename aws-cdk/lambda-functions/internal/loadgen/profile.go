@@ -0,0 +1,252 @@
+package loadgen
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Phase shapes
+const (
+	ShapeConstant = "constant" // Rate throughout
+	ShapeSoak     = "soak"     // a long constant phase
+	ShapeRamp     = "ramp"     // linear from Rate to ToRate
+	ShapeStep     = "step"     // Steps equal stairs from Rate to ToRate
+	ShapeSpike    = "spike"    // Rate, with ToRate for SpikeMinutes in the middle
+	ShapeSine     = "sine"     // a day cycle between Rate and ToRate every PeriodMinutes
+)
+
+// Phase is one part of a load profile. It sets either a rate, for open-loop
+// load, or a number of threads, for closed-loop load; every phase of a
+// profile must set the same one.
+type Phase struct {
+	Shape           string  `json:"shape"`
+	DurationMinutes float64 `json:"durationMinutes"`
+	Rate            float64 `json:"rate,omitempty"`    // operations per second, at the start or throughout
+	ToRate          float64 `json:"toRate,omitempty"`  // end of a ramp or step, peak of a spike or sine
+	Threads         int     `json:"threads,omitempty"` // concurrent threads, like Rate
+	ToThreads       int     `json:"toThreads,omitempty"`
+	Steps           int     `json:"steps,omitempty"`         // stairs of a step, default 4
+	SpikeMinutes    float64 `json:"spikeMinutes,omitempty"`  // length of a spike, default 1
+	PeriodMinutes   float64 `json:"periodMinutes,omitempty"` // length of a sine cycle, default the phase
+}
+
+// PhaseResult is what one phase of a profile achieved
+type PhaseResult struct {
+	Shape         string  `json:"shape"`
+	StartSeconds  float64 `json:"start_seconds"` // offset from the start of the run
+	EndSeconds    float64 `json:"end_seconds"`
+	Operations    int64   `json:"operations"`
+	RequestedRate float64 `json:"requested_rate,omitempty"` // mean of the phase, for rate profiles
+	AchievedRate  float64 `json:"achieved_rate"`
+}
+
+// Profile is a sequence of phases
+type Profile []Phase
+
+// validate checks every phase and that they agree on rate or threads
+func (p Profile) validate() error {
+	byRate := p.byRate()
+	for i, phase := range p {
+		name := fmt.Sprintf("profile phase %d (%s)", i+1, phase.Shape)
+		switch phase.Shape {
+		case ShapeConstant, ShapeSoak, ShapeRamp, ShapeStep, ShapeSpike, ShapeSine:
+		default:
+			return fmt.Errorf("profile phase %d: shape must be constant, soak, ramp, step, spike or sine, got %q", i+1, phase.Shape)
+		}
+		if phase.DurationMinutes <= 0 {
+			return fmt.Errorf("%s: durationMinutes must be positive", name)
+		}
+		if phase.Rate < 0 || phase.ToRate < 0 || phase.Threads < 0 || phase.ToThreads < 0 || phase.Steps < 0 {
+			return fmt.Errorf("%s: rates, threads and steps must not be negative", name)
+		}
+
+		if phase.Rate > 0 || phase.ToRate > 0 {
+			if !byRate || phase.Threads > 0 || phase.ToThreads > 0 {
+				return fmt.Errorf("%s: every phase must set either rates or threads, not both", name)
+			}
+		} else if phase.Threads > 0 || phase.ToThreads > 0 {
+			if byRate {
+				return fmt.Errorf("%s: every phase must set either rates or threads, not both", name)
+			}
+		} else if phase.Shape != ShapeConstant && phase.Shape != ShapeSoak {
+			return fmt.Errorf("%s: needs a rate or threads", name)
+		}
+
+		if phase.Shape == ShapeSpike && phase.spikeMinutes() > phase.DurationMinutes {
+			return fmt.Errorf("%s: spikeMinutes must not exceed durationMinutes", name)
+		}
+	}
+	return nil
+}
+
+// byRate reports whether the profile sets rates rather than threads
+func (p Profile) byRate() bool {
+	for _, phase := range p {
+		if phase.Rate > 0 || phase.ToRate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// duration is the length of the whole profile
+func (p Profile) duration() time.Duration {
+	var minutes float64
+	for _, phase := range p {
+		minutes += phase.DurationMinutes
+	}
+	return time.Duration(minutes * float64(time.Minute))
+}
+
+// phaseAt returns the index of the phase running at elapsed and how far into
+// it elapsed is, or -1 once the profile is over
+func (p Profile) phaseAt(elapsed time.Duration) (int, time.Duration) {
+	for i, phase := range p {
+		length := time.Duration(phase.DurationMinutes * float64(time.Minute))
+		if elapsed < length {
+			return i, elapsed
+		}
+		elapsed -= length
+	}
+	return -1, 0
+}
+
+// rateAt returns the operations per second the profile asks for at elapsed
+func (p Profile) rateAt(elapsed time.Duration) float64 {
+	i, into := p.phaseAt(elapsed)
+	if i < 0 {
+		return 0
+	}
+	return p[i].level(into, p[i].Rate, p[i].ToRate)
+}
+
+// threadsAt returns the threads the profile asks for at elapsed
+func (p Profile) threadsAt(elapsed time.Duration) int {
+	i, into := p.phaseAt(elapsed)
+	if i < 0 {
+		return 0
+	}
+	return int(math.Round(p[i].level(into, float64(p[i].Threads), float64(p[i].ToThreads))))
+}
+
+// maxThreads returns the most threads any phase asks for
+func (p Profile) maxThreads() int {
+	most := 0
+	for _, phase := range p {
+		most = max(most, phase.Threads, phase.ToThreads)
+	}
+	return most
+}
+
+// level shapes the value of the phase at into, going from start to to
+func (phase Phase) level(into time.Duration, start, to float64) float64 {
+	progress := into.Minutes() / phase.DurationMinutes
+
+	switch phase.Shape {
+	case ShapeRamp:
+		return start + (to-start)*progress
+	case ShapeStep:
+		steps := phase.Steps
+		if steps <= 0 {
+			steps = 4
+		}
+		if steps == 1 {
+			return to
+		}
+		stair := math.Min(math.Floor(progress*float64(steps)), float64(steps-1))
+		return start + (to-start)*stair/float64(steps-1)
+	case ShapeSpike:
+		// The spike is centred in the phase
+		spikeStart := (phase.DurationMinutes - phase.spikeMinutes()) / 2
+		if minutes := into.Minutes(); minutes >= spikeStart && minutes < spikeStart+phase.spikeMinutes() {
+			return to
+		}
+		return start
+	case ShapeSine:
+		period := phase.PeriodMinutes
+		if period <= 0 {
+			period = phase.DurationMinutes
+		}
+		// Starts at the trough, peaks half a period in
+		return start + (to-start)*(1-math.Cos(2*math.Pi*into.Minutes()/period))/2
+	}
+	return start
+}
+
+// scaled returns a copy of the profile with every rate multiplied by factor
+func (p Profile) scaled(factor float64) Profile {
+	scaled := make(Profile, len(p))
+	for i, phase := range p {
+		phase.Rate *= factor
+		phase.ToRate *= factor
+		scaled[i] = phase
+	}
+	return scaled
+}
+
+// progress counts completed operations by the phase they completed in
+type progress struct {
+	start   time.Time
+	profile Profile
+	counts  []int64
+}
+
+// newProgress starts counting offset into the profile
+func newProgress(profile Profile, offset time.Duration) *progress {
+	if len(profile) == 0 {
+		return nil
+	}
+	return &progress{start: time.Now().Add(-offset), profile: profile, counts: make([]int64, len(profile))}
+}
+
+// record counts one operation; a nil progress counts nothing
+func (p *progress) record() {
+	if p == nil {
+		return
+	}
+	if i, _ := p.profile.phaseAt(time.Since(p.start)); i >= 0 {
+		atomic.AddInt64(&p.counts[i], 1)
+	}
+}
+
+func (p *progress) results() []PhaseResult {
+	if p == nil {
+		return nil
+	}
+	results := make([]PhaseResult, len(p.profile))
+	var offset float64
+	for i, phase := range p.profile {
+		seconds := phase.DurationMinutes * 60
+		results[i] = PhaseResult{
+			Shape:        phase.Shape,
+			StartSeconds: offset,
+			EndSeconds:   offset + seconds,
+			Operations:   atomic.LoadInt64(&p.counts[i]),
+			AchievedRate: float64(atomic.LoadInt64(&p.counts[i])) / seconds,
+		}
+		if p.profile.byRate() {
+			results[i].RequestedRate = phase.meanRate()
+		}
+		offset += seconds
+	}
+	return results
+}
+
+// meanRate samples the rate of the phase once a second
+func (phase Phase) meanRate() float64 {
+	seconds := int(math.Max(phase.DurationMinutes*60, 1))
+	var sum float64
+	for second := 0; second < seconds; second++ {
+		sum += phase.level(time.Duration(second)*time.Second, phase.Rate, phase.ToRate)
+	}
+	return sum / float64(seconds)
+}
+
+func (phase Phase) spikeMinutes() float64 {
+	if phase.SpikeMinutes <= 0 {
+		return 1
+	}
+	return phase.SpikeMinutes
+}
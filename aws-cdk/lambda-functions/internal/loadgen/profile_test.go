@@ -0,0 +1,84 @@
+package loadgen
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileLevels(t *testing.T) {
+	profile := Profile{
+		{Shape: ShapeRamp, DurationMinutes: 2, Rate: 100, ToRate: 500},
+		{Shape: ShapeStep, DurationMinutes: 4, Rate: 100, ToRate: 400, Steps: 4},
+		{Shape: ShapeSpike, DurationMinutes: 3, Rate: 100, ToRate: 1000},
+		{Shape: ShapeSine, DurationMinutes: 4, Rate: 100, ToRate: 300, PeriodMinutes: 2},
+		{Shape: ShapeSoak, DurationMinutes: 10, Rate: 50},
+	}
+	if err := profile.validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		at   time.Duration
+		want float64
+	}{
+		{0, 100},
+		{time.Minute, 300},                     // halfway up the ramp
+		{2*time.Minute + 30*time.Second, 100},  // first stair
+		{5*time.Minute + 30*time.Second, 400},  // last stair
+		{6*time.Minute + 30*time.Second, 100},  // before the spike
+		{7*time.Minute + 30*time.Second, 1000}, // the spike, centred
+		{9 * time.Minute, 100},                 // sine trough
+		{10 * time.Minute, 300},                // sine peak, half a period in
+		{15 * time.Minute, 50},                 // soak
+		{23 * time.Minute, 0},                  // after the profile
+	}
+	for _, tt := range tests {
+		if got := profile.rateAt(tt.at); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("At %v: expected rate %g, got %g", tt.at, tt.want, got)
+		}
+	}
+	if profile.duration() != 23*time.Minute {
+		t.Errorf("Expected a 23 minute profile, got %v", profile.duration())
+	}
+}
+
+func TestProfileThreads(t *testing.T) {
+	request := Request{Profile: Profile{
+		{Shape: ShapeStep, DurationMinutes: 2, Threads: 2, ToThreads: 8, Steps: 2},
+		{Shape: ShapeConstant, DurationMinutes: 1},
+	}}
+	request.SetDefaults()
+	if err := request.Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if request.NumThreads != 8 || request.DurationMinutes != 3 {
+		t.Errorf("Expected 8 threads for 3 minutes, got %d for %d", request.NumThreads, request.DurationMinutes)
+	}
+	if got := request.Profile.threadsAt(90 * time.Second); got != 8 {
+		t.Errorf("Expected 8 threads on the second stair, got %d", got)
+	}
+	if got := request.Profile.threadsAt(150 * time.Second); got != 0 {
+		t.Errorf("Expected no threads during the pause, got %d", got)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	tests := []struct {
+		profile Profile
+		msg     string
+	}{
+		{Profile{{Shape: "zigzag", DurationMinutes: 1, Rate: 10}}, "shape must be"},
+		{Profile{{Shape: ShapeRamp, Rate: 10, ToRate: 20}}, "durationMinutes must be positive"},
+		{Profile{{Shape: ShapeRamp, DurationMinutes: 1, Rate: 10}, {Shape: ShapeRamp, DurationMinutes: 1, Threads: 5}}, "either rates or threads"},
+		{Profile{{Shape: ShapeSpike, DurationMinutes: 1, Rate: 10, ToRate: 100, SpikeMinutes: 2}}, "spikeMinutes must not exceed"},
+		{Profile{{Shape: ShapeSine, DurationMinutes: 1}}, "needs a rate or threads"},
+	}
+	for _, tt := range tests {
+		err := tt.profile.validate()
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("Expected error containing %q, got %v", tt.msg, err)
+		}
+	}
+}
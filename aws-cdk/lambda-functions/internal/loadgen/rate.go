@@ -0,0 +1,163 @@
+package loadgen
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateResult compares the requested arrival rate with what was achieved
+type RateResult struct {
+	RequestedRate float64 `json:"requested_rate"` // scheduled operations per second
+	AchievedRate  float64 `json:"achieved_rate"`  // completed operations per second
+	Scheduled     int64   `json:"scheduled"`      // sends the schedule called for
+	Missed        int64   `json:"missed"`         // sends dropped because every worker was busy
+	Late          int64   `json:"late"`           // operations started more than late_after_ms behind schedule
+	LateAfterMs   float64 `json:"late_after_ms"`
+	MaxLagMs      float64 `json:"max_lag_ms"` // furthest an operation started behind schedule
+}
+
+// slot is one scheduled operation
+type slot struct {
+	seq      int64
+	intended time.Time
+}
+
+// lag is how far a worker's operations started behind schedule
+type lag struct {
+	late int64
+	max  time.Duration
+}
+
+// idleStep is how far the schedule skips ahead while the rate is zero
+const idleStep = 100 * time.Millisecond
+
+// schedule issues slots at rateAt(elapsed) per second until duration has
+// passed or ctx is done, then closes slots. The arrival rate doesn't depend
+// on how fast the slots are served: a slot that finds every worker busy is
+// missed rather than delaying the ones after it, and a schedule that fell
+// behind catches up.
+func schedule(ctx context.Context, rateAt func(time.Duration) float64, duration time.Duration, slots chan<- slot) (scheduled, missed int64) {
+	defer close(slots)
+
+	start := time.Now()
+	var offset time.Duration // of the next slot from start
+	for offset < duration {
+		rate := rateAt(offset)
+		if rate <= 0 {
+			offset += idleStep
+			continue
+		}
+		if !sleepUntil(ctx, start.Add(offset)) {
+			return scheduled, missed
+		}
+
+		select {
+		case slots <- slot{seq: scheduled, intended: start.Add(offset)}:
+		default:
+			missed++
+		}
+		scheduled++
+		offset += time.Duration(float64(time.Second) / rate)
+	}
+
+	// A profile may end with a pause
+	sleepUntil(ctx, start.Add(duration))
+	return scheduled, missed
+}
+
+// sleepUntil waits for t, reporting false if ctx is done first
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	if wait := time.Until(t); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// runAtRate generates open-loop load: one operation per slot, served by the
+// workers. offset is how much of the run earlier invocations did.
+func runAtRate(ctx context.Context, request Request, workers []*worker, rateAt func(time.Duration) float64, duration, offset time.Duration) Result {
+	lateAfter := time.Duration(request.LateAfterMs * float64(time.Millisecond))
+	phases := newProgress(request.Profile, offset)
+	latencies := newSeries(request.interval())
+
+	// The buffer lets a briefly busy pool absorb a burst without missing sends
+	slots := make(chan slot, len(workers))
+	results := make([]ThreadResult, len(workers))
+	lags := make([]lag, len(workers))
+
+	var wg sync.WaitGroup
+	for i, w := range workers {
+		w.phases, w.latency = phases, newRecorder(latencies)
+		wg.Add(1)
+		go func(i int, w *worker) {
+			defer wg.Done()
+			results[i], lags[i] = w.serve(ctx, slots, lateAfter)
+		}(i, w)
+	}
+
+	start := time.Now()
+	scheduled, missed := schedule(ctx, rateAt, duration, slots)
+	// Rates are over the schedule, not the drain of operations still in flight
+	elapsed := time.Since(start)
+	wg.Wait()
+
+	rate := &RateResult{
+		Scheduled:   scheduled,
+		Missed:      missed,
+		LateAfterMs: request.LateAfterMs,
+	}
+	var totalOperations int64
+	for i, result := range results {
+		totalOperations += result.OperationsCompleted
+		rate.Late += lags[i].late
+		rate.MaxLagMs = math.Max(rate.MaxLagMs, float64(lags[i].max)/float64(time.Millisecond))
+	}
+	if elapsed > 0 {
+		rate.RequestedRate = float64(scheduled) / elapsed.Seconds()
+		rate.AchievedRate = float64(totalOperations) / elapsed.Seconds()
+	}
+	log.Printf("Requested %.1f ops/sec, achieved %.1f: %d scheduled, %d missed, %d late",
+		rate.RequestedRate, rate.AchievedRate, rate.Scheduled, rate.Missed, rate.Late)
+
+	result := newResult(request, workers, results, rate)
+	result.Phases = phases.results()
+	result.LatencySeries = latencies.results(request.IncludeHistograms)
+	result.Servers = latencies.servers()
+	return result
+}
+
+// serve performs one operation of the mix per slot until slots is closed
+func (w *worker) serve(ctx context.Context, slots <-chan slot, lateAfter time.Duration) (ThreadResult, lag) {
+	startTime := time.Now()
+	var operationsCount int64
+	var behind lag
+
+	collection := w.collection()
+	for s := range slots {
+		delay := time.Since(s.intended)
+		if delay > lateAfter {
+			behind.late++
+		}
+		if delay > behind.max {
+			behind.max = delay
+		}
+
+		op := w.ops.next()
+		if err := w.perform(ctx, collection, op); err != nil {
+			w.fail(ctx, op, err)
+			continue
+		}
+		operationsCount++
+		w.phases.record()
+	}
+
+	return w.result(operationsCount, startTime), behind
+}
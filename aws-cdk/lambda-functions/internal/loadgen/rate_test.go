@@ -0,0 +1,80 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func constantRate(rate float64) func(time.Duration) float64 {
+	return func(time.Duration) float64 { return rate }
+}
+
+func TestScheduleKeepsRate(t *testing.T) {
+	slots := make(chan slot, 1)
+	received := make(chan int64)
+	go func() {
+		var count int64
+		for range slots {
+			count++
+		}
+		received <- count
+	}()
+
+	start := time.Now()
+	scheduled, missed := schedule(context.Background(), constantRate(1000), 200*time.Millisecond, slots)
+	elapsed := time.Since(start)
+
+	if scheduled != 200 {
+		t.Errorf("Expected 200 scheduled slots, got %d", scheduled)
+	}
+	if count := <-received; count+missed != scheduled {
+		t.Errorf("Expected every slot to be received or missed, got %d received and %d missed of %d", count, missed, scheduled)
+	}
+	if elapsed < 190*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected the schedule to take about 200ms, took %v", elapsed)
+	}
+}
+
+func TestScheduleMissesWhenWorkersAreBusy(t *testing.T) {
+	// Nobody serves the slots, so all but the buffered one are missed
+	slots := make(chan slot, 1)
+	scheduled, missed := schedule(context.Background(), constantRate(500), 100*time.Millisecond, slots)
+
+	if scheduled != 50 || missed != 49 {
+		t.Errorf("Expected 50 scheduled and 49 missed, got %d and %d", scheduled, missed)
+	}
+}
+
+func TestScheduleStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	slots := make(chan slot, 1000)
+	scheduled, _ := schedule(ctx, constantRate(100), time.Minute, slots)
+	if scheduled >= 100 {
+		t.Errorf("Expected the schedule to stop early, got %d slots", scheduled)
+	}
+	for range slots {
+		// Drains only because schedule closed the channel
+	}
+}
+
+func TestScheduleFollowsProfile(t *testing.T) {
+	// 100ms at 500/s, a pause, then 100ms at 1000/s
+	profile := Profile{
+		{Shape: ShapeConstant, DurationMinutes: 0.1 / 60, Rate: 500},
+		{Shape: ShapeConstant, DurationMinutes: 0.1 / 60},
+		{Shape: ShapeConstant, DurationMinutes: 0.1 / 60, Rate: 1000},
+	}
+	slots := make(chan slot, 1000)
+
+	start := time.Now()
+	scheduled, missed := schedule(context.Background(), profile.rateAt, profile.duration(), slots)
+	if elapsed := time.Since(start); elapsed < 290*time.Millisecond {
+		t.Errorf("Expected the schedule to last the whole profile, took %v", elapsed)
+	}
+	if scheduled < 148 || scheduled > 152 || missed != 0 {
+		t.Errorf("Expected about 150 scheduled and none missed, got %d and %d", scheduled, missed)
+	}
+}
@@ -0,0 +1,187 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// readOperations are the operation types a read preference routes; writes
+// always go to the writer
+var readOperations = []string{OpFind, OpRange, OpAggregate}
+
+// AllReads sets the read preference of every read operation type without
+// one of its own
+const AllReads = "read"
+
+// minMaxStaleness is the smallest maxStalenessSeconds drivers accept
+const minMaxStaleness = 90
+
+// ReadPreference routes the reads of one operation type
+type ReadPreference struct {
+	Mode                string `json:"mode"`                          // primary, primaryPreferred, secondary, secondaryPreferred or nearest
+	MaxStalenessSeconds int    `json:"maxStalenessSeconds,omitempty"` // how far behind the writer a reader may be
+}
+
+// readPref converts the preference for the driver
+func (p ReadPreference) readPref() (*readpref.ReadPref, error) {
+	mode, err := readpref.ModeFromString(p.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("mode must be primary, primaryPreferred, secondary, secondaryPreferred or nearest, got %q", p.Mode)
+	}
+	if p.MaxStalenessSeconds == 0 {
+		return readpref.New(mode)
+	}
+	if mode == readpref.PrimaryMode {
+		return nil, fmt.Errorf("maxStalenessSeconds doesn't apply to primary reads")
+	}
+	if p.MaxStalenessSeconds < minMaxStaleness {
+		return nil, fmt.Errorf("maxStalenessSeconds must be at least %d, got %d", minMaxStaleness, p.MaxStalenessSeconds)
+	}
+	return readpref.New(mode, readpref.WithMaxStaleness(time.Duration(p.MaxStalenessSeconds)*time.Second))
+}
+
+// ReadPreferences routes reads by operation type, or AllReads
+type ReadPreferences map[string]ReadPreference
+
+// validate checks the operation types and preferences
+func (r ReadPreferences) validate() error {
+	for op, preference := range r {
+		if op != AllReads && !isRead(op) {
+			return fmt.Errorf("readPreferences: %q must be %s or one of %s", op, AllReads, strings.Join(readOperations, ", "))
+		}
+		if _, err := preference.readPref(); err != nil {
+			return fmt.Errorf("readPreferences: %s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// forOperation returns the read preference of op, or nil for the client's
+func (r ReadPreferences) forOperation(op string) *readpref.ReadPref {
+	if !isRead(op) {
+		return nil
+	}
+	preference, ok := r[op]
+	if !ok {
+		if preference, ok = r[AllReads]; !ok {
+			return nil
+		}
+	}
+	rp, _ := preference.readPref() // validated
+	return rp
+}
+
+func isRead(op string) bool {
+	for _, read := range readOperations {
+		if op == read {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseReadPreferences parses op=mode[:maxStalenessSeconds] pairs separated
+// by commas, e.g. "find=secondary:120,aggregate=primary"
+func ParseReadPreferences(s string) (ReadPreferences, error) {
+	preferences := make(ReadPreferences)
+	for _, pair := range strings.Split(s, ",") {
+		op, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("readPreferences: %q is not op=mode", pair)
+		}
+		mode, staleness, _ := strings.Cut(value, ":")
+		preference := ReadPreference{Mode: mode}
+		if staleness != "" {
+			seconds, err := strconv.Atoi(staleness)
+			if err != nil {
+				return nil, fmt.Errorf("readPreferences: maxStalenessSeconds of %s must be a number, got %q", op, staleness)
+			}
+			preference.MaxStalenessSeconds = seconds
+		}
+		preferences[op] = preference
+	}
+	return preferences, preferences.validate()
+}
+
+// routed returns collection with the read preference of op
+func (w *worker) routed(collection *mongo.Collection, op string) *mongo.Collection {
+	rp := w.readPrefs.forOperation(op)
+	if rp == nil {
+		return collection
+	}
+	if w.routes == nil {
+		w.routes = make(map[string]*mongo.Collection)
+	}
+	if routed, ok := w.routes[op]; ok {
+		return routed
+	}
+	routed, err := collection.Clone(options.Collection().SetReadPreference(rp))
+	if err != nil {
+		return collection
+	}
+	w.routes[op] = routed
+	return routed
+}
+
+// ServerResult counts the commands one member served for one operation type
+type ServerResult struct {
+	Address   string `json:"address"`
+	Operation string `json:"operation"`
+	Commands  int64  `json:"commands"`
+}
+
+// servedKey is the context key of the operation a command belongs to
+type servedKey struct{}
+
+// served is the operation a command belongs to and the run it counts in
+type served struct {
+	op     string
+	series *series
+}
+
+// withOperation marks the commands run with ctx as part of op
+func (w *worker) withOperation(ctx context.Context, op string) context.Context {
+	if w.latency == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, servedKey{}, served{op: op, series: w.latency.series})
+}
+
+// CommandMonitor counts which member of the cluster served each command of
+// a run, so that Result.Servers shows whether new readers take traffic.
+// Install it with docdb.Client.SetCommandMonitor.
+var CommandMonitor = &event.CommandMonitor{
+	Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+		if s, ok := ctx.Value(servedKey{}).(served); ok {
+			s.series.serve(s.op, serverAddress(e.ConnectionID), time.Now())
+		}
+	},
+}
+
+// serverAddress strips the connection number from a connection ID, e.g.
+// host:27017[-12]
+func serverAddress(connectionID string) string {
+	if i := strings.Index(connectionID, "[-"); i >= 0 {
+		return connectionID[:i]
+	}
+	return connectionID
+}
+
+// sortServers orders server results by operation type and address
+func sortServers(results []ServerResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Operation != results[j].Operation {
+			return results[i].Operation < results[j].Operation
+		}
+		return results[i].Address < results[j].Address
+	})
+}
@@ -0,0 +1,76 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestParseReadPreferences(t *testing.T) {
+	preferences, err := ParseReadPreferences("find=secondary:120, read=nearest")
+	if err != nil {
+		t.Fatalf("Expected valid read preferences, got %v", err)
+	}
+	if want := (ReadPreference{Mode: "secondary", MaxStalenessSeconds: 120}); preferences[OpFind] != want {
+		t.Errorf("Expected %+v for find, got %+v", want, preferences[OpFind])
+	}
+
+	if rp := preferences.forOperation(OpFind); rp.Mode() != readpref.SecondaryMode {
+		t.Errorf("Expected finds to read from secondaries, got %v", rp.Mode())
+	} else if staleness, _ := rp.MaxStaleness(); staleness != 120*time.Second {
+		t.Errorf("Expected a max staleness of 120s, got %v", staleness)
+	}
+	if rp := preferences.forOperation(OpAggregate); rp.Mode() != readpref.NearestMode {
+		t.Errorf("Expected aggregations to fall back to the read preference, got %v", rp.Mode())
+	}
+	if rp := preferences.forOperation(OpInsert); rp != nil {
+		t.Errorf("Expected writes to keep the client's read preference, got %v", rp.Mode())
+	}
+}
+
+func TestParseReadPreferencesRejects(t *testing.T) {
+	for _, s := range []string{
+		"find",                // no mode
+		"find=fastest",        // unknown mode
+		"insert=secondary",    // not a read
+		"find=secondary:30",   // under the minimum staleness
+		"find=primary:120",    // staleness of primary reads
+		"find=secondary:soon", // not a number
+	} {
+		if _, err := ParseReadPreferences(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestCommandMonitorCountsServers(t *testing.T) {
+	s := newSeries(time.Second)
+	w := &worker{latency: newRecorder(s)}
+
+	ctx := w.withOperation(context.Background(), OpFind)
+	for _, id := range []string{"reader-1:27017[-3]", "reader-1:27017[-4]", "reader-2:27017[-1]"} {
+		CommandMonitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{ConnectionID: id}})
+	}
+	// Commands outside an operation, e.g. creating indexes, don't count
+	CommandMonitor.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{ConnectionID: "writer:27017[-1]"}})
+
+	servers := s.servers()
+	want := []ServerResult{
+		{Address: "reader-1:27017", Operation: OpFind, Commands: 2},
+		{Address: "reader-2:27017", Operation: OpFind, Commands: 1},
+	}
+	if len(servers) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, servers)
+	}
+	for i := range want {
+		if servers[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], servers[i])
+		}
+	}
+	if intervals := s.results(false); len(intervals) != 1 || intervals[0].Servers["reader-1:27017"] != 2 {
+		t.Errorf("Expected the interval to count 2 commands on reader-1, got %+v", intervals)
+	}
+}
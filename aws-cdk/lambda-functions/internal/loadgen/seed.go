@@ -0,0 +1,281 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"docdb-autoscaling-lambdas/internal/docdb"
+)
+
+// Actions of a request
+const (
+	ActionRun     = "run"     // seed, run and tear down a load test
+	ActionCleanup = "cleanup" // drop every test database, e.g. after crashed runs
+)
+
+// Teardowns, what happens to the test data after a run
+const (
+	TeardownKeep = "keep" // leave it for the next run
+	TeardownDrop = "drop" // drop the databases, or the collection of a template
+	TeardownTTL  = "ttl"  // expire documents TTLHours after they were written
+)
+
+// testDatabasePrefix starts the name of every database a load test writes
+const testDatabasePrefix = "test_db_"
+
+// seedBatch is how many documents a seed insert writes at once
+const seedBatch = 1000
+
+// teardownTimeout bounds the teardown, which runs even if the run was
+// cancelled
+const teardownTimeout = time.Minute
+
+// defaultDocumentBytes is the size of the synthetic documents' data
+const defaultDocumentBytes = 1000
+
+// Seed describes the documents written before the measured run, so that
+// reads find data from the start
+type Seed struct {
+	Documents     int64    `json:"documents"`               // across all threads
+	DocumentBytes int      `json:"documentBytes,omitempty"` // data in each synthetic document, also for the run's inserts
+	Indexes       []string `json:"indexes,omitempty"`       // more indexes, e.g. "timestamp" or "thread_id,hits:-1"
+}
+
+// SeedResult describes the seed phase
+type SeedResult struct {
+	Documents int64    `json:"documents"`
+	Indexes   []string `json:"indexes,omitempty"`
+	Seconds   float64  `json:"seconds"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// TeardownResult describes the teardown after the run
+type TeardownResult struct {
+	Action     string   `json:"action"`
+	Namespaces []string `json:"namespaces"` // databases or database.collection
+	Error      string   `json:"error,omitempty"`
+}
+
+// CleanupResult lists the databases a cleanup dropped
+type CleanupResult struct {
+	Dropped []string `json:"dropped_databases"`
+}
+
+// validate checks the seed
+func (s *Seed) validate() error {
+	if s.Documents < 0 || s.DocumentBytes < 0 {
+		return fmt.Errorf("seed: documents and documentBytes must not be negative")
+	}
+	for _, spec := range s.Indexes {
+		if _, err := parseIndex(spec); err != nil {
+			return fmt.Errorf("seed: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseIndex parses an index spec of fields separated by commas, each
+// ascending or with :-1 descending, e.g. "thread_id,hits:-1"
+func parseIndex(spec string) (bson.D, error) {
+	var keys bson.D
+	for _, field := range strings.Split(spec, ",") {
+		name, direction, hasDirection := strings.Cut(strings.TrimSpace(field), ":")
+		order := 1
+		if hasDirection {
+			var err error
+			if order, err = strconv.Atoi(direction); err != nil || (order != 1 && order != -1) {
+				return nil, fmt.Errorf("index %q: direction of %s must be 1 or -1", spec, name)
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("index %q: empty field", spec)
+		}
+		keys = append(keys, bson.E{Key: name, Value: order})
+	}
+	return keys, nil
+}
+
+// seedIndexes returns the indexes the seed adds
+func (r *Request) seedIndexes() []mongo.IndexModel {
+	if r.Seed == nil {
+		return nil
+	}
+	indexes := make([]mongo.IndexModel, 0, len(r.Seed.Indexes))
+	for _, spec := range r.Seed.Indexes {
+		keys, _ := parseIndex(spec) // validated
+		indexes = append(indexes, mongo.IndexModel{Keys: keys})
+	}
+	return indexes
+}
+
+// documentBytes returns the size of the synthetic documents' data
+func (r *Request) documentBytes() int {
+	if r.Seed != nil && r.Seed.DocumentBytes > 0 {
+		return r.Seed.DocumentBytes
+	}
+	return defaultDocumentBytes
+}
+
+// share is how many of the seed documents thread writes
+func (s *Seed) share(thread, threads int) int64 {
+	n := s.Documents / int64(threads)
+	if int64(thread) < s.Documents%int64(threads) {
+		n++
+	}
+	return n
+}
+
+// prepare creates a worker per thread. Each creates the workload's indexes
+// and writes its share of the seed documents, unless the run continues from
+// a checkpoint.
+func prepare(ctx context.Context, client *docdb.Client, request Request, from *checkpoint) ([]*worker, *SeedResult) {
+	seed := request.Seed
+	if from != nil {
+		seed = nil
+	}
+	start := time.Now()
+	workers := make([]*worker, request.NumThreads)
+	seeded := make([]int64, request.NumThreads)
+	errs := make([]error, request.NumThreads)
+	extra := request.seedIndexes()
+
+	for i := range workers {
+		workers[i] = newWorker(request.FirstThread+i, client, request)
+	}
+	resume(workers, from)
+
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func(i int, w *worker) {
+			defer wg.Done()
+			collection := w.collection()
+			w.ensureIndexes(ctx, collection, extra)
+			if seed != nil {
+				seeded[i], errs[i] = w.seed(ctx, collection, seed.share(i, len(workers)))
+			}
+		}(i, workers[i])
+	}
+	wg.Wait()
+
+	if seed == nil {
+		return workers, nil
+	}
+	result := &SeedResult{Indexes: seed.Indexes, Seconds: time.Since(start).Seconds()}
+	for i := range workers {
+		result.Documents += seeded[i]
+		if errs[i] != nil && result.Error == "" {
+			result.Error = truncate(errs[i].Error())
+		}
+	}
+	log.Printf("Seeded %d documents in %.1f seconds", result.Documents, result.Seconds)
+	if result.Error != "" {
+		log.Printf("Warning: seeding failed: %s", result.Error)
+	}
+	return workers, result
+}
+
+// seed writes n documents in batches, returning how many it wrote
+func (w *worker) seed(ctx context.Context, collection *mongo.Collection, n int64) (int64, error) {
+	if w.workload.seed != nil {
+		return w.workload.seed(ctx, w, collection, n)
+	}
+	if w.workload.document == nil {
+		return 0, nil
+	}
+
+	var written int64
+	for written < n {
+		batch := make([]interface{}, min(n-written, seedBatch))
+		for i := range batch {
+			batch[i] = w.workload.document(w)
+			w.inserted++
+		}
+		result, err := collection.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		if result != nil {
+			written += int64(len(result.InsertedIDs))
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// teardown drops or expires the data the workers wrote
+func teardown(ctx context.Context, request Request, workers []*worker) *TeardownResult {
+	if request.Teardown == "" || request.Teardown == TeardownKeep {
+		return nil
+	}
+	timeout := teardownTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline)-deadlineMargin/4)
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	result := &TeardownResult{Action: request.Teardown, Namespaces: []string{}}
+	seen := make(map[string]bool)
+	for _, w := range workers {
+		collection := w.collection()
+		namespace := collection.Database().Name()
+		if w.workload.database != "" || request.Teardown == TeardownTTL {
+			namespace += "." + collection.Name()
+		}
+		if seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+
+		var err error
+		switch {
+		case request.Teardown == TeardownTTL:
+			_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "createdAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(int32(request.TTLHours * 3600)),
+			})
+		case w.workload.database == "":
+			err = collection.Database().Drop(ctx)
+		default:
+			err = collection.Drop(ctx)
+		}
+		if err != nil {
+			result.Error = fmt.Sprintf("%s: %v", namespace, err)
+			log.Printf("Warning: teardown of %s failed: %v", namespace, err)
+			continue
+		}
+		result.Namespaces = append(result.Namespaces, namespace)
+	}
+	log.Printf("Teardown (%s) of %d namespaces", result.Action, len(result.Namespaces))
+	return result
+}
+
+// Cleanup drops every database a load test writes, including those of runs
+// still in progress
+func Cleanup(ctx context.Context, client *docdb.Client) (CleanupResult, error) {
+	result := CleanupResult{Dropped: []string{}}
+	names, err := client.GetClient().ListDatabaseNames(ctx, bson.D{})
+	if err != nil {
+		return result, fmt.Errorf("failed to list databases: %w", err)
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, testDatabasePrefix) {
+			continue
+		}
+		if err := client.Database(name).Drop(ctx); err != nil {
+			return result, fmt.Errorf("failed to drop %s: %w", name, err)
+		}
+		log.Printf("Dropped database %s", name)
+		result.Dropped = append(result.Dropped, name)
+	}
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package loadgen
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseIndex(t *testing.T) {
+	keys, err := parseIndex("thread_id, hits:-1")
+	if err != nil {
+		t.Fatalf("Expected a valid index, got %v", err)
+	}
+	want := bson.D{{Key: "thread_id", Value: 1}, {Key: "hits", Value: -1}}
+	if len(keys) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, keys)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], keys[i])
+		}
+	}
+
+	for _, spec := range []string{"", "hits:2", "hits:desc", "thread_id,"} {
+		if _, err := parseIndex(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestSeedShare(t *testing.T) {
+	seed := Seed{Documents: 10}
+	var total int64
+	for thread := 0; thread < 3; thread++ {
+		total += seed.share(thread, 3)
+	}
+	if total != 10 {
+		t.Errorf("Expected the shares to total 10 documents, got %d", total)
+	}
+	if seed.share(0, 3) != 4 || seed.share(2, 3) != 3 {
+		t.Errorf("Expected shares of 4, 3 and 3, got %d and %d", seed.share(0, 3), seed.share(2, 3))
+	}
+}
+
+func TestValidateSeedAndTeardown(t *testing.T) {
+	for name, request := range map[string]Request{
+		"negative documents": {Seed: &Seed{Documents: -1}},
+		"bad index":          {Seed: &Seed{Indexes: []string{"hits:up"}}},
+		"unknown teardown":   {Teardown: "archive"},
+		"unknown action":     {Action: "seed"},
+	} {
+		request.SetDefaults()
+		if err := request.Validate(); err == nil {
+			t.Errorf("Expected a request with %s to be rejected", name)
+		}
+	}
+
+	// A cleanup ignores the run's parameters
+	request := Request{Action: ActionCleanup, OperationType: "sideways"}
+	request.SetDefaults()
+	if err := request.Validate(); err != nil {
+		t.Errorf("Expected a cleanup to be valid, got %v", err)
+	}
+}
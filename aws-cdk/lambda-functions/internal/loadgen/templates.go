@@ -0,0 +1,361 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// templateDatabase holds the collections of the workload templates, which
+// every thread shares like the back-end's services do
+const templateDatabase = "test_db_templates"
+
+// Workload templates, modelled on back-end/models and the services that
+// query them
+const (
+	WorkloadUsers     = "users"
+	WorkloadPoints    = "points"
+	WorkloadRoles     = "roles"
+	WorkloadBadWords  = "badwords"
+	WorkloadHolograms = "holograms"
+)
+
+// templates are the workloads a request can name
+var templates = map[string]*workload{
+	WorkloadUsers:     usersWorkload,
+	WorkloadPoints:    pointsWorkload,
+	WorkloadRoles:     rolesWorkload,
+	WorkloadBadWords:  badWordsWorkload,
+	WorkloadHolograms: hologramsWorkload,
+}
+
+// templateNames lists the workload templates, sorted
+func templateNames() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pageSize is how many documents the back-end's list queries return
+const pageSize = 20
+
+var (
+	roleNames     = []string{"admin", "moderator", "creator", "viewer"}
+	pointsReasons = []string{"video upload", "daily login", "referral", "moderation report"}
+	languages     = []string{"en-US", "en-GB", "es-ES", "es-US", "fr-FR", "de-DE", "it-IT", "pt-BR", "ja-JP", "ko-KR", "zh-CN", "hi-IN"}
+)
+
+// key names the worker's nth document of a kind, e.g. user-k3x9a2-42. The
+// worker's id keeps keys unique across threads and runs.
+func (w *worker) key(kind string, n int64) string {
+	return fmt.Sprintf("%s-%s-%d", kind, w.id, n)
+}
+
+// someKey names one of the documents the worker inserted
+func (w *worker) someKey(kind string) string {
+	return w.key(kind, w.someNumber())
+}
+
+// someUser names a user with about four of the worker's awards or
+// holograms, so that lists by user aren't empty
+func (w *worker) someUser() string {
+	return w.key("user", w.rand.Int63n(w.inserted/4+1))
+}
+
+// pick returns a random element of values
+func (w *worker) pick(values []string) string {
+	return values[w.rand.Intn(len(values))]
+}
+
+// timestamps are the createdAt and updatedAt fields Mongoose adds
+func timestamps(doc bson.M) bson.M {
+	now := time.Now().UTC()
+	doc["createdAt"] = now
+	doc["updatedAt"] = now
+	doc["__v"] = 0
+	return doc
+}
+
+func newestFirst(limit int64) *options.FindOptions {
+	return options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit)
+}
+
+// usersWorkload looks users up by username and lists the active ones, like
+// sandbox-data.service
+var usersWorkload = &workload{
+	database:   templateDatabase,
+	collection: "users",
+	mix:        Mix{OpFind: 70, OpRange: 10, OpInsert: 10, OpUpdate: 10},
+	indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "isActive", Value: 1}, {Key: "createdAt", Value: -1}}},
+	},
+	document: func(w *worker) bson.M {
+		username := w.key("user", w.inserted)
+		return timestamps(bson.M{
+			"username": username,
+			"email":    username + "@example.com",
+			"roles":    bson.A{w.pick(roleNames)},
+			"isActive": true,
+		})
+	},
+	ops: map[string]operation{
+		OpFind: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			return ignoreNoDocuments(c.FindOne(ctx, bson.M{"username": w.someKey("user")}).Err())
+		},
+		// getUsers
+		OpRange: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			cursor, err := c.Find(ctx, bson.M{"isActive": true}, newestFirst(pageSize))
+			return drain(ctx, cursor, err)
+		},
+		OpInsert: insertDocument,
+		// updateUser
+		OpUpdate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.UpdateOne(ctx, bson.M{"username": w.someKey("user")}, bson.M{
+				"$set": bson.M{"roles": bson.A{w.pick(roleNames)}, "updatedAt": time.Now().UTC()},
+			})
+			return err
+		},
+		OpDelete: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.DeleteOne(ctx, bson.M{"username": w.someKey("user")})
+			return err
+		},
+		// Active users per role
+		OpAggregate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"isActive": true}}},
+				{{Key: "$unwind", Value: "$roles"}},
+				{{Key: "$group", Value: bson.M{"_id": "$roles", "users": bson.M{"$sum": 1}}}},
+			})
+			return drain(ctx, cursor, err)
+		},
+	},
+}
+
+// pointsWorkload awards points to users and reads their history and the
+// leaderboard
+var pointsWorkload = &workload{
+	database:   templateDatabase,
+	collection: "points",
+	mix:        Mix{OpInsert: 50, OpRange: 20, OpFind: 10, OpUpdate: 10, OpAggregate: 10},
+	indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	},
+	// An award to a user, who may already have some
+	document: func(w *worker) bson.M {
+		return timestamps(bson.M{
+			"userId": w.someUser(),
+			"points": 1 + w.rand.Intn(100),
+			"reason": w.pick(pointsReasons),
+		})
+	},
+	ops: map[string]operation{
+		// The user's latest award
+		OpFind: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+			return ignoreNoDocuments(c.FindOne(ctx, bson.M{"userId": w.someUser()}, opts).Err())
+		},
+		// The user's history
+		OpRange: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			cursor, err := c.Find(ctx, bson.M{"userId": w.someUser()}, newestFirst(pageSize))
+			return drain(ctx, cursor, err)
+		},
+		OpInsert: insertDocument,
+		OpUpdate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.UpdateOne(ctx, bson.M{"userId": w.someUser()}, bson.M{
+				"$inc": bson.M{"points": 1 + w.rand.Intn(10)},
+				"$set": bson.M{"updatedAt": time.Now().UTC()},
+			})
+			return err
+		},
+		OpDelete: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.DeleteOne(ctx, bson.M{"userId": w.someUser()})
+			return err
+		},
+		// The leaderboard: the ten users with the most points
+		OpAggregate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{"_id": "$userId", "points": bson.M{"$sum": "$points"}}}},
+				{{Key: "$sort", Value: bson.M{"points": -1}}},
+				{{Key: "$limit", Value: 10}},
+			})
+			return drain(ctx, cursor, err)
+		},
+	},
+}
+
+// rolesWorkload is a small collection read far more often than it changes
+var rolesWorkload = &workload{
+	database:   templateDatabase,
+	collection: "roles",
+	mix:        Mix{OpFind: 90, OpRange: 5, OpInsert: 3, OpUpdate: 2},
+	indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	document: func(w *worker) bson.M {
+		name := w.key("role", w.inserted)
+		return timestamps(bson.M{
+			"name":        name,
+			"description": "Load test role " + name,
+			"permissions": bson.A{"videos:read", "holograms:read"},
+		})
+	},
+	ops: map[string]operation{
+		OpFind: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			return ignoreNoDocuments(c.FindOne(ctx, bson.M{"name": w.someKey("role")}).Err())
+		},
+		OpRange: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}}).SetLimit(rangeLimit)
+			cursor, err := c.Find(ctx, bson.M{}, opts)
+			return drain(ctx, cursor, err)
+		},
+		OpInsert: insertDocument,
+		OpUpdate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.UpdateOne(ctx, bson.M{"name": w.someKey("role")}, bson.M{
+				"$addToSet": bson.M{"permissions": fmt.Sprintf("permission:%d", w.rand.Intn(20))},
+				"$set":      bson.M{"updatedAt": time.Now().UTC()},
+			})
+			return err
+		},
+		OpDelete: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.DeleteOne(ctx, bson.M{"name": w.someKey("role")})
+			return err
+		},
+	},
+}
+
+// swearWords is the size of each language's list
+const swearWords = 500
+
+// badWordsWorkload looks lists up by language and saves them, like
+// badwords.service
+var badWordsWorkload = &workload{
+	database:   templateDatabase,
+	collection: "badwords",
+	mix:        Mix{OpFind: 95, OpUpdate: 5},
+	indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "language", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	// One list per language, however many documents were asked for
+	seed: func(ctx context.Context, w *worker, c *mongo.Collection, n int64) (int64, error) {
+		if w.threadID != 0 {
+			return 0, nil
+		}
+		for i, language := range languages {
+			if err := w.upsertBadWords(ctx, c, language); err != nil {
+				return int64(i), err
+			}
+		}
+		return int64(len(languages)), nil
+	},
+	ops: map[string]operation{
+		// checkTranscription: the language's list, falling back to en-US
+		OpFind: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			err := c.FindOne(ctx, bson.M{"language": w.pick(languages)}).Err()
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				err = c.FindOne(ctx, bson.M{"language": "en-US"}).Err()
+			}
+			return ignoreNoDocuments(err)
+		},
+		OpRange: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			cursor, err := c.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"language": 1}))
+			return drain(ctx, cursor, err)
+		},
+		// createOrUpdateBadWordsForLanguage
+		OpUpdate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			return w.upsertBadWords(ctx, c, w.pick(languages))
+		},
+		// The size of every list
+		OpAggregate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$project", Value: bson.M{"language": 1, "words": bson.M{"$size": "$swearWords"}}}},
+			})
+			return drain(ctx, cursor, err)
+		},
+	},
+}
+
+// upsertBadWords replaces the list of a language with random words
+func (w *worker) upsertBadWords(ctx context.Context, c *mongo.Collection, language string) error {
+	words := make(bson.A, swearWords)
+	for i := range words {
+		words[i] = fmt.Sprintf("word%d", w.rand.Intn(10*swearWords))
+	}
+	now := time.Now().UTC()
+	_, err := c.UpdateOne(ctx, bson.M{"language": language}, bson.M{
+		"$set":         bson.M{"swearWords": words, "updatedAt": now},
+		"$setOnInsert": bson.M{"createdAt": now, "__v": 0},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+// hologramsWorkload creates holograms and lists a user's active ones, like
+// sandbox-data.service
+var hologramsWorkload = &workload{
+	database:   templateDatabase,
+	collection: "holograms",
+	mix:        Mix{OpRange: 60, OpInsert: 20, OpFind: 10, OpUpdate: 10},
+	indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "isActive", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "name", Value: 1}}},
+	},
+	// createHologram, for a user who may already have some
+	document: func(w *worker) bson.M {
+		name := w.key("hologram", w.inserted)
+		return timestamps(bson.M{
+			"name":         name,
+			"url":          "https://cdn.example.com/holograms/" + name + ".glb",
+			"thumbnailUrl": "https://cdn.example.com/holograms/" + name + ".jpg",
+			"userId":       w.someUser(),
+			"isActive":     true,
+			"metadata": bson.M{
+				"format":          "glb",
+				"sizeBytes":       1_000_000 + w.rand.Intn(50_000_000),
+				"durationSeconds": 5 + w.rand.Intn(60),
+			},
+		})
+	},
+	ops: map[string]operation{
+		OpFind: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			return ignoreNoDocuments(c.FindOne(ctx, bson.M{"name": w.someKey("hologram")}).Err())
+		},
+		// getHologramsByUser
+		OpRange: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			filter := bson.M{"userId": w.someUser(), "isActive": true}
+			cursor, err := c.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+			return drain(ctx, cursor, err)
+		},
+		OpInsert: insertDocument,
+		// Deactivates a hologram
+		OpUpdate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.UpdateOne(ctx, bson.M{"name": w.someKey("hologram")}, bson.M{
+				"$set": bson.M{"isActive": false, "updatedAt": time.Now().UTC()},
+			})
+			return err
+		},
+		OpDelete: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			_, err := c.DeleteOne(ctx, bson.M{"name": w.someKey("hologram")})
+			return err
+		},
+		// The users with the most active holograms
+		OpAggregate: func(ctx context.Context, w *worker, c *mongo.Collection) error {
+			cursor, err := c.Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"isActive": true}}},
+				{{Key: "$group", Value: bson.M{"_id": "$userId", "holograms": bson.M{"$sum": 1}}}},
+				{{Key: "$sort", Value: bson.M{"holograms": -1}}},
+				{{Key: "$limit", Value: 10}},
+			})
+			return drain(ctx, cursor, err)
+		},
+	},
+}
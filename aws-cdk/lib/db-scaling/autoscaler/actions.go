@@ -0,0 +1,165 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/docdb"
+
+	"docdb-auto-scaling/internal/apierror"
+	"docdb-auto-scaling/internal/lock"
+)
+
+// errLockLost is returned by apply when the lease can no longer be confirmed
+var errLockLost = errors.New("scaling lock lost")
+
+// apply executes the plan's pending actions in order, checking the lease's
+// fencing token before each one. It stops at the first failure.
+func (a *Autoscaler) apply(ctx context.Context, cfg *Config, plan *ReconcilePlan, lease *lock.Lease) error {
+	for _, action := range plan.Pending() {
+		if err := a.lock.Validate(ctx, lease); err != nil {
+			return fmt.Errorf("%w: %v", errLockLost, err)
+		}
+
+		var err error
+		switch action.Type {
+		case ActionCreate:
+			err = a.create(ctx, cfg, action, plan.Desired.InstanceClasses)
+		case ActionDelete:
+			err = a.delete(ctx, cfg, action)
+		case ActionModify:
+			err = a.modify(ctx, action)
+		}
+		a.telemetry.recordAction(cfg.ClusterIdentifier, action, err)
+		if err != nil {
+			return err
+		}
+		a.recordHistory(ctx, cfg, action)
+	}
+
+	for _, action := range plan.Actions {
+		if action.Deferred {
+			a.telemetry.recordAction(cfg.ClusterIdentifier, action, nil)
+			log.Printf("Deferred %s of %s to a later run: %s", action.Type, action.Identifier, action.DeferredReason)
+		}
+	}
+	return nil
+}
+
+// create adds a reader, trying each allowed class while capacity is short
+func (a *Autoscaler) create(ctx context.Context, cfg *Config, action Action, candidateClasses []string) error {
+	log.Printf("Creating read replica %s in %s", action.Identifier, cfg.ClusterIdentifier)
+
+	var err error
+	for i, candidateClass := range candidateClasses {
+		err = a.createReader(ctx, cfg, action, candidateClass)
+
+		switch apierror.Classify(err) {
+		case apierror.ClassNone:
+			log.Printf("Successfully initiated creation of read replica: %s (%s)", action.Identifier, candidateClass)
+			return nil
+		case apierror.ClassAlreadyExists:
+			// An earlier invocation for this window already created it
+			log.Printf("Read replica %s already exists, treating create as done", action.Identifier)
+			return nil
+		case apierror.ClassInsufficientCapacity:
+			if i+1 < len(candidateClasses) {
+				log.Printf("Insufficient capacity for %s, falling back to %s", candidateClass, candidateClasses[i+1])
+				continue
+			}
+			log.Printf("Insufficient capacity for all instance classes: %v", candidateClasses)
+		case apierror.ClassInvalidClusterState:
+			log.Printf("Cluster %s is not in a state that allows adding readers, deferring scale-out", cfg.ClusterIdentifier)
+		case apierror.ClassQuotaExceeded:
+			log.Printf("Instance quota exceeded for cluster %s, a service quota increase is required", cfg.ClusterIdentifier)
+		}
+		break
+	}
+
+	return fmt.Errorf("failed to create read replica: %w", err)
+}
+
+func (a *Autoscaler) createReader(ctx context.Context, cfg *Config, action Action, class string) error {
+	createInput := &docdb.CreateDBInstanceInput{
+		DBInstanceIdentifier: aws.String(action.Identifier),
+		DBClusterIdentifier:  aws.String(cfg.ClusterIdentifier),
+		DBInstanceClass:      aws.String(class),
+		Engine:               aws.String("docdb"),
+		Tags:                 docdbTags(action.Tags),
+	}
+	if action.AvailabilityZone != "" {
+		createInput.AvailabilityZone = aws.String(action.AvailabilityZone)
+	}
+
+	return apierror.Retry(ctx, apierror.DefaultBackoff, "CreateDBInstance", func() error {
+		_, err := a.docdb.CreateDBInstanceWithContext(ctx, createInput)
+		return err
+	})
+}
+
+// delete removes a reader the plan selected
+func (a *Autoscaler) delete(ctx context.Context, cfg *Config, action Action) error {
+	log.Printf("Deleting instance: %s", action.Identifier)
+	deleteInput := &docdb.DeleteDBInstanceInput{
+		DBInstanceIdentifier: aws.String(action.Identifier),
+	}
+	err := apierror.Retry(ctx, apierror.DefaultBackoff, "DeleteDBInstance", func() error {
+		_, err := a.docdb.DeleteDBInstanceWithContext(ctx, deleteInput)
+		return err
+	})
+	if err != nil {
+		if apierror.Classify(err) == apierror.ClassInvalidClusterState {
+			log.Printf("Cluster %s is not in a state that allows removing readers, deferring scale-in", cfg.ClusterIdentifier)
+		}
+		return fmt.Errorf("failed to delete instance %s: %w", action.Identifier, err)
+	}
+
+	log.Printf("Successfully initiated deletion of instance %s", action.Identifier)
+	return nil
+}
+
+// modify changes a reader's instance class and adds missing tags
+func (a *Autoscaler) modify(ctx context.Context, action Action) error {
+	if action.InstanceClass != "" {
+		log.Printf("Modifying instance %s to %s", action.Identifier, action.InstanceClass)
+		modifyInput := &docdb.ModifyDBInstanceInput{
+			DBInstanceIdentifier: aws.String(action.Identifier),
+			DBInstanceClass:      aws.String(action.InstanceClass),
+			ApplyImmediately:     aws.Bool(true),
+		}
+		err := apierror.Retry(ctx, apierror.DefaultBackoff, "ModifyDBInstance", func() error {
+			_, err := a.docdb.ModifyDBInstanceWithContext(ctx, modifyInput)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to modify instance %s: %w", action.Identifier, err)
+		}
+	}
+
+	if len(action.Tags) > 0 {
+		log.Printf("Tagging instance %s with %s", action.Identifier, formatTags(action.Tags))
+		tagInput := &docdb.AddTagsToResourceInput{
+			ResourceName: aws.String(action.Arn),
+			Tags:         docdbTags(action.Tags),
+		}
+		err := apierror.Retry(ctx, apierror.DefaultBackoff, "AddTagsToResource", func() error {
+			_, err := a.docdb.AddTagsToResourceWithContext(ctx, tagInput)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to tag instance %s: %w", action.Identifier, err)
+		}
+	}
+	return nil
+}
+
+func docdbTags(tags map[string]string) []*docdb.Tag {
+	var result []*docdb.Tag
+	for key, value := range tags {
+		result = append(result, &docdb.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return result
+}
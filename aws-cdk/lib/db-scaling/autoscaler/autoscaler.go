@@ -0,0 +1,339 @@
+// Package autoscaler decides and executes read replica scaling for a
+// DocumentDB cluster. It backs the Lambda function and daemon in this module
+// and the docdbctl CLI in lambda-functions.
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/docdb"
+	"github.com/aws/aws-sdk-go/service/docdb/docdbiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+
+	"docdb-auto-scaling/internal/apierror"
+	"docdb-auto-scaling/internal/clusterarn"
+	"docdb-auto-scaling/internal/history"
+	"docdb-auto-scaling/internal/lock"
+	"docdb-auto-scaling/internal/naming"
+	"docdb-auto-scaling/internal/policy"
+	"docdb-auto-scaling/internal/pricing"
+	"docdb-auto-scaling/internal/profile"
+	"docdb-auto-scaling/openmetrics"
+)
+
+// SchedulerEvent is the input sent by the EventBridge schedule
+type SchedulerEvent struct {
+	Source            string `json:"source"`
+	Environment       string `json:"environment"`
+	ClusterIdentifier string `json:"clusterIdentifier"`
+	ScheduledTime     string `json:"scheduledTime,omitempty"`
+}
+
+// Response is returned by the Lambda handler
+type Response struct {
+	StatusCode    int    `json:"statusCode"`
+	Body          string `json:"body"`
+	ErrorClass    string `json:"errorClass,omitempty"`
+	PolicyVersion string `json:"policyVersion,omitempty"`
+	Cost          *Cost  `json:"cost,omitempty"`
+}
+
+// Evaluation is the outcome of one autoscaler run
+type Evaluation struct {
+	StartedAt   time.Time        `json:"startedAt"`
+	Response    Response         `json:"response"`
+	Readers     int              `json:"readers"`
+	ClusterInfo *ClusterInfo     `json:"clusterInfo,omitempty"`
+	Metrics     *Metrics         `json:"metrics,omitempty"`
+	Decision    *ScalingDecision `json:"decision,omitempty"`
+	Plan        *ReconcilePlan   `json:"plan,omitempty"`
+}
+
+// Autoscaler scales the read replicas of one cluster
+type Autoscaler struct {
+	cluster    string
+	docdb      docdbiface.DocDBAPI
+	cloudwatch cloudwatchiface.CloudWatchAPI
+	arns       *clusterarn.Cache
+	sns        snsiface.SNSAPI
+	policies   *policy.Store
+	lock       lock.Locker
+	history    history.Recorder
+	prices     *pricing.Table // nil if costs are not tracked
+	template   *naming.Template
+	telemetry  *telemetry
+}
+
+// New creates an autoscaler for a validated configuration. API calls made
+// through sess are recorded in the autoscaler's metrics registry.
+func New(cfg *Config, sess *session.Session) (*Autoscaler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	t := newTelemetry()
+	sess = sess.Copy()
+	t.instrument(&sess.Handlers)
+
+	a := &Autoscaler{
+		cluster:    cfg.ClusterIdentifier,
+		docdb:      docdb.New(sess),
+		cloudwatch: cloudwatch.New(sess),
+		sns:        sns.New(sess),
+		telemetry:  t,
+	}
+
+	// Already validated by cfg.Validate
+	a.template, _ = naming.Parse(cfg.ReaderNameTemplate)
+	a.arns = clusterarn.NewCache(a.docdb)
+
+	var provider policy.Provider
+	if cfg.PolicySource != "" {
+		var err error
+		provider, err = policy.NewProvider(cfg.PolicySource, sess)
+		if err != nil {
+			return nil, fmt.Errorf("invalid POLICY_SOURCE: %w", err)
+		}
+	}
+	prices, err := pricing.Load(cfg.PriceTable, cfg.PriceSnapshot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PRICE_TABLE: %w", err)
+	}
+	a.prices = prices
+
+	a.policies = policy.NewStore(provider, cfg, time.Duration(cfg.PolicyCacheSeconds)*time.Second)
+
+	switch cfg.LockBackend {
+	case "tag":
+		a.lock = lock.NewClusterTag(a.docdb)
+	case "memory":
+		a.lock = lock.NewMemory()
+	default:
+		a.lock = lock.Noop{}
+	}
+
+	switch cfg.HistoryBackend {
+	case "tag":
+		a.history = history.NewClusterTag(a.docdb)
+	case "memory":
+		a.history = history.NewMemory()
+	default:
+		a.history = history.Noop{}
+	}
+
+	return a, nil
+}
+
+// Registry returns the metrics recorded by this autoscaler
+func (a *Autoscaler) Registry() *openmetrics.Registry {
+	return a.telemetry.registry
+}
+
+// Plan gathers the cluster state and metrics and returns the decision and
+// reconcile plan that Evaluate would act on, without taking the lock or
+// changing the cluster
+func (a *Autoscaler) Plan(ctx context.Context, event SchedulerEvent) Evaluation {
+	cfg, policyVersion, profileName := a.configFor(ctx, event)
+	ev, _ := a.decide(ctx, cfg, policyVersion, profileName, event)
+	return ev
+}
+
+// Evaluate runs one reconcile cycle: decide, plan the difference between the
+// desired and actual readers, and apply the plan within the action budget.
+// The cluster lock is held for the whole run so that overlapping invocations
+// can't double-scale.
+func (a *Autoscaler) Evaluate(ctx context.Context, event SchedulerEvent) (ev Evaluation) {
+	log.Printf("Processing scheduler event for cluster: %s", event.ClusterIdentifier)
+
+	// Thresholds come from the policy document when one is configured
+	cfg, policyVersion, profileName := a.configFor(ctx, event)
+	log.Printf("Using policy version %s, profile %s", policyVersion, profileName)
+
+	lease, err := a.lock.Acquire(ctx, cfg.ClusterIdentifier, lockOwner(ctx), time.Duration(cfg.LockTTLSeconds)*time.Second)
+	if errors.Is(err, lock.ErrLocked) {
+		log.Printf("Skipping: scaling lock for %s is held by another invocation", cfg.ClusterIdentifier)
+		ev.StartedAt = time.Now()
+		ev.Response = Response{StatusCode: 200, Body: "Scaling decision: skipped (lock held)"}
+		return ev
+	}
+	if err != nil {
+		log.Printf("Error acquiring scaling lock: %v", err)
+		ev.StartedAt = time.Now()
+		ev.Response = Response{StatusCode: 500, Body: fmt.Sprintf("Error: %v", err)}
+		return ev
+	}
+	log.Printf("Acquired scaling lock %s", lease)
+	defer func() {
+		if err := a.lock.Release(context.Background(), lease); err != nil {
+			log.Printf("Warning: failed to release scaling lock: %v", err)
+		}
+	}()
+
+	ev, ok := a.decide(ctx, cfg, policyVersion, profileName, event)
+	if !ok {
+		return ev
+	}
+	if len(ev.Plan.RateLimited) > 0 {
+		a.alertRateLimited(ctx, cfg, ev.Plan.RateLimited)
+	}
+	if len(ev.Plan.Pending()) == 0 {
+		return ev
+	}
+	decision := *ev.Decision
+
+	if err := a.apply(ctx, cfg, ev.Plan, lease); err != nil {
+		if errors.Is(err, errLockLost) {
+			log.Printf("Aborting plan, %v", err)
+			ev.Response = Response{StatusCode: 409, Body: fmt.Sprintf("Error: %v", err), PolicyVersion: decision.PolicyVersion}
+			return ev
+		}
+		errorClass := apierror.Classify(err)
+		log.Printf("Error executing scaling action (%s): %v", errorClass, err)
+		ev.Response = Response{
+			StatusCode:    errorClass.StatusCode(),
+			Body:          fmt.Sprintf("Error: %v", err),
+			ErrorClass:    string(errorClass),
+			PolicyVersion: decision.PolicyVersion,
+		}
+		return ev
+	}
+	log.Printf("Successfully executed %d of %d planned actions", len(ev.Plan.Pending()), len(ev.Plan.Actions))
+
+	return ev
+}
+
+// decide gathers cluster state and metrics and makes the scaling decision.
+// It returns false if the run failed before a decision could be made.
+func (a *Autoscaler) decide(ctx context.Context, cfg *Config, policyVersion, profileName string, event SchedulerEvent) (Evaluation, bool) {
+	ev := Evaluation{StartedAt: time.Now()}
+
+	// Get current cluster information
+	clusterInfo, err := a.getClusterInfo(ctx, cfg)
+	if err != nil {
+		log.Printf("Error getting cluster info: %v", err)
+		ev.Response = Response{StatusCode: 500, Body: fmt.Sprintf("Error: %v", err)}
+		return ev, false
+	}
+	ev.ClusterInfo = clusterInfo
+	ev.Readers = clusterInfo.ReaderCount
+	a.telemetry.recordCluster(cfg.ClusterIdentifier, clusterInfo)
+
+	// Get current metrics
+	metrics, err := a.getCurrentMetrics(ctx, cfg)
+	if err != nil {
+		log.Printf("Error getting metrics: %v", err)
+		ev.Response = Response{StatusCode: 500, Body: fmt.Sprintf("Error: %v", err)}
+		return ev, false
+	}
+	ev.Metrics = metrics
+	a.telemetry.recordMetrics(cfg.ClusterIdentifier, metrics)
+
+	// Make scaling decision, unless an operator paused autoscaling
+	var decision ScalingDecision
+	paused := false
+	if pausedUntil, err := a.pausedUntil(ctx, clusterInfo.ClusterArn); err != nil {
+		log.Printf("Warning: failed to check pause state: %v", err)
+		decision = Decide(cfg, clusterInfo, metrics)
+	} else if time.Now().Before(pausedUntil) {
+		// The reason labels the decisions counter, so the time goes to its own gauge
+		paused = true
+		log.Printf("Autoscaling paused until %s", pausedUntil.Format(time.RFC3339))
+		a.telemetry.recordPause(cfg.ClusterIdentifier, pausedUntil)
+		decision = ScalingDecision{Action: ActionNone, Reason: "Autoscaling paused"}
+	} else {
+		a.telemetry.recordPause(cfg.ClusterIdentifier, time.Time{})
+		decision = Decide(cfg, clusterInfo, metrics)
+	}
+	decision.Window = decisionWindowFor(cfg, event)
+	decision.PolicyVersion = policyVersion
+	decision.Profile = profileName
+	ev.Decision = &decision
+	a.telemetry.recordDecision(cfg.ClusterIdentifier, decision)
+	log.Printf("Scaling decision: %s - %s (window %s, policy %s, profile %s)",
+		decision.Action, decision.Reason, decision.Window.Format(time.RFC3339), decision.PolicyVersion, decision.Profile)
+
+	// Plan the changes that reach the desired state
+	plan, err := Diff(cfg, Desired(cfg, clusterInfo, decision), clusterInfo, decision.Window, time.Now())
+	if err != nil {
+		log.Printf("Error planning scaling actions: %v", err)
+		ev.Response = Response{StatusCode: 500, Body: fmt.Sprintf("Error: %v", err), PolicyVersion: decision.PolicyVersion}
+		return ev, false
+	}
+	if paused {
+		plan.deferAll("paused")
+		plan.Notes = append(plan.Notes, decision.Reason)
+	} else if err := a.enforceRateLimits(ctx, cfg, plan, time.Now()); err != nil {
+		log.Printf("Error reading scaling history: %v", err)
+		ev.Response = Response{StatusCode: 500, Body: fmt.Sprintf("Error: %v", err), PolicyVersion: decision.PolicyVersion}
+		return ev, false
+	}
+	if a.prices != nil {
+		cost := ApplyCostCeiling(cfg, a.prices, plan, clusterInfo)
+		a.telemetry.recordCost(cfg.ClusterIdentifier, cost)
+	}
+	ev.Plan = plan
+	log.Printf("Reconcile plan:\n%s", plan)
+
+	ev.Response = Response{
+		StatusCode:    200,
+		Body:          fmt.Sprintf("Scaling decision: %s", decision.Action),
+		PolicyVersion: decision.PolicyVersion,
+		Cost:          plan.Cost,
+	}
+	return ev, true
+}
+
+// configFor returns the configuration for an invocation: the current policy
+// over the environment, with the defaults of the environment's profile. The
+// event's environment takes precedence over ENVIRONMENT. If the profile
+// doesn't fit the configuration, for example because MIN_READ_REPLICAS is
+// above its maximum, the profile is ignored.
+func (a *Autoscaler) configFor(ctx context.Context, event SchedulerEvent) (*Config, string, string) {
+	cfg, policyVersion := a.policies.Get(ctx)
+
+	environment := cfg.Environment
+	if event.Environment != "" {
+		environment = event.Environment
+	}
+	p := profile.Select(cfg.Profile, environment)
+	withProfile, err := p.Apply(cfg)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return cfg, policyVersion, "none"
+	}
+	return withProfile, policyVersion, p.Name
+}
+
+// lockOwner identifies this invocation as the holder of the scaling lock
+func lockOwner(ctx context.Context) string {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		return lc.AwsRequestID
+	}
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// decisionWindowFor returns the decision window of an invocation. The
+// scheduled time is preferred over the clock so that a retried invocation
+// lands in the same window as the original one.
+func decisionWindowFor(cfg *Config, event SchedulerEvent) time.Time {
+	decisionTime := time.Now()
+	if event.ScheduledTime != "" {
+		if scheduledTime, err := time.Parse(time.RFC3339, event.ScheduledTime); err == nil {
+			decisionTime = scheduledTime
+		} else {
+			log.Printf("Warning: ignoring invalid scheduledTime %q: %v", event.ScheduledTime, err)
+		}
+	}
+	return naming.Window(decisionTime, time.Duration(cfg.DecisionWindowMinutes)*time.Minute)
+}
@@ -0,0 +1,291 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/docdb"
+)
+
+// ClusterInfo is the current membership of the cluster
+type ClusterInfo struct {
+	ClusterArn        string           `json:"clusterArn"`
+	AvailabilityZones []string         `json:"availabilityZones"`
+	ReaderCount       int              `json:"readerCount"`
+	WriterCount       int              `json:"writerCount"`
+	WriterClass       string           `json:"writerClass,omitempty"`
+	ReaderInstances   []ReaderInstance `json:"readerInstances"`
+	PendingReaders    []ReaderInstance `json:"pendingReaders,omitempty"` // readers still being created
+	ReaderStatuses    map[string]int   `json:"readerStatuses"`           // every described reader, including pending ones
+}
+
+// ReaderInstance is a reader of the cluster
+type ReaderInstance struct {
+	Identifier       string            `json:"identifier"`
+	Arn              string            `json:"arn"`
+	CreateTime       time.Time         `json:"createTime"`
+	Status           string            `json:"status"`
+	InstanceClass    string            `json:"instanceClass"`
+	AvailabilityZone string            `json:"availabilityZone"`
+	Tags             map[string]string `json:"tags,omitempty"` // only read when READER_TAGS is set
+}
+
+// megabyte converts FreeableMemory to FREEABLE_MEMORY_THRESHOLD_MB's unit
+const megabyte = 1024 * 1024
+
+// Metrics are the CloudWatch averages a decision is based on, and the
+// series behind them for rules
+type Metrics struct {
+	WriterCPU         float64   `json:"writerCpu"`
+	ReaderCPU         float64   `json:"readerCpu"`
+	WriterConnections float64   `json:"writerConnections"`
+	ReplicaLag        float64   `json:"replicaLag"`     // milliseconds, slowest reader
+	FreeableMemory    float64   `json:"freeableMemory"` // writer bytes, 0 if unknown
+	Timestamp         time.Time `json:"timestamp"`
+
+	// Missing names the metrics above that had no datapoints, e.g. the
+	// readers' with no readers. Their zero values are not measurements.
+	Missing []string `json:"missing,omitempty"`
+
+	// Series are the one-minute values the rules policy reads, oldest first.
+	// Nil unless DECISION_POLICY=rules.
+	Series map[string][]float64 `json:"series,omitempty"`
+}
+
+// HasData reports whether the metric with the given JSON name had datapoints
+func (m *Metrics) HasData(metric string) bool {
+	for _, name := range m.Missing {
+		if name == metric {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Autoscaler) getClusterInfo(ctx context.Context, cfg *Config) (*ClusterInfo, error) {
+	input := &docdb.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(cfg.ClusterIdentifier),
+	}
+	result, err := a.docdb.DescribeDBClustersWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cluster: %w", err)
+	}
+	if len(result.DBClusters) == 0 {
+		return nil, fmt.Errorf("cluster %s not found", cfg.ClusterIdentifier)
+	}
+
+	cluster := result.DBClusters[0]
+	info := &ClusterInfo{
+		ClusterArn:        aws.StringValue(cluster.DBClusterArn),
+		AvailabilityZones: aws.StringValueSlice(cluster.AvailabilityZones),
+		ReaderStatuses:    make(map[string]int),
+	}
+
+	for _, member := range cluster.DBClusterMembers {
+		if member.IsClusterWriter != nil && *member.IsClusterWriter {
+			info.WriterCount++
+			// The writer's class is only needed to price the cluster
+			writerResult, err := a.docdb.DescribeDBInstancesWithContext(ctx, &docdb.DescribeDBInstancesInput{
+				DBInstanceIdentifier: member.DBInstanceIdentifier,
+			})
+			if err != nil {
+				log.Printf("Warning: Failed to describe writer %s: %v", *member.DBInstanceIdentifier, err)
+			} else if len(writerResult.DBInstances) > 0 {
+				info.WriterClass = aws.StringValue(writerResult.DBInstances[0].DBInstanceClass)
+			}
+		} else {
+			// Get detailed instance information
+			instanceInput := &docdb.DescribeDBInstancesInput{
+				DBInstanceIdentifier: member.DBInstanceIdentifier,
+			}
+			instanceResult, err := a.docdb.DescribeDBInstancesWithContext(ctx, instanceInput)
+			if err != nil {
+				log.Printf("Warning: Failed to describe instance %s: %v", *member.DBInstanceIdentifier, err)
+				continue
+			}
+			if len(instanceResult.DBInstances) > 0 {
+				instance := instanceResult.DBInstances[0]
+				info.ReaderStatuses[aws.StringValue(instance.DBInstanceStatus)]++
+				reader := ReaderInstance{
+					Identifier:       aws.StringValue(instance.DBInstanceIdentifier),
+					Arn:              aws.StringValue(instance.DBInstanceArn),
+					Status:           aws.StringValue(instance.DBInstanceStatus),
+					InstanceClass:    aws.StringValue(instance.DBInstanceClass),
+					AvailabilityZone: aws.StringValue(instance.AvailabilityZone),
+				}
+				if len(cfg.ReaderTags) > 0 {
+					reader.Tags, err = a.instanceTags(ctx, reader.Arn)
+					if err != nil {
+						log.Printf("Warning: Failed to list tags of instance %s: %v", reader.Identifier, err)
+					}
+				}
+				if instance.InstanceCreateTime != nil {
+					reader.CreateTime = *instance.InstanceCreateTime
+					info.ReaderInstances = append(info.ReaderInstances, reader)
+					info.ReaderCount++
+				} else {
+					info.PendingReaders = append(info.PendingReaders, reader)
+				}
+			}
+		}
+	}
+
+	log.Printf("Current cluster state: %d writers, %d readers", info.WriterCount, info.ReaderCount)
+	return info, nil
+}
+
+// instanceTags returns the tags of a reader; DescribeDBInstances doesn't include them
+func (a *Autoscaler) instanceTags(ctx context.Context, arn string) (map[string]string, error) {
+	result, err := a.docdb.ListTagsForResourceWithContext(ctx, &docdb.ListTagsForResourceInput{
+		ResourceName: aws.String(arn),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(result.TagList))
+	for _, tag := range result.TagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+func (a *Autoscaler) getCurrentMetrics(ctx context.Context, cfg *Config) (*Metrics, error) {
+	endTime := time.Now()
+	startTime := endTime.Add(-time.Duration(cfg.EvaluationPeriods) * time.Minute)
+
+	var missing []string
+	found := func(name string, ok bool) {
+		if !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	// Get Writer CPU utilization
+	writerCPU, ok, err := a.getMetricValue(ctx, cfg, "CPUUtilization", "WRITER", startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get writer CPU metric: %w", err)
+	}
+	found("writerCpu", ok)
+
+	// Get Reader CPU utilization (average across all readers)
+	readerCPU, ok, err := a.getMetricValue(ctx, cfg, "CPUUtilization", "READER", startTime, endTime)
+	if err != nil {
+		log.Printf("Warning: Failed to get reader CPU metric: %v", err)
+	}
+	found("readerCpu", ok) // no data if no readers exist
+
+	// Get Writer connections
+	writerConnections, ok, err := a.getMetricValue(ctx, cfg, "DatabaseConnections", "WRITER", startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get writer connections metric: %w", err)
+	}
+	found("writerConnections", ok)
+
+	// Replica lag and freeable memory only feed the score policy
+	replicaLag, ok, err := a.getMetricValue(ctx, cfg, "DBClusterReplicaLagMaximum", "", startTime, endTime)
+	if err != nil {
+		log.Printf("Warning: Failed to get replica lag metric: %v", err)
+	}
+	found("replicaLag", ok)
+	freeableMemory, ok, err := a.getMetricValue(ctx, cfg, "FreeableMemory", "WRITER", startTime, endTime)
+	if err != nil {
+		log.Printf("Warning: Failed to get freeable memory metric: %v", err)
+	}
+	found("freeableMemory", ok)
+
+	metrics := &Metrics{
+		WriterCPU:         writerCPU,
+		ReaderCPU:         readerCPU,
+		WriterConnections: writerConnections,
+		ReplicaLag:        replicaLag,
+		FreeableMemory:    freeableMemory,
+		Timestamp:         endTime,
+		Missing:           missing,
+	}
+
+	if cfg.DecisionPolicy == "rules" {
+		metrics.Series = a.getRuleSeries(ctx, cfg, endTime)
+	}
+
+	log.Printf("Current metrics - Writer CPU: %.1f%%, Reader CPU: %.1f%%, Writer Connections: %.0f, Replica Lag: %.0fms, Freeable Memory: %.0fMB",
+		metrics.WriterCPU, metrics.ReaderCPU, metrics.WriterConnections, metrics.ReplicaLag, metrics.FreeableMemory/megabyte)
+
+	return metrics, nil
+}
+
+// getMetricValue averages a metric over the evaluation period. It reports
+// false if there were no datapoints.
+func (a *Autoscaler) getMetricValue(ctx context.Context, cfg *Config, metricName, role string, startTime, endTime time.Time) (float64, bool, error) {
+	values, err := a.getMetricSeries(ctx, cfg, metricName, role, "Average", startTime, endTime)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(values) == 0 {
+		return 0, false, nil // No data available
+	}
+
+	// Calculate average of the evaluation period
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+
+	return sum / float64(len(values)), true, nil
+}
+
+// getMetricSeries returns the one-minute values of statistic, oldest first
+func (a *Autoscaler) getMetricSeries(ctx context.Context, cfg *Config, metricName, role, statistic string, startTime, endTime time.Time) ([]float64, error) {
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/DocDB"),
+		MetricName: aws.String(metricName),
+		Dimensions: []*cloudwatch.Dimension{
+			{
+				Name:  aws.String("DBClusterIdentifier"),
+				Value: aws.String(cfg.ClusterIdentifier),
+			},
+		},
+		StartTime:  aws.Time(startTime),
+		EndTime:    aws.Time(endTime),
+		Period:     aws.Int64(60), // 1 minute periods
+		Statistics: []*string{aws.String(statistic)},
+	}
+
+	// Cluster-wide metrics have no role
+	if role != "" {
+		input.Dimensions = append(input.Dimensions, &cloudwatch.Dimension{Name: aws.String("Role"), Value: aws.String(role)})
+	}
+
+	result, err := a.cloudwatch.GetMetricStatisticsWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// CloudWatch returns datapoints in no particular order
+	sort.Slice(result.Datapoints, func(i, j int) bool {
+		return aws.TimeValue(result.Datapoints[i].Timestamp).Before(aws.TimeValue(result.Datapoints[j].Timestamp))
+	})
+	values := make([]float64, 0, len(result.Datapoints))
+	for _, datapoint := range result.Datapoints {
+		var value *float64
+		switch statistic {
+		case "Maximum":
+			value = datapoint.Maximum
+		case "Minimum":
+			value = datapoint.Minimum
+		case "Sum":
+			value = datapoint.Sum
+		default:
+			value = datapoint.Average
+		}
+		if value != nil {
+			values = append(values, *value)
+		}
+	}
+	return values, nil
+}
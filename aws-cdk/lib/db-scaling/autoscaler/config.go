@@ -0,0 +1,58 @@
+package autoscaler
+
+import (
+	"docdb-auto-scaling/internal/config"
+	"docdb-auto-scaling/internal/policy"
+	"docdb-auto-scaling/internal/pricing"
+	"docdb-auto-scaling/internal/profile"
+)
+
+// Config is the autoscaler configuration, see the internal config package
+type Config = config.Config
+
+// Rule is a named condition of the rules decision policy
+type Rule = config.Rule
+
+// PolicyDocument is a versioned scaling policy, see the internal policy package
+type PolicyDocument = policy.Document
+
+// PriceTable holds hourly instance prices, see the internal pricing package
+type PriceTable = pricing.Table
+
+// LoadPrices builds a price table from a JSON file, the built-in snapshot or
+// both, as PRICE_TABLE and PRICE_SNAPSHOT do. It returns nil if neither is used.
+func LoadPrices(path string, snapshot bool) (*PriceTable, error) {
+	return pricing.Load(path, snapshot)
+}
+
+// ProfileFor returns the name of the scaling profile of an environment:
+// prod, staging or ephemeral for branch environments
+func ProfileFor(environment string) string {
+	return profile.Select("", environment).Name
+}
+
+// LoadConfig loads and validates the configuration from the environment
+func LoadConfig() (*Config, error) {
+	return config.FromEnv()
+}
+
+// LoadConfigFile loads and validates the configuration from a KEY=VALUE env file
+func LoadConfigFile(path string) (*Config, error) {
+	return config.FromEnvFile(path)
+}
+
+// ParsePolicy decodes a JSON or YAML policy document
+func ParsePolicy(data []byte) (*PolicyDocument, error) {
+	return policy.Parse(data)
+}
+
+// DefaultConfig returns the default configuration for a cluster, ignoring
+// the environment. It is the base for validating policies offline.
+func DefaultConfig(clusterIdentifier string) (*Config, error) {
+	return config.Load(func(key string) (string, bool) {
+		if key == "CLUSTER_IDENTIFIER" {
+			return clusterIdentifier, true
+		}
+		return "", false
+	})
+}
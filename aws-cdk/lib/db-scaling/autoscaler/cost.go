@@ -0,0 +1,108 @@
+package autoscaler
+
+import (
+	"fmt"
+	"sort"
+
+	"docdb-auto-scaling/internal/pricing"
+)
+
+// Cost is the hourly on-demand cost of the cluster's instances
+type Cost struct {
+	Currency  string   `json:"currency"`
+	Current   float64  `json:"current"`
+	Projected float64  `json:"projected"` // after the pending actions
+	Delta     float64  `json:"delta"`
+	Budget    float64  `json:"budget,omitempty"`
+	Unpriced  []string `json:"unpriced,omitempty"` // classes missing from the price table, counted as free
+}
+
+func (c *Cost) String() string {
+	s := fmt.Sprintf("%.3f -> %.3f %s/h (%+.3f)", c.Current, c.Projected, c.Currency, c.Delta)
+	if c.Budget > 0 {
+		s += fmt.Sprintf(", budget %.3f", c.Budget)
+	}
+	if len(c.Unpriced) > 0 {
+		s += fmt.Sprintf(", no price for %v", c.Unpriced)
+	}
+	return s
+}
+
+// ApplyCostCeiling prices the plan's actions and defers the ones that would
+// take the cluster above cfg.HourlyBudget, then re-applies the action budget
+// and returns the current and projected cost. A create is priced at the most
+// expensive of the allowed classes, since capacity fallbacks may pick any of
+// them. With a budget set, creates and modifies to an unpriced class are
+// deferred too.
+func ApplyCostCeiling(cfg *Config, prices *pricing.Table, plan *ReconcilePlan, info *ClusterInfo) *Cost {
+	unpriced := make(map[string]bool)
+	price := func(class string) float64 {
+		p, ok := prices.Price(class)
+		if !ok && class != "" {
+			unpriced[class] = true
+		}
+		return p
+	}
+
+	cost := &Cost{Currency: prices.Currency, Budget: cfg.HourlyBudget}
+	current := make(map[string]string)
+	cost.Current = price(info.WriterClass) * float64(info.WriterCount)
+	for _, reader := range activeReaders(info) {
+		current[reader.Identifier] = reader.InstanceClass
+		cost.Current += price(reader.InstanceClass)
+	}
+
+	projected := cost.Current
+	for i := range plan.Actions {
+		action := &plan.Actions[i]
+
+		known := true
+		switch action.Type {
+		case ActionCreate:
+			for _, class := range plan.Desired.InstanceClasses {
+				if _, ok := prices.Price(class); !ok {
+					known = false
+				}
+				action.CostDelta = max(action.CostDelta, price(class))
+			}
+		case ActionDelete:
+			action.CostDelta = -price(current[action.Identifier])
+		case ActionModify:
+			if action.InstanceClass != "" {
+				_, known = prices.Price(action.InstanceClass)
+				action.CostDelta = price(action.InstanceClass) - price(current[action.Identifier])
+			}
+		}
+
+		if action.Deferred && action.DeferredReason != reasonBudget {
+			continue
+		}
+		if cfg.HourlyBudget > 0 {
+			switch {
+			case !known:
+				action.Deferred, action.DeferredReason = true, "cost ceiling: instance class has no price"
+				continue
+			case action.CostDelta > 0 && projected+action.CostDelta > cfg.HourlyBudget:
+				action.Deferred = true
+				action.DeferredReason = fmt.Sprintf("cost ceiling: %.3f %s/h would exceed the budget of %.3f",
+					projected+action.CostDelta, cost.Currency, cfg.HourlyBudget)
+				continue
+			}
+		}
+		projected += action.CostDelta
+	}
+
+	plan.applyBudget(cfg.MaxActionsPerRun)
+
+	cost.Projected = cost.Current
+	for _, action := range plan.Pending() {
+		cost.Projected += action.CostDelta
+	}
+	cost.Delta = cost.Projected - cost.Current
+	for class := range unpriced {
+		cost.Unpriced = append(cost.Unpriced, class)
+	}
+	sort.Strings(cost.Unpriced)
+	plan.Cost = cost
+	return cost
+}
@@ -0,0 +1,81 @@
+package autoscaler
+
+import (
+	"log"
+	"time"
+)
+
+// Scaling actions
+const (
+	ActionScaleOut = "scale_out"
+	ActionScaleIn  = "scale_in"
+	ActionNone     = "none"
+)
+
+// ScalingDecision is the action chosen for one run
+type ScalingDecision struct {
+	Action        string    `json:"action"` // "scale_out", "scale_in", "none"
+	Reason        string    `json:"reason"`
+	Threshold     float64   `json:"threshold"`
+	Current       float64   `json:"current"`
+	Window        time.Time `json:"window"` // start of the decision window, used for idempotent naming
+	PolicyVersion string    `json:"policyVersion"`
+	Profile       string    `json:"profile"`
+	Score         *Score    `json:"score,omitempty"` // set by the score policy
+	Rule          string    `json:"rule,omitempty"`  // set by the rules policy
+}
+
+// Decide applies the thresholds in cfg, or the pressure score or rules that
+// DECISION_POLICY selects, to the cluster state and metrics. It makes no API
+// calls, so it can be used to simulate a policy offline.
+func Decide(cfg *Config, clusterInfo *ClusterInfo, metrics *Metrics) ScalingDecision {
+	switch cfg.DecisionPolicy {
+	case "score":
+		return decideScore(cfg, clusterInfo, metrics)
+	case "rules":
+		return decideRules(cfg, clusterInfo, metrics)
+	}
+
+	// Check for scale out conditions
+	if metrics.WriterCPU >= cfg.CPUScaleOutThreshold {
+		if clusterInfo.ReaderCount < cfg.MaxReadReplicas {
+			return ScalingDecision{
+				Action:    ActionScaleOut,
+				Reason:    "Writer CPU utilization high",
+				Threshold: cfg.CPUScaleOutThreshold,
+				Current:   metrics.WriterCPU,
+			}
+		} else {
+			log.Printf("Scale out needed but already at max replicas (%d)", cfg.MaxReadReplicas)
+		}
+	}
+
+	if metrics.WriterConnections >= cfg.ConnectionsScaleOutThreshold {
+		if clusterInfo.ReaderCount < cfg.MaxReadReplicas {
+			return ScalingDecision{
+				Action:    ActionScaleOut,
+				Reason:    "Writer connections high",
+				Threshold: cfg.ConnectionsScaleOutThreshold,
+				Current:   metrics.WriterConnections,
+			}
+		} else {
+			log.Printf("Scale out needed but already at max replicas (%d)", cfg.MaxReadReplicas)
+		}
+	}
+
+	// Check for scale in conditions
+	if metrics.ReaderCPU <= cfg.CPUScaleInThreshold && metrics.WriterCPU <= cfg.CPUScaleInThreshold {
+		if clusterInfo.ReaderCount > cfg.MinReadReplicas {
+			return ScalingDecision{
+				Action:    ActionScaleIn,
+				Reason:    "CPU utilization low on both writer and readers",
+				Threshold: cfg.CPUScaleInThreshold,
+				Current:   metrics.ReaderCPU,
+			}
+		} else {
+			log.Printf("Scale in conditions met but already at min replicas (%d)", cfg.MinReadReplicas)
+		}
+	}
+
+	return ScalingDecision{Action: ActionNone, Reason: "No scaling conditions met"}
+}
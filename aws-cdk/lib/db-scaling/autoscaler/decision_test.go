@@ -0,0 +1,135 @@
+package autoscaler
+
+import (
+	"testing"
+
+	"docdb-auto-scaling/internal/rules"
+)
+
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg, err := DefaultConfig("test-cluster")
+	if err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+	return cfg
+}
+
+func TestDecide(t *testing.T) {
+	cfg := testConfig(t)
+
+	tests := []struct {
+		name    string
+		readers int
+		metrics Metrics
+		action  string
+	}{
+		{"writer cpu high", 2, Metrics{WriterCPU: 85, ReaderCPU: 50}, ActionScaleOut},
+		{"connections high", 2, Metrics{WriterCPU: 40, ReaderCPU: 50, WriterConnections: 500}, ActionScaleOut},
+		{"at max replicas", cfg.MaxReadReplicas, Metrics{WriterCPU: 85}, ActionNone},
+		{"cpu low", 3, Metrics{WriterCPU: 10, ReaderCPU: 10}, ActionScaleIn},
+		{"at min replicas", cfg.MinReadReplicas, Metrics{WriterCPU: 10, ReaderCPU: 10}, ActionNone},
+		{"steady", 3, Metrics{WriterCPU: 50, ReaderCPU: 50}, ActionNone},
+	}
+
+	for _, tt := range tests {
+		decision := Decide(cfg, &ClusterInfo{ReaderCount: tt.readers}, &tt.metrics)
+		if decision.Action != tt.action {
+			t.Errorf("%s: expected action %s, got %s (%s)", tt.name, tt.action, decision.Action, decision.Reason)
+		}
+	}
+}
+
+func TestDecideScore(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DecisionPolicy = "score"
+
+	tests := []struct {
+		name    string
+		readers int
+		metrics Metrics
+		action  string
+	}{
+		// No metric crosses its own threshold, but together they do
+		{"combined pressure", 2, Metrics{WriterCPU: 65, ReaderCPU: 65, WriterConnections: 380, ReplicaLag: 2500, FreeableMemory: 400 * megabyte}, ActionScaleOut},
+		{"at max replicas", cfg.MaxReadReplicas, Metrics{WriterCPU: 90, ReaderCPU: 90, WriterConnections: 500, ReplicaLag: 3000}, ActionNone},
+		{"quiet", 3, Metrics{WriterCPU: 10, ReaderCPU: 10, WriterConnections: 40}, ActionScaleIn},
+		{"steady", 3, Metrics{WriterCPU: 50, ReaderCPU: 50, WriterConnections: 200, ReplicaLag: 500}, ActionNone},
+		// Without readers there is no reader CPU or lag, which must not dilute the writer's
+		{"no readers, hot writer", 0, Metrics{WriterCPU: 100, WriterConnections: 400, Missing: []string{"readerCpu", "replicaLag", "freeableMemory"}}, ActionScaleOut},
+	}
+
+	for _, tt := range tests {
+		decision := Decide(cfg, &ClusterInfo{ReaderCount: tt.readers}, &tt.metrics)
+		if decision.Action != tt.action {
+			t.Errorf("%s: expected action %s, got %s (%s)", tt.name, tt.action, decision.Action, decision.Reason)
+		}
+		if decision.Score == nil {
+			t.Errorf("%s: expected a score", tt.name)
+		}
+	}
+}
+
+func TestPressureScore(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ScoreWeights = map[string]float64{"writer_cpu": 1, "freeable_memory": 1}
+
+	score := PressureScore(cfg, &Metrics{WriterCPU: cfg.CPUScaleOutThreshold, FreeableMemory: cfg.FreeableMemoryThreshold * megabyte / 2})
+	if len(score.Signals) != 2 {
+		t.Fatalf("Expected 2 weighted signals, got %d", len(score.Signals))
+	}
+	if score.Value != 1.5 {
+		t.Errorf("Expected score 1.5, got %g", score.Value)
+	}
+	if top, _ := score.Top(); top.Name != "freeable_memory" {
+		t.Errorf("Expected freeable_memory to lead, got %s", top.Name)
+	}
+
+	// Unknown freeable memory is left out, not counted as no pressure
+	score = PressureScore(cfg, &Metrics{WriterCPU: cfg.CPUScaleOutThreshold})
+	if len(score.Signals) != 1 || score.Value != 1 {
+		t.Errorf("Expected only writer_cpu at 1.0, got %d signals at %g", len(score.Signals), score.Value)
+	}
+}
+
+func TestDecideRules(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DecisionPolicy = "rules"
+	cfg.Rules = []Rule{
+		{Name: "writer-hot", Action: ActionScaleOut, When: "writer.cpu.p90 > 75 && readers.lag.max < 2000"},
+		{Name: "connections", Action: ActionScaleOut, When: "connections.writer > 400 for 3m"},
+		{Name: "quiet", Action: ActionScaleIn, When: "writer.cpu.max < 30 && readers.cpu.max < 30"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid rules, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		readers int
+		metrics Metrics
+		action  string
+		rule    string
+	}{
+		{"writer hot", 2, Metrics{Series: map[string][]float64{"writer.cpu": {70, 80, 90}, "readers.lag": {100, 100, 100}}}, ActionScaleOut, "writer-hot"},
+		{"connections held", 2, Metrics{Series: map[string][]float64{"writer.cpu": {50, 50, 50}, "readers.lag": {0, 0, 0}, "connections.writer": {380, 410, 420, 450}}}, ActionScaleOut, "connections"},
+		{"connections dipped", 2, Metrics{Series: map[string][]float64{"writer.cpu": {50, 50, 50}, "readers.lag": {0, 0, 0}, "connections.writer": {450, 390, 420, 450}, "readers.cpu": {40, 40, 40}}}, ActionNone, ""},
+		{"quiet", 3, Metrics{Series: map[string][]float64{"writer.cpu": {10, 20, 15}, "readers.cpu": {5, 5, 5}}}, ActionScaleIn, "quiet"},
+		{"offline steady metrics", 2, Metrics{WriterCPU: 80}, ActionScaleOut, "writer-hot"},
+	}
+
+	for _, tt := range tests {
+		decision := Decide(cfg, &ClusterInfo{ReaderCount: tt.readers}, &tt.metrics)
+		if decision.Action != tt.action || decision.Rule != tt.rule {
+			t.Errorf("%s: expected %s by rule %q, got %s by rule %q (%s)", tt.name, tt.action, tt.rule, decision.Action, decision.Rule, decision.Reason)
+		}
+	}
+}
+
+func TestRuleSeriesHaveSources(t *testing.T) {
+	for name := range rules.Series {
+		if _, ok := ruleSeries[name]; !ok {
+			t.Errorf("Expected a CloudWatch source for rule series %s", name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/docdb"
+)
+
+// PauseTag holds the RFC 3339 time until which autoscaling is paused. It is
+// kept on the cluster so every autoscaler instance sees the same state.
+const PauseTag = "autoscaler:paused-until"
+
+// Pause stops scaling actions on the cluster until the given time. Runs
+// still gather metrics and record a "none" decision while paused.
+func (a *Autoscaler) Pause(ctx context.Context, until time.Time) error {
+	arn, err := a.arns.Get(ctx, a.cluster)
+	if err != nil {
+		return err
+	}
+	_, err = a.docdb.AddTagsToResourceWithContext(ctx, &docdb.AddTagsToResourceInput{
+		ResourceName: aws.String(arn),
+		Tags:         []*docdb.Tag{{Key: aws.String(PauseTag), Value: aws.String(until.UTC().Format(time.RFC3339))}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to pause autoscaling: %w", err)
+	}
+	log.Printf("Paused autoscaling for %s until %s", a.cluster, until.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// Resume clears a pause set by Pause
+func (a *Autoscaler) Resume(ctx context.Context) error {
+	arn, err := a.arns.Get(ctx, a.cluster)
+	if err != nil {
+		return err
+	}
+	_, err = a.docdb.RemoveTagsFromResourceWithContext(ctx, &docdb.RemoveTagsFromResourceInput{
+		ResourceName: aws.String(arn),
+		TagKeys:      []*string{aws.String(PauseTag)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resume autoscaling: %w", err)
+	}
+	log.Printf("Resumed autoscaling for %s", a.cluster)
+	return nil
+}
+
+// PausedUntil returns the end of the current pause, or the zero time if the
+// cluster isn't paused
+func (a *Autoscaler) PausedUntil(ctx context.Context) (time.Time, error) {
+	arn, err := a.arns.Get(ctx, a.cluster)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return a.pausedUntil(ctx, arn)
+}
+
+func (a *Autoscaler) pausedUntil(ctx context.Context, arn string) (time.Time, error) {
+	result, err := a.docdb.ListTagsForResourceWithContext(ctx, &docdb.ListTagsForResourceInput{
+		ResourceName: aws.String(arn),
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to list cluster tags: %w", err)
+	}
+	for _, tag := range result.TagList {
+		if aws.StringValue(tag.Key) != PauseTag {
+			continue
+		}
+		until, err := time.Parse(time.RFC3339, aws.StringValue(tag.Value))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s tag %q: %w", PauseTag, aws.StringValue(tag.Value), err)
+		}
+		return until, nil
+	}
+	return time.Time{}, nil
+}
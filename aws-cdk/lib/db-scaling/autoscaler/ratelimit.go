@@ -0,0 +1,177 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+
+	"docdb-auto-scaling/internal/history"
+)
+
+// alertKind is the history kind that throttles rate-limit alerts to one per hour
+const alertKind = "alert"
+
+// RateLimit caps how many actions of one type may run per period
+type RateLimit struct {
+	Action string
+	Period time.Duration
+	Max    int
+}
+
+// LimitHit is a rate limit that deferred at least one planned action
+type LimitHit struct {
+	Action string `json:"action"`
+	Period string `json:"period"`
+	Max    int    `json:"max"`
+	Count  int    `json:"count"` // actions already taken in the period
+}
+
+func (h LimitHit) String() string {
+	return fmt.Sprintf("%d %ss in the last %s (max %d)", h.Count, h.Action, h.Period, h.Max)
+}
+
+// RateLimits returns the configured limits; a maximum of 0 means no limit
+func RateLimits(cfg *Config) []RateLimit {
+	var limits []RateLimit
+	for _, limit := range []RateLimit{
+		{ActionCreate, time.Hour, cfg.MaxCreatesPerHour},
+		{ActionCreate, 24 * time.Hour, cfg.MaxCreatesPerDay},
+		{ActionDelete, time.Hour, cfg.MaxDeletesPerHour},
+		{ActionDelete, 24 * time.Hour, cfg.MaxDeletesPerDay},
+	} {
+		if limit.Max > 0 {
+			limits = append(limits, limit)
+		}
+	}
+	return limits
+}
+
+// ApplyRateLimits defers the planned actions that would exceed a limit,
+// given the times of past actions by type, and then re-applies the action
+// budget to the actions that remain. Deferred actions are planned again by
+// later runs once the period has moved on.
+func ApplyRateLimits(cfg *Config, plan *ReconcilePlan, past map[string][]time.Time, now time.Time) {
+	limits := RateLimits(cfg)
+	counts := make([]int, len(limits))
+	for i, limit := range limits {
+		for _, t := range past[limit.Action] {
+			if t.After(now.Add(-limit.Period)) {
+				counts[i]++
+			}
+		}
+	}
+
+	hit := make(map[int]bool)
+	for i := range plan.Actions {
+		action := &plan.Actions[i]
+		if action.Deferred && action.DeferredReason != reasonBudget {
+			continue
+		}
+
+		limited := false
+		for j, limit := range limits {
+			if limit.Action != action.Type || counts[j] < limit.Max {
+				continue
+			}
+			h := LimitHit{Action: limit.Action, Period: periodName(limit.Period), Max: limit.Max, Count: counts[j]}
+			action.Deferred, action.DeferredReason = true, "rate limit: "+h.String()
+			if !hit[j] {
+				hit[j] = true
+				plan.RateLimited = append(plan.RateLimited, h)
+			}
+			limited = true
+			break
+		}
+		if limited {
+			continue
+		}
+		for j, limit := range limits {
+			if limit.Action == action.Type {
+				counts[j]++
+			}
+		}
+	}
+
+	plan.applyBudget(cfg.MaxActionsPerRun)
+}
+
+func periodName(period time.Duration) string {
+	if period == 24*time.Hour {
+		return "day"
+	}
+	return "hour"
+}
+
+// enforceRateLimits reads the cluster's action history and applies the limits to plan
+func (a *Autoscaler) enforceRateLimits(ctx context.Context, cfg *Config, plan *ReconcilePlan, now time.Time) error {
+	if len(RateLimits(cfg)) == 0 {
+		return nil
+	}
+
+	past := make(map[string][]time.Time)
+	for _, kind := range []string{ActionCreate, ActionDelete} {
+		times, err := a.history.Since(ctx, cfg.ClusterIdentifier, kind, now.Add(-history.Retention))
+		if err != nil {
+			return err
+		}
+		past[kind] = times
+	}
+
+	ApplyRateLimits(cfg, plan, past, now)
+	return nil
+}
+
+// recordHistory adds an applied create or delete to the history
+func (a *Autoscaler) recordHistory(ctx context.Context, cfg *Config, action Action) {
+	if action.Type != ActionCreate && action.Type != ActionDelete {
+		return
+	}
+	if err := a.history.Record(ctx, cfg.ClusterIdentifier, action.Type, time.Now()); err != nil {
+		log.Printf("Warning: failed to record %s of %s in the scaling history: %v", action.Type, action.Identifier, err)
+	}
+}
+
+// alertRateLimited reports rate limits that deferred actions. The log line
+// and metric are emitted on every run; the SNS alert at most once an hour.
+func (a *Autoscaler) alertRateLimited(ctx context.Context, cfg *Config, hits []LimitHit) {
+	for _, h := range hits {
+		log.Printf("Warning: scaling rate limit reached for %s: %s", cfg.ClusterIdentifier, h)
+		a.telemetry.recordRateLimited(cfg.ClusterIdentifier, h)
+	}
+	if cfg.AlertTopicArn == "" {
+		return
+	}
+
+	now := time.Now()
+	recent, err := a.history.Since(ctx, cfg.ClusterIdentifier, alertKind, now.Add(-time.Hour))
+	if err != nil {
+		log.Printf("Warning: failed to read alert history: %v", err)
+		return
+	}
+	if len(recent) > 0 {
+		return
+	}
+
+	message := fmt.Sprintf("The DocumentDB autoscaler for %s deferred scaling actions because a rate limit was reached:\n", cfg.ClusterIdentifier)
+	for _, h := range hits {
+		message += fmt.Sprintf("- %s\n", h)
+	}
+	message += "\nRepeated hits usually mean the scaling metrics are flapping. Check the thresholds and cooldown before raising the limits."
+
+	_, err = a.sns.PublishWithContext(ctx, &sns.PublishInput{
+		TopicArn: aws.String(cfg.AlertTopicArn),
+		Subject:  aws.String(fmt.Sprintf("DocumentDB autoscaler rate limit reached: %s", cfg.ClusterIdentifier)),
+		Message:  aws.String(message),
+	})
+	if err != nil {
+		log.Printf("Warning: failed to publish rate limit alert: %v", err)
+		return
+	}
+	if err := a.history.Record(ctx, cfg.ClusterIdentifier, alertKind, now); err != nil {
+		log.Printf("Warning: failed to record rate limit alert: %v", err)
+	}
+}
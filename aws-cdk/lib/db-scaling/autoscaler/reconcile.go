@@ -0,0 +1,341 @@
+package autoscaler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"docdb-auto-scaling/internal/naming"
+)
+
+// Plan actions
+const (
+	ActionCreate = "create"
+	ActionDelete = "delete"
+	ActionModify = "modify"
+)
+
+// DesiredState is what the reader fleet should look like after reconciling
+type DesiredState struct {
+	Readers           int               `json:"readers"`
+	InstanceClasses   []string          `json:"instanceClasses"` // new readers use the first; readers of other classes are modified to it
+	AvailabilityZones []string          `json:"availabilityZones"`
+	Tags              map[string]string `json:"tags,omitempty"`
+}
+
+// Action is one change to the cluster. Actions are idempotent: creates use
+// the window's reader names, deletes and modifies name an existing reader.
+type Action struct {
+	Type             string            `json:"type"`
+	Identifier       string            `json:"identifier"`
+	Arn              string            `json:"arn,omitempty"`
+	InstanceClass    string            `json:"instanceClass,omitempty"`
+	AvailabilityZone string            `json:"availabilityZone,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	Reason           string            `json:"reason"`
+	Deferred         bool              `json:"deferred,omitempty"` // left for a later run
+	DeferredReason   string            `json:"deferredReason,omitempty"`
+	CostDelta        float64           `json:"costDelta,omitempty"` // hourly, when prices are known
+}
+
+// reasonBudget is the DeferredReason of actions over the per-run action budget
+const reasonBudget = "action budget"
+
+// ReconcilePlan is the diff between the desired state and the cluster
+type ReconcilePlan struct {
+	Desired DesiredState `json:"desired"`
+	Actual  int          `json:"actual"` // readers that are not being deleted
+	Actions []Action     `json:"actions"`
+	Notes   []string     `json:"notes,omitempty"` // why expected actions were left out
+
+	RateLimited []LimitHit `json:"rateLimited,omitempty"`
+	Cost        *Cost      `json:"cost,omitempty"`
+}
+
+// Pending returns the actions within the budget
+func (p *ReconcilePlan) Pending() []Action {
+	var pending []Action
+	for _, action := range p.Actions {
+		if !action.Deferred {
+			pending = append(pending, action)
+		}
+	}
+	return pending
+}
+
+// String renders the plan for logs and the CLI
+func (p *ReconcilePlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "readers: %d -> %d\n", p.Actual, p.Desired.Readers)
+	if len(p.Actions) == 0 {
+		b.WriteString("  no changes\n")
+	}
+	for _, action := range p.Actions {
+		prefix := map[string]string{ActionCreate: "+", ActionDelete: "-", ActionModify: "~"}[action.Type]
+		fmt.Fprintf(&b, "  %s %s %s", prefix, action.Type, action.Identifier)
+		var details []string
+		if action.InstanceClass != "" {
+			details = append(details, action.InstanceClass)
+		}
+		if action.AvailabilityZone != "" {
+			details = append(details, action.AvailabilityZone)
+		}
+		if len(action.Tags) > 0 {
+			details = append(details, "tags "+formatTags(action.Tags))
+		}
+		if action.CostDelta != 0 {
+			details = append(details, fmt.Sprintf("%+.3f/h", action.CostDelta))
+		}
+		if len(details) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+		}
+		fmt.Fprintf(&b, ": %s", action.Reason)
+		if action.Deferred {
+			fmt.Fprintf(&b, " [deferred: %s]", action.DeferredReason)
+		}
+		b.WriteString("\n")
+	}
+	for _, note := range p.Notes {
+		fmt.Fprintf(&b, "  note: %s\n", note)
+	}
+	if p.Cost != nil {
+		fmt.Fprintf(&b, "cost: %s\n", p.Cost)
+	}
+	return b.String()
+}
+
+// Desired derives the desired state from the configuration and a decision:
+// one reader more or less than the readers not being deleted, within the
+// configured bounds
+func Desired(cfg *Config, info *ClusterInfo, decision ScalingDecision) DesiredState {
+	readers := len(activeReaders(info))
+	switch decision.Action {
+	case ActionScaleOut:
+		readers++
+	case ActionScaleIn:
+		readers--
+	}
+	readers = max(cfg.MinReadReplicas, min(readers, cfg.MaxReadReplicas))
+
+	return DesiredState{
+		Readers:           readers,
+		InstanceClasses:   append([]string{cfg.InstanceClass}, cfg.FallbackInstanceClasses...),
+		AvailabilityZones: append([]string(nil), info.AvailabilityZones...),
+		Tags:              cfg.ReaderTags,
+	}
+}
+
+// Diff plans the actions that move the cluster to the desired state. New
+// readers go to the least used availability zones and are named from the
+// decision window; removed readers are the oldest available ones outside the
+// cooldown, taken from the most used zones. Actions beyond
+// cfg.MaxActionsPerRun are marked deferred.
+func Diff(cfg *Config, desired DesiredState, info *ClusterInfo, window, now time.Time) (*ReconcilePlan, error) {
+	template, err := naming.Parse(cfg.ReaderNameTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	active := activeReaders(info)
+	plan := &ReconcilePlan{Desired: desired, Actual: len(active)}
+
+	zoneCounts := make(map[string]int)
+	for _, zone := range desired.AvailabilityZones {
+		zoneCounts[zone] = 0
+	}
+	for _, reader := range active {
+		if reader.AvailabilityZone != "" {
+			zoneCounts[reader.AvailabilityZone]++
+		}
+	}
+
+	existing := make(map[string]bool)
+	for _, reader := range append(append([]ReaderInstance(nil), info.ReaderInstances...), info.PendingReaders...) {
+		existing[reader.Identifier] = true
+	}
+	current := make(map[string]bool)
+	for _, reader := range active {
+		current[reader.Identifier] = true
+	}
+
+	// Create missing readers. A reader an earlier run in this window already
+	// created is one of this window's creates: Desired counted it, so a retry
+	// would otherwise ask for another. Names of readers being deleted are
+	// skipped.
+	missing := desired.Readers - len(active)
+	for ordinal := 0; missing > 0; ordinal++ {
+		identifier, err := template.RenderOrdinal(cfg.ClusterIdentifier, window, ordinal)
+		if err != nil {
+			return nil, fmt.Errorf("failed to name read replica: %w", err)
+		}
+		if current[identifier] {
+			missing--
+			continue
+		}
+		if existing[identifier] {
+			continue
+		}
+
+		zone := leastUsedZone(zoneCounts)
+		if zone != "" {
+			zoneCounts[zone]++
+		}
+		plan.Actions = append(plan.Actions, Action{
+			Type:             ActionCreate,
+			Identifier:       identifier,
+			InstanceClass:    desired.InstanceClasses[0],
+			AvailabilityZone: zone,
+			Tags:             desired.Tags,
+			Reason:           fmt.Sprintf("%d of %d readers", len(active), desired.Readers),
+		})
+		missing--
+	}
+
+	// Remove surplus readers
+	deleting := make(map[string]bool)
+	if surplus := len(active) - desired.Readers; surplus > 0 {
+		if len(active) < len(info.ReaderInstances)+len(info.PendingReaders) {
+			plan.Notes = append(plan.Notes, "scale-in skipped: a reader is already being deleted")
+		} else {
+			cooldownThreshold := now.Add(-time.Duration(cfg.CooldownMinutes) * time.Minute)
+			var candidates []ReaderInstance
+			for _, reader := range active {
+				if reader.Status == "available" && !reader.CreateTime.IsZero() && reader.CreateTime.Before(cooldownThreshold) {
+					candidates = append(candidates, reader)
+				}
+			}
+
+			for ; surplus > 0 && len(candidates) > 0; surplus-- {
+				// Oldest reader in the most used zone
+				sort.SliceStable(candidates, func(i, j int) bool {
+					zi, zj := zoneCounts[candidates[i].AvailabilityZone], zoneCounts[candidates[j].AvailabilityZone]
+					if zi != zj {
+						return zi > zj
+					}
+					return candidates[i].CreateTime.Before(candidates[j].CreateTime)
+				})
+				reader := candidates[0]
+				candidates = candidates[1:]
+				zoneCounts[reader.AvailabilityZone]--
+				deleting[reader.Identifier] = true
+
+				plan.Actions = append(plan.Actions, Action{
+					Type:             ActionDelete,
+					Identifier:       reader.Identifier,
+					AvailabilityZone: reader.AvailabilityZone,
+					Reason:           fmt.Sprintf("%d of %d readers", len(active), desired.Readers),
+				})
+			}
+			if surplus > 0 {
+				plan.Notes = append(plan.Notes, fmt.Sprintf("%d surplus readers kept: no available reader is outside the %d-minute cooldown", surplus, cfg.CooldownMinutes))
+			}
+		}
+	}
+
+	// Bring the remaining readers to the desired class and tags
+	for _, reader := range active {
+		if deleting[reader.Identifier] || reader.Status != "available" {
+			continue
+		}
+
+		action := Action{Type: ActionModify, Identifier: reader.Identifier, Arn: reader.Arn}
+		var reasons []string
+		if cfg.VerticalScaling && reader.InstanceClass != "" && !contains(desired.InstanceClasses, reader.InstanceClass) {
+			action.InstanceClass = desired.InstanceClasses[0]
+			reasons = append(reasons, fmt.Sprintf("instance class %s is not allowed", reader.InstanceClass))
+		}
+		if reader.Tags != nil {
+			for key, value := range desired.Tags {
+				if current, ok := reader.Tags[key]; !ok || current != value {
+					if action.Tags == nil {
+						action.Tags = make(map[string]string)
+					}
+					action.Tags[key] = value
+				}
+			}
+			if len(action.Tags) > 0 {
+				reasons = append(reasons, "tags differ")
+			}
+		}
+		if len(reasons) > 0 {
+			action.Reason = strings.Join(reasons, ", ")
+			plan.Actions = append(plan.Actions, action)
+		}
+	}
+
+	plan.applyBudget(cfg.MaxActionsPerRun)
+	return plan, nil
+}
+
+// applyBudget defers the actions beyond the first budget ones, ignoring
+// actions already deferred for another reason
+func (p *ReconcilePlan) applyBudget(budget int) {
+	n := 0
+	for i := range p.Actions {
+		action := &p.Actions[i]
+		if action.Deferred && action.DeferredReason != reasonBudget {
+			continue
+		}
+		action.Deferred, action.DeferredReason = false, ""
+		if n >= budget {
+			action.Deferred, action.DeferredReason = true, reasonBudget
+		}
+		n++
+	}
+}
+
+// deferAll defers every action, for example while autoscaling is paused
+func (p *ReconcilePlan) deferAll(reason string) {
+	for i := range p.Actions {
+		p.Actions[i].Deferred, p.Actions[i].DeferredReason = true, reason
+	}
+}
+
+// activeReaders returns the readers, including pending ones, that are not being deleted
+func activeReaders(info *ClusterInfo) []ReaderInstance {
+	var active []ReaderInstance
+	for _, reader := range append(append([]ReaderInstance(nil), info.ReaderInstances...), info.PendingReaders...) {
+		if reader.Status != "deleting" {
+			active = append(active, reader)
+		}
+	}
+	return active
+}
+
+// leastUsedZone returns the zone with the fewest readers, or "" if the
+// cluster's zones are unknown
+func leastUsedZone(zoneCounts map[string]int) string {
+	zones := make([]string, 0, len(zoneCounts))
+	for zone := range zoneCounts {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	best := ""
+	for _, zone := range zones {
+		if best == "" || zoneCounts[zone] < zoneCounts[best] {
+			best = zone
+		}
+	}
+	return best
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// formatTags renders tags as sorted key=value pairs
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
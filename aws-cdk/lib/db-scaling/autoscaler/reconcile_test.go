@@ -0,0 +1,180 @@
+package autoscaler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"docdb-auto-scaling/internal/pricing"
+)
+
+var (
+	testNow    = time.Date(2024, 5, 1, 10, 7, 0, 0, time.UTC)
+	testWindow = time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC)
+)
+
+func reader(id, zone, class string, age time.Duration) ReaderInstance {
+	return ReaderInstance{
+		Identifier:       id,
+		Status:           "available",
+		InstanceClass:    class,
+		AvailabilityZone: zone,
+		CreateTime:       testNow.Add(-age),
+	}
+}
+
+func testCluster(readers ...ReaderInstance) *ClusterInfo {
+	return &ClusterInfo{
+		AvailabilityZones: []string{"us-east-1a", "us-east-1b", "us-east-1c"},
+		ReaderCount:       len(readers),
+		ReaderInstances:   readers,
+	}
+}
+
+func TestDiffCreatesInLeastUsedZones(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MaxActionsPerRun = 2
+	info := testCluster(reader("r1", "us-east-1a", "db.r6g.large", time.Hour))
+
+	desired := Desired(cfg, info, ScalingDecision{Action: ActionScaleOut})
+	desired.Readers = 3
+	plan, err := Diff(cfg, desired, info, testWindow, testNow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(plan.Actions) != 2 {
+		t.Fatalf("Expected 2 creates, got:\n%s", plan)
+	}
+	zones := plan.Actions[0].AvailabilityZone + "," + plan.Actions[1].AvailabilityZone
+	if zones != "us-east-1b,us-east-1c" {
+		t.Errorf("Expected creates in the unused zones, got %s", zones)
+	}
+	if plan.Actions[0].Identifier == plan.Actions[1].Identifier {
+		t.Errorf("Expected distinct reader names, got %s twice", plan.Actions[0].Identifier)
+	}
+}
+
+func TestDiffIsIdempotentWithinWindow(t *testing.T) {
+	cfg := testConfig(t)
+	info := testCluster(reader("r1", "us-east-1a", "db.r6g.large", time.Hour))
+	desired := Desired(cfg, info, ScalingDecision{Action: ActionScaleOut})
+
+	first, _ := Diff(cfg, desired, info, testWindow, testNow)
+	if len(first.Actions) != 1 || first.Actions[0].Type != ActionCreate {
+		t.Fatalf("Expected one create, got:\n%s", first)
+	}
+
+	// The reader from the first run is pending; re-deciding and re-planning,
+	// as Evaluate does, must not create another
+	info.PendingReaders = []ReaderInstance{{Identifier: first.Actions[0].Identifier, Status: "creating"}}
+	desired = Desired(cfg, info, ScalingDecision{Action: ActionScaleOut})
+	second, _ := Diff(cfg, desired, info, testWindow, testNow)
+	if len(second.Actions) != 0 {
+		t.Errorf("Expected no actions once the reader exists, got:\n%s", second)
+	}
+}
+
+func TestDiffDeletesOldestInBusiestZone(t *testing.T) {
+	cfg := testConfig(t)
+	info := testCluster(
+		reader("oldest", "us-east-1a", "db.r6g.large", 3*time.Hour),
+		reader("crowded-old", "us-east-1b", "db.r6g.large", 2*time.Hour),
+		reader("crowded-new", "us-east-1b", "db.r6g.large", time.Hour),
+		reader("cooling", "us-east-1c", "db.r6g.large", time.Minute),
+	)
+
+	plan, _ := Diff(cfg, Desired(cfg, info, ScalingDecision{Action: ActionScaleIn}), info, testWindow, testNow)
+	if len(plan.Actions) != 1 || plan.Actions[0].Type != ActionDelete || plan.Actions[0].Identifier != "crowded-old" {
+		t.Errorf("Expected crowded-old to be deleted, got:\n%s", plan)
+	}
+
+	// Readers inside the cooldown are kept
+	info = testCluster(
+		reader("r1", "us-east-1a", "db.r6g.large", time.Minute),
+		reader("r2", "us-east-1b", "db.r6g.large", time.Minute),
+	)
+	plan, _ = Diff(cfg, Desired(cfg, info, ScalingDecision{Action: ActionScaleIn}), info, testWindow, testNow)
+	if len(plan.Actions) != 0 || len(plan.Notes) != 1 {
+		t.Errorf("Expected no deletes and a cooldown note, got:\n%s", plan)
+	}
+}
+
+func TestDiffBudgetAndModify(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ReaderTags = map[string]string{"team": "platform"}
+	old := reader("r1", "us-east-1a", "db.r5.large", time.Hour)
+	old.Tags = map[string]string{}
+	info := testCluster(old)
+
+	plan, _ := Diff(cfg, Desired(cfg, info, ScalingDecision{Action: ActionScaleOut}), info, testWindow, testNow)
+	if len(plan.Actions) != 2 {
+		t.Fatalf("Expected a create and a modify, got:\n%s", plan)
+	}
+	modify := plan.Actions[1]
+	if modify.Type != ActionModify || modify.InstanceClass != "db.r6g.large" || modify.Tags["team"] != "platform" {
+		t.Errorf("Expected r1 to be moved to db.r6g.large and tagged, got %+v", modify)
+	}
+	if plan.Actions[0].Deferred || !modify.Deferred {
+		t.Errorf("Expected only the first action within a budget of 1, got:\n%s", plan)
+	}
+	if len(plan.Pending()) != 1 || !strings.Contains(plan.String(), "[deferred: action budget]") {
+		t.Errorf("Expected the deferred action to be shown, got:\n%s", plan)
+	}
+}
+
+func TestApplyRateLimits(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MaxActionsPerRun = 3
+	cfg.MaxCreatesPerHour = 2
+	cfg.MaxCreatesPerDay = 20
+	plan := &ReconcilePlan{Actions: []Action{
+		{Type: ActionCreate, Identifier: "c1"},
+		{Type: ActionCreate, Identifier: "c2"},
+		{Type: ActionModify, Identifier: "m1"},
+	}}
+	past := map[string][]time.Time{
+		ActionCreate: {testNow.Add(-3 * time.Hour), testNow.Add(-30 * time.Minute)},
+	}
+
+	ApplyRateLimits(cfg, plan, past, testNow)
+
+	if plan.Actions[0].Deferred || !plan.Actions[1].Deferred || plan.Actions[2].Deferred {
+		t.Fatalf("Expected only the second create to be deferred, got:\n%s", plan)
+	}
+	if !strings.HasPrefix(plan.Actions[1].DeferredReason, "rate limit: 2 creates in the last hour") {
+		t.Errorf("Expected a rate limit reason, got %q", plan.Actions[1].DeferredReason)
+	}
+	if len(plan.RateLimited) != 1 || plan.RateLimited[0].Period != "hour" {
+		t.Errorf("Expected one hourly limit hit, got %+v", plan.RateLimited)
+	}
+}
+
+func TestApplyCostCeiling(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MaxActionsPerRun = 3
+	cfg.HourlyBudget = 1.0
+	prices := &pricing.Table{Currency: "USD", Prices: map[string]float64{"db.r6g.large": 0.25, "db.r6g.xlarge": 0.5}}
+	info := testCluster(reader("r1", "us-east-1a", "db.r6g.large", time.Hour))
+	info.WriterCount, info.WriterClass = 1, "db.r6g.xlarge"
+	plan := &ReconcilePlan{
+		Desired: DesiredState{InstanceClasses: []string{"db.r6g.large"}},
+		Actions: []Action{
+			{Type: ActionCreate, Identifier: "c1"},
+			{Type: ActionCreate, Identifier: "c2"},
+			{Type: ActionModify, Identifier: "r1", InstanceClass: "db.r6g.xlarge"},
+		},
+	}
+
+	cost := ApplyCostCeiling(cfg, prices, plan, info)
+
+	if cost.Current != 0.75 || cost.Projected != 1.0 || cost.Delta != 0.25 {
+		t.Errorf("Expected 0.75 -> 1.0 per hour, got %s", cost)
+	}
+	if plan.Actions[0].Deferred || !plan.Actions[1].Deferred || !plan.Actions[2].Deferred {
+		t.Fatalf("Expected only the first create within the budget, got:\n%s", plan)
+	}
+	if !strings.HasPrefix(plan.Actions[1].DeferredReason, "cost ceiling") || plan.Actions[2].CostDelta != 0.25 {
+		t.Errorf("Expected cost ceiling deferrals with deltas, got:\n%s", plan)
+	}
+}
@@ -0,0 +1,135 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"docdb-auto-scaling/internal/rules"
+)
+
+// metricSource is where a rule series comes from in CloudWatch
+type metricSource struct {
+	metric    string
+	role      string
+	statistic string
+	scale     float64 // multiplies every value, 0 for none
+}
+
+// ruleSeries maps the series rules can read to their CloudWatch metrics
+var ruleSeries = map[string]metricSource{
+	"writer.cpu":          {metric: "CPUUtilization", role: "WRITER", statistic: "Average"},
+	"readers.cpu":         {metric: "CPUUtilization", role: "READER", statistic: "Average"},
+	"connections.writer":  {metric: "DatabaseConnections", role: "WRITER", statistic: "Average"},
+	"connections.readers": {metric: "DatabaseConnections", role: "READER", statistic: "Average"},
+	"readers.lag":         {metric: "DBClusterReplicaLagMaximum", statistic: "Maximum"},
+	"writer.memory":       {metric: "FreeableMemory", role: "WRITER", statistic: "Average", scale: 1.0 / megabyte},
+}
+
+// getRuleSeries fetches the series the configured rules read, over the
+// longest span any of them needs. A series that fails to load is left out,
+// so only the rules reading it are skipped.
+func (a *Autoscaler) getRuleSeries(ctx context.Context, cfg *Config, endTime time.Time) map[string][]float64 {
+	span := cfg.EvaluationPeriods
+	needed := make(map[string]bool)
+	for _, rule := range cfg.Rules {
+		span = max(span, rule.Condition.Span(cfg.EvaluationPeriods))
+		for _, name := range rule.Condition.Refs() {
+			needed[name] = true
+		}
+	}
+	startTime := endTime.Add(-time.Duration(span) * time.Minute)
+
+	series := make(map[string][]float64, len(needed))
+	for name := range needed {
+		source := ruleSeries[name]
+		values, err := a.getMetricSeries(ctx, cfg, source.metric, source.role, source.statistic, startTime, endTime)
+		if err != nil {
+			log.Printf("Warning: Failed to get %s for rules: %v", name, err)
+			continue
+		}
+		if source.scale != 0 {
+			for i := range values {
+				values[i] *= source.scale
+			}
+		}
+		series[name] = values
+	}
+	return series
+}
+
+// ruleEnv builds what rules are evaluated against. Without fetched series,
+// as when simulating a policy offline, every series holds its average for
+// as long as the rules look back.
+func ruleEnv(cfg *Config, clusterInfo *ClusterInfo, metrics *Metrics) *rules.Env {
+	env := &rules.Env{
+		Series:  metrics.Series,
+		Scalars: map[string]float64{"readers.count": float64(clusterInfo.ReaderCount)},
+		Window:  cfg.EvaluationPeriods,
+	}
+	if env.Series != nil {
+		return env
+	}
+
+	span := cfg.EvaluationPeriods
+	for _, rule := range cfg.Rules {
+		span = max(span, rule.Condition.Span(cfg.EvaluationPeriods))
+	}
+	steady := func(value float64) []float64 {
+		values := make([]float64, span)
+		for i := range values {
+			values[i] = value
+		}
+		return values
+	}
+	env.Series = map[string][]float64{
+		"writer.cpu":         steady(metrics.WriterCPU),
+		"readers.cpu":        steady(metrics.ReaderCPU),
+		"connections.writer": steady(metrics.WriterConnections),
+		"readers.lag":        steady(metrics.ReplicaLag),
+	}
+	if metrics.FreeableMemory > 0 {
+		env.Series["writer.memory"] = steady(metrics.FreeableMemory / megabyte)
+	}
+	return env
+}
+
+// decideRules scales out if any scale_out rule holds, otherwise in if any
+// scale_in rule holds, in the order of the policy document. A rule that
+// can't be evaluated, for lack of data, counts as not holding.
+func decideRules(cfg *Config, clusterInfo *ClusterInfo, metrics *Metrics) ScalingDecision {
+	env := ruleEnv(cfg, clusterInfo, metrics)
+
+	for _, action := range []string{ActionScaleOut, ActionScaleIn} {
+		for _, rule := range cfg.Rules {
+			if rule.Action != action {
+				continue
+			}
+			held, err := rule.Condition.Eval(env)
+			if err != nil {
+				log.Printf("Warning: Rule %q not evaluated: %v", rule.Name, err)
+				continue
+			}
+			if !held {
+				continue
+			}
+
+			switch {
+			case action == ActionScaleOut && clusterInfo.ReaderCount >= cfg.MaxReadReplicas:
+				log.Printf("Rule %q holds but already at max replicas (%d)", rule.Name, cfg.MaxReadReplicas)
+			case action == ActionScaleIn && clusterInfo.ReaderCount <= cfg.MinReadReplicas:
+				log.Printf("Rule %q holds but already at min replicas (%d)", rule.Name, cfg.MinReadReplicas)
+			default:
+				return ScalingDecision{
+					Action: action,
+					Reason: fmt.Sprintf("Rule %q holds", rule.Name),
+					Rule:   rule.Name,
+				}
+			}
+			break
+		}
+	}
+
+	return ScalingDecision{Action: ActionNone, Reason: "No rule holds"}
+}
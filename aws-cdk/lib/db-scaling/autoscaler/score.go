@@ -0,0 +1,124 @@
+package autoscaler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Signal is one metric's contribution to a pressure score
+type Signal struct {
+	Name      string  `json:"name"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Pressure  float64 `json:"pressure"` // 1.0 means the metric sits at its threshold
+	Weight    float64 `json:"weight"`
+}
+
+// Score is the weighted mean pressure across the signals with a weight
+type Score struct {
+	Value   float64  `json:"value"`
+	Signals []Signal `json:"signals"`
+}
+
+// Top returns the signal furthest past its threshold
+func (s *Score) Top() (Signal, bool) {
+	var top Signal
+	found := false
+	for _, signal := range s.Signals {
+		if !found || signal.Pressure > top.Pressure {
+			top, found = signal, true
+		}
+	}
+	return top, found
+}
+
+// PressureScore combines the metrics into a single score. Each signal's
+// pressure is its value over its scale-out threshold, except freeable memory,
+// which rises as memory falls. Signals without data are left out rather than
+// counted as zero pressure.
+func PressureScore(cfg *Config, metrics *Metrics) *Score {
+	var candidates []Signal
+	add := func(name, metric string, value, threshold float64) {
+		if metrics.HasData(metric) {
+			candidates = append(candidates, Signal{Name: name, Value: value, Threshold: threshold})
+		}
+	}
+	add("writer_cpu", "writerCpu", metrics.WriterCPU, cfg.CPUScaleOutThreshold)
+	add("reader_cpu", "readerCpu", metrics.ReaderCPU, cfg.CPUScaleOutThreshold)
+	add("connections", "writerConnections", metrics.WriterConnections, cfg.ConnectionsScaleOutThreshold)
+	add("replica_lag", "replicaLag", metrics.ReplicaLag, cfg.ReplicaLagThreshold)
+	if metrics.FreeableMemory > 0 && metrics.HasData("freeableMemory") {
+		free := metrics.FreeableMemory / megabyte
+		candidates = append(candidates, Signal{
+			Name:      "freeable_memory",
+			Value:     free,
+			Threshold: cfg.FreeableMemoryThreshold,
+			Pressure:  cfg.FreeableMemoryThreshold / free,
+		})
+	}
+
+	score := &Score{}
+	total := 0.0
+	for _, signal := range candidates {
+		signal.Weight = cfg.ScoreWeights[signal.Name]
+		if signal.Weight <= 0 || signal.Threshold <= 0 {
+			continue
+		}
+		if signal.Name != "freeable_memory" {
+			signal.Pressure = signal.Value / signal.Threshold
+		}
+		score.Value += signal.Pressure * signal.Weight
+		total += signal.Weight
+		score.Signals = append(score.Signals, signal)
+	}
+	if total > 0 {
+		score.Value /= total
+	}
+	sort.SliceStable(score.Signals, func(i, j int) bool {
+		return score.Signals[i].Pressure > score.Signals[j].Pressure
+	})
+	return score
+}
+
+// decideScore scales out when the pressure score reaches SCORE_SCALE_OUT and
+// in when it falls to SCORE_SCALE_IN, so no single metric has to cross its
+// own threshold
+func decideScore(cfg *Config, clusterInfo *ClusterInfo, metrics *Metrics) ScalingDecision {
+	score := PressureScore(cfg, metrics)
+	// Reasons label the decisions counter, so they name the leading signal
+	// but leave the numbers to Score
+	led := ""
+	if top, ok := score.Top(); ok {
+		led = fmt.Sprintf(", led by %s", top.Name)
+	}
+
+	if score.Value >= cfg.ScoreScaleOut {
+		if clusterInfo.ReaderCount < cfg.MaxReadReplicas {
+			return ScalingDecision{
+				Action:    ActionScaleOut,
+				Reason:    "Pressure score high" + led,
+				Threshold: cfg.ScoreScaleOut,
+				Current:   score.Value,
+				Score:     score,
+			}
+		}
+		log.Printf("Scale out needed but already at max replicas (%d)", cfg.MaxReadReplicas)
+	}
+
+	if score.Value <= cfg.ScoreScaleIn {
+		if clusterInfo.ReaderCount > cfg.MinReadReplicas {
+			return ScalingDecision{
+				Action:    ActionScaleIn,
+				Reason:    "Pressure score low" + led,
+				Threshold: cfg.ScoreScaleIn,
+				Current:   score.Value,
+				Score:     score,
+			}
+		}
+		log.Printf("Scale in conditions met but already at min replicas (%d)", cfg.MinReadReplicas)
+	}
+
+	log.Printf("Pressure score %.2f%s", score.Value, led)
+	return ScalingDecision{Action: ActionNone, Reason: "Pressure score within band", Current: score.Value, Score: score}
+}
@@ -0,0 +1,117 @@
+package autoscaler
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	"docdb-auto-scaling/openmetrics"
+)
+
+// telemetry exposes the autoscaler's view of the cluster in OpenMetrics
+// format; the daemon serves it on /metrics
+type telemetry struct {
+	registry          *openmetrics.Registry
+	readers           *openmetrics.Gauge
+	writerCPU         *openmetrics.Gauge
+	readerCPU         *openmetrics.Gauge
+	writerConnections *openmetrics.Gauge
+	replicaLag        *openmetrics.Gauge
+	freeableMemory    *openmetrics.Gauge
+	pressureScore     *openmetrics.Gauge
+	pausedUntil       *openmetrics.Gauge
+	decisions         *openmetrics.Counter
+	actions           *openmetrics.Counter
+	rateLimited       *openmetrics.Counter
+	hourlyCost        *openmetrics.Gauge
+	hourlyBudget      *openmetrics.Gauge
+	apiLatency        *openmetrics.Histogram
+}
+
+func newTelemetry() *telemetry {
+	registry := openmetrics.NewRegistry()
+	return &telemetry{
+		registry:          registry,
+		readers:           registry.Gauge("docdb_autoscaler_readers", "Reader instances by status"),
+		writerCPU:         registry.Gauge("docdb_autoscaler_writer_cpu_percent", "Writer CPU utilization over the evaluation period"),
+		readerCPU:         registry.Gauge("docdb_autoscaler_reader_cpu_percent", "Average reader CPU utilization over the evaluation period"),
+		writerConnections: registry.Gauge("docdb_autoscaler_writer_connections", "Writer database connections over the evaluation period"),
+		replicaLag:        registry.Gauge("docdb_autoscaler_replica_lag_milliseconds", "Maximum reader replica lag over the evaluation period"),
+		freeableMemory:    registry.Gauge("docdb_autoscaler_writer_freeable_memory_bytes", "Writer freeable memory over the evaluation period"),
+		pressureScore:     registry.Gauge("docdb_autoscaler_pressure_score", "Weighted pressure score of the score policy, by signal and in total"),
+		pausedUntil:       registry.Gauge("docdb_autoscaler_paused_until_seconds", "Unix time until which autoscaling is paused, 0 if it is not"),
+		decisions:         registry.Counter("docdb_autoscaler_decisions", "Scaling decisions by action and reason"),
+		actions:           registry.Counter("docdb_autoscaler_actions", "Planned reconcile actions by type and outcome"),
+		rateLimited:       registry.Counter("docdb_autoscaler_rate_limited", "Runs in which a rate limit deferred actions, by action and period"),
+		hourlyCost:        registry.Gauge("docdb_autoscaler_hourly_cost", "On-demand cost per hour of the cluster's instances, now and after the plan"),
+		hourlyBudget:      registry.Gauge("docdb_autoscaler_hourly_budget", "Configured ceiling of the hourly cost, 0 if none"),
+		apiLatency:        registry.Histogram("docdb_autoscaler_aws_api_duration_seconds", "Latency of AWS API calls, including retries", openmetrics.DefaultLatencyBuckets),
+	}
+}
+
+// instrument records the latency of every request sent through handlers
+func (t *telemetry) instrument(handlers *request.Handlers) {
+	handlers.Complete.PushBack(func(r *request.Request) {
+		t.apiLatency.Observe(time.Since(r.Time).Seconds(), openmetrics.Labels{
+			"service":   r.ClientInfo.ServiceName,
+			"operation": r.Operation.Name,
+		})
+	})
+}
+
+func (t *telemetry) recordCluster(clusterID string, info *ClusterInfo) {
+	t.readers.Reset()
+	for status, count := range info.ReaderStatuses {
+		t.readers.Set(float64(count), openmetrics.Labels{"cluster": clusterID, "status": status})
+	}
+}
+
+func (t *telemetry) recordMetrics(clusterID string, metrics *Metrics) {
+	labels := openmetrics.Labels{"cluster": clusterID}
+	t.writerCPU.Set(metrics.WriterCPU, labels)
+	t.readerCPU.Set(metrics.ReaderCPU, labels)
+	t.writerConnections.Set(metrics.WriterConnections, labels)
+	t.replicaLag.Set(metrics.ReplicaLag, labels)
+	t.freeableMemory.Set(metrics.FreeableMemory, labels)
+}
+
+func (t *telemetry) recordPause(clusterID string, until time.Time) {
+	value := 0.0
+	if !until.IsZero() {
+		value = float64(until.Unix())
+	}
+	t.pausedUntil.Set(value, openmetrics.Labels{"cluster": clusterID})
+}
+
+func (t *telemetry) recordDecision(clusterID string, decision ScalingDecision) {
+	if decision.Score != nil {
+		t.pressureScore.Reset()
+		t.pressureScore.Set(decision.Score.Value, openmetrics.Labels{"cluster": clusterID, "signal": "total"})
+		for _, signal := range decision.Score.Signals {
+			t.pressureScore.Set(signal.Pressure, openmetrics.Labels{"cluster": clusterID, "signal": signal.Name})
+		}
+	}
+	t.decisions.Inc(openmetrics.Labels{"cluster": clusterID, "action": decision.Action, "reason": decision.Reason})
+}
+
+// recordAction counts a planned action as applied, failed or deferred
+func (t *telemetry) recordAction(clusterID string, action Action, err error) {
+	outcome := "applied"
+	switch {
+	case action.Deferred:
+		outcome = "deferred"
+	case err != nil:
+		outcome = "failed"
+	}
+	t.actions.Inc(openmetrics.Labels{"cluster": clusterID, "type": action.Type, "outcome": outcome})
+}
+
+func (t *telemetry) recordRateLimited(clusterID string, hit LimitHit) {
+	t.rateLimited.Inc(openmetrics.Labels{"cluster": clusterID, "action": hit.Action, "period": hit.Period})
+}
+
+func (t *telemetry) recordCost(clusterID string, cost *Cost) {
+	t.hourlyCost.Set(cost.Current, openmetrics.Labels{"cluster": clusterID, "state": "current", "currency": cost.Currency})
+	t.hourlyCost.Set(cost.Projected, openmetrics.Labels{"cluster": clusterID, "state": "projected", "currency": cost.Currency})
+	t.hourlyBudget.Set(cost.Budget, openmetrics.Labels{"cluster": clusterID, "currency": cost.Currency})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"docdb-auto-scaling/autoscaler"
+	"docdb-auto-scaling/openmetrics"
+)
+
+// daemon runs the scaling loop on its own ticker, for clusters that are not
+// managed by the Lambda function (EC2 hosts, on-prem Mongo-compatible clusters)
+type daemon struct {
+	interval    time.Duration
+	maxFailures int
+	startedAt   time.Time
+
+	mu          sync.Mutex
+	last        *autoscaler.Evaluation
+	lastSuccess time.Time
+	lastError   string
+	failures    int // consecutive failed evaluations
+}
+
+// runDaemon implements the daemon command. It evaluates immediately and then
+// on every tick, serves /healthz and /decision, and on SIGTERM or SIGINT lets
+// the running evaluation finish before shutting down.
+func runDaemon(args []string) int {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	interval := flags.Duration("interval", time.Minute, "time between scaling evaluations")
+	listen := flags.String("listen", ":8080", "address of the HTTP status endpoints")
+	maxFailures := flags.Int("max-failures", 3, "consecutive failed evaluations after which /healthz reports unhealthy")
+	flags.Parse(args)
+
+	if *interval <= 0 {
+		log.Printf("Invalid -interval %s: must be positive", *interval)
+		return 2
+	}
+	if *maxFailures < 1 {
+		log.Printf("Invalid -max-failures %d: must be at least 1", *maxFailures)
+		return 2
+	}
+
+	setup()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	d := &daemon{interval: *interval, maxFailures: *maxFailures, startedAt: time.Now()}
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           d.routes(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Serving status endpoints on %s", *listen)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+			stop()
+		}
+	}()
+
+	d.loop(ctx)
+
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: HTTP server did not shut down cleanly: %v", err)
+	}
+
+	select {
+	case err := <-serverErr:
+		log.Printf("HTTP server failed: %v", err)
+		return 1
+	default:
+		return 0
+	}
+}
+
+// loop evaluates on every tick until ctx is cancelled. Evaluations run on
+// their own context so a shutdown signal doesn't abort an AWS call half way.
+func (d *daemon) loop(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	tick := time.Now()
+	for {
+		d.evaluate(tick)
+
+		select {
+		case <-ctx.Done():
+			return
+		case tick = <-ticker.C:
+		}
+	}
+}
+
+func (d *daemon) evaluate(tick time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.interval)
+	defer cancel()
+
+	ev := scaler.Evaluate(ctx, autoscaler.SchedulerEvent{
+		Source:            "daemon",
+		Environment:       os.Getenv("ENVIRONMENT"),
+		ClusterIdentifier: cfg.ClusterIdentifier,
+		ScheduledTime:     tick.UTC().Format(time.RFC3339),
+	})
+	d.record(ev)
+}
+
+// record keeps the evaluation for the status endpoints. Responses of 400
+// and above count as failures.
+func (d *daemon) record(ev autoscaler.Evaluation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.last = &ev
+	if ev.Response.StatusCode >= 400 {
+		d.failures++
+		d.lastError = ev.Response.Body
+		return
+	}
+	d.failures = 0
+	d.lastSuccess = ev.StartedAt
+}
+
+func (d *daemon) lastEvaluation() *autoscaler.Evaluation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.last
+}
+
+func (d *daemon) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", d.handleHealth)
+	mux.HandleFunc("/decision", d.handleDecision)
+	mux.HandleFunc("/metrics", d.handleMetrics)
+	return mux
+}
+
+// handleHealth reports unhealthy once no evaluation has finished for three
+// intervals, or the last maxFailures evaluations failed
+func (d *daemon) handleHealth(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	last, lastSuccess, lastError, failures := d.last, d.lastSuccess, d.lastError, d.failures
+	d.mu.Unlock()
+
+	lastActivity := d.startedAt
+	if last != nil {
+		lastActivity = last.StartedAt
+	}
+
+	status := http.StatusOK
+	body := map[string]interface{}{"status": "ok", "interval": d.interval.String(), "consecutiveFailures": failures}
+	if last != nil {
+		body["lastEvaluation"] = last.StartedAt
+	}
+	if !lastSuccess.IsZero() {
+		body["lastSuccess"] = lastSuccess
+	}
+	if lastError != "" {
+		body["lastError"] = lastError
+	}
+	switch {
+	case time.Since(lastActivity) > 3*d.interval:
+		status = http.StatusServiceUnavailable
+		body["status"] = "stale"
+	case failures >= d.maxFailures:
+		status = http.StatusServiceUnavailable
+		body["status"] = "failing"
+	}
+
+	writeJSON(w, status, body)
+}
+
+// handleDecision returns the most recent evaluation
+func (d *daemon) handleDecision(w http.ResponseWriter, r *http.Request) {
+	last := d.lastEvaluation()
+	if last == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no evaluation yet"})
+		return
+	}
+	writeJSON(w, http.StatusOK, last)
+}
+
+// handleMetrics serves the autoscaler metrics in OpenMetrics text format
+func (d *daemon) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", openmetrics.ContentType)
+	if _, err := scaler.Registry().WriteTo(w); err != nil {
+		log.Printf("Warning: failed to write metrics: %v", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"docdb-auto-scaling/autoscaler"
+)
+
+func TestHealthAfterFailures(t *testing.T) {
+	d := &daemon{interval: time.Minute, maxFailures: 3, startedAt: time.Now()}
+	health := func() (int, map[string]interface{}) {
+		recorder := httptest.NewRecorder()
+		d.handleHealth(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var body map[string]interface{}
+		json.NewDecoder(recorder.Body).Decode(&body)
+		return recorder.Code, body
+	}
+
+	d.record(autoscaler.Evaluation{StartedAt: time.Now(), Response: autoscaler.Response{StatusCode: 200}})
+	failed := autoscaler.Evaluation{StartedAt: time.Now(), Response: autoscaler.Response{StatusCode: 500, Body: "Error: expired credentials"}}
+	for i := 0; i < 2; i++ {
+		d.record(failed)
+	}
+	if code, body := health(); code != http.StatusOK {
+		t.Errorf("Expected 200 after 2 failures, got %d (%v)", code, body)
+	}
+
+	d.record(failed)
+	code, body := health()
+	if code != http.StatusServiceUnavailable || body["status"] != "failing" || body["lastError"] != "Error: expired credentials" {
+		t.Errorf("Expected 503 failing with the last error after 3 failures, got %d (%v)", code, body)
+	}
+	if _, ok := body["lastSuccess"]; !ok {
+		t.Errorf("Expected the last successful evaluation to be reported, got %v", body)
+	}
+
+	d.record(autoscaler.Evaluation{StartedAt: time.Now(), Response: autoscaler.Response{StatusCode: 200}})
+	if code, body := health(); code != http.StatusOK {
+		t.Errorf("Expected 200 once an evaluation succeeds again, got %d (%v)", code, body)
+	}
+}
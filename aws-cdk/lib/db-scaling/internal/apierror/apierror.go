@@ -0,0 +1,98 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/docdb"
+)
+
+// Class identifies the kind of AWS API failure a scaling action ran into
+type Class string
+
+const (
+	ClassNone                 Class = ""
+	ClassThrottling           Class = "throttling"
+	ClassInvalidClusterState  Class = "invalid_cluster_state"
+	ClassQuotaExceeded        Class = "instance_quota_exceeded"
+	ClassInsufficientCapacity Class = "insufficient_capacity"
+	ClassAlreadyExists        Class = "already_exists"
+	ClassUnknown              Class = "unknown"
+)
+
+// Classify maps an AWS SDK error to a Class
+func Classify(err error) Class {
+	if err == nil {
+		return ClassNone
+	}
+
+	var scalingErr *Error
+	if errors.As(err, &scalingErr) {
+		return scalingErr.Class
+	}
+
+	if request.IsErrorThrottle(err) {
+		return ClassThrottling
+	}
+
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return ClassUnknown
+	}
+
+	switch aerr.Code() {
+	case docdb.ErrCodeInvalidDBClusterStateFault:
+		return ClassInvalidClusterState
+	case docdb.ErrCodeInstanceQuotaExceededFault:
+		return ClassQuotaExceeded
+	case docdb.ErrCodeInsufficientDBInstanceCapacityFault:
+		return ClassInsufficientCapacity
+	case docdb.ErrCodeDBInstanceAlreadyExistsFault:
+		return ClassAlreadyExists
+	default:
+		return ClassUnknown
+	}
+}
+
+// Error wraps a failed scaling call together with its classification
+type Error struct {
+	Class Class
+	Op    string
+	Err   error
+}
+
+// Wrap classifies err and annotates it with the operation that failed
+func Wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Class: Classify(err), Op: op, Err: err}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed (%s): %v", e.Op, e.Class, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the handler status code that reports a failure of this class
+func (c Class) StatusCode() int {
+	switch c {
+	case ClassNone, ClassAlreadyExists:
+		return 200
+	case ClassThrottling:
+		return 429
+	case ClassInvalidClusterState:
+		return 409
+	case ClassQuotaExceeded:
+		return 403
+	case ClassInsufficientCapacity:
+		return 503
+	default:
+		return 500
+	}
+}
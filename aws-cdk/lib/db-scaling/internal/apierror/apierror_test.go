@@ -0,0 +1,80 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/docdb"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected Class
+	}{
+		{nil, ClassNone},
+		{awserr.New("Throttling", "Rate exceeded", nil), ClassThrottling},
+		{awserr.New(docdb.ErrCodeInvalidDBClusterStateFault, "modifying", nil), ClassInvalidClusterState},
+		{awserr.New(docdb.ErrCodeInstanceQuotaExceededFault, "quota", nil), ClassQuotaExceeded},
+		{awserr.New(docdb.ErrCodeInsufficientDBInstanceCapacityFault, "capacity", nil), ClassInsufficientCapacity},
+		{awserr.New(docdb.ErrCodeDBInstanceAlreadyExistsFault, "exists", nil), ClassAlreadyExists},
+		{errors.New("boom"), ClassUnknown},
+		{fmt.Errorf("wrapped: %w", Wrap("CreateDBInstance", awserr.New("Throttling", "", nil))), ClassThrottling},
+	}
+
+	for _, test := range tests {
+		if result := Classify(test.err); result != test.expected {
+			t.Errorf("Classify(%v): expected '%s', got '%s'", test.err, test.expected, result)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	// Throttling is retried until the call succeeds
+	calls := 0
+	err := Retry(context.Background(), DefaultBackoff, "op", func() error {
+		calls++
+		if calls < 3 {
+			return awserr.New("Throttling", "Rate exceeded", nil)
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success after 3 calls, got %d calls and error %v", calls, err)
+	}
+
+	// Other errors are not retried
+	calls = 0
+	err = Retry(context.Background(), DefaultBackoff, "op", func() error {
+		calls++
+		return awserr.New(docdb.ErrCodeInstanceQuotaExceededFault, "quota", nil)
+	})
+	if calls != 1 || Classify(err) != ClassQuotaExceeded {
+		t.Errorf("Expected a single quota failure, got %d calls and error %v", calls, err)
+	}
+
+	// Throttling gives up after MaxAttempts
+	calls = 0
+	err = Retry(context.Background(), DefaultBackoff, "op", func() error {
+		calls++
+		return awserr.New("Throttling", "Rate exceeded", nil)
+	})
+	if calls != DefaultBackoff.MaxAttempts || Classify(err) != ClassThrottling {
+		t.Errorf("Expected %d throttled calls, got %d and error %v", DefaultBackoff.MaxAttempts, calls, err)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt <= 10; attempt++ {
+		if delay := b.Delay(attempt); delay < 0 || delay > b.MaxDelay {
+			t.Errorf("Delay(%d) = %s is outside [0, %s]", attempt, delay, b.MaxDelay)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package apierror
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Backoff configures retries of throttled API calls
+type Backoff struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultBackoff retries throttled calls up to five times, waiting at most ten seconds
+var DefaultBackoff = Backoff{
+	MaxAttempts: 5,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// sleep is replaced in tests to avoid real waits
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Delay returns the full-jitter wait before the given retry attempt (1-based)
+func (b Backoff) Delay(attempt int) time.Duration {
+	ceiling := b.BaseDelay << uint(attempt-1)
+	if ceiling <= 0 || ceiling > b.MaxDelay {
+		ceiling = b.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// Retry calls fn until it succeeds, fails with a non-throttling error or the
+// attempts are exhausted. The returned error is classified with Wrap.
+func Retry(ctx context.Context, b Backoff, op string, fn func() error) error {
+	attempts := b.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		if Classify(err) != ClassThrottling || attempt == attempts {
+			break
+		}
+
+		delay := b.Delay(attempt)
+		log.Printf("%s throttled (attempt %d/%d), retrying in %s", op, attempt, attempts, delay)
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			break
+		}
+	}
+
+	return Wrap(op, err)
+}
@@ -0,0 +1,52 @@
+// Package clusterarn resolves DocumentDB cluster identifiers to the ARNs the
+// tagging APIs take.
+package clusterarn
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/docdb"
+	"github.com/aws/aws-sdk-go/service/docdb/docdbiface"
+)
+
+// Cache looks cluster ARNs up once and remembers them. It is safe for
+// concurrent use, e.g. by the daemon's evaluations and HTTP handlers.
+type Cache struct {
+	client docdbiface.DocDBAPI
+	mu     sync.Mutex
+	arns   map[string]string
+}
+
+// NewCache creates an empty cache
+func NewCache(client docdbiface.DocDBAPI) *Cache {
+	return &Cache{client: client, arns: make(map[string]string)}
+}
+
+// Get returns the ARN of the cluster
+func (c *Cache) Get(ctx context.Context, clusterID string) (string, error) {
+	c.mu.Lock()
+	arn, ok := c.arns[clusterID]
+	c.mu.Unlock()
+	if ok {
+		return arn, nil
+	}
+
+	result, err := c.client.DescribeDBClustersWithContext(ctx, &docdb.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(clusterID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe cluster %s: %w", clusterID, err)
+	}
+	if len(result.DBClusters) == 0 {
+		return "", fmt.Errorf("cluster %s not found", clusterID)
+	}
+
+	arn = aws.StringValue(result.DBClusters[0].DBClusterArn)
+	c.mu.Lock()
+	c.arns[clusterID] = arn
+	c.mu.Unlock()
+	return arn, nil
+}
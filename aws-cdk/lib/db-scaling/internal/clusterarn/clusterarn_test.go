@@ -0,0 +1,50 @@
+package clusterarn
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/docdb"
+	"github.com/aws/aws-sdk-go/service/docdb/docdbiface"
+)
+
+// countingClient describes any cluster and counts the calls
+type countingClient struct {
+	docdbiface.DocDBAPI
+	calls atomic.Int32
+}
+
+func (c *countingClient) DescribeDBClustersWithContext(ctx aws.Context, input *docdb.DescribeDBClustersInput, opts ...request.Option) (*docdb.DescribeDBClustersOutput, error) {
+	c.calls.Add(1)
+	return &docdb.DescribeDBClustersOutput{DBClusters: []*docdb.DBCluster{
+		{DBClusterArn: aws.String("arn:aws:rds:us-east-1:123456789012:cluster:" + aws.StringValue(input.DBClusterIdentifier))},
+	}}, nil
+}
+
+func TestCacheConcurrentGet(t *testing.T) {
+	client := &countingClient{}
+	cache := NewCache(client)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			arn, err := cache.Get(context.Background(), "test-cluster")
+			if err != nil || arn != "arn:aws:rds:us-east-1:123456789012:cluster:test-cluster" {
+				t.Errorf("Expected the cluster's ARN, got %q (%v)", arn, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	before := client.calls.Load()
+	cache.Get(context.Background(), "test-cluster")
+	if client.calls.Load() != before {
+		t.Errorf("Expected a cached ARN not to be looked up again")
+	}
+}
@@ -0,0 +1,648 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"docdb-auto-scaling/internal/history"
+	"docdb-auto-scaling/internal/naming"
+	"docdb-auto-scaling/internal/rules"
+)
+
+// MaxReadReplicasLimit is the number of replicas DocumentDB allows per cluster
+const MaxReadReplicasLimit = 15
+
+var instanceClassPattern = regexp.MustCompile(`^db\.[a-z0-9]+\.[a-z0-9]+$`)
+
+// ScoreSignals are the metrics the score policy combines, see SCORE_WEIGHTS
+var ScoreSignals = []string{"writer_cpu", "reader_cpu", "connections", "replica_lag", "freeable_memory"}
+
+// defaultScoreWeights count the writer's CPU double, since readers can't relieve writes
+const defaultScoreWeights = "writer_cpu=2,reader_cpu=1,connections=1,replica_lag=1,freeable_memory=1"
+
+// maxRuleMinutes bounds the data a rule may read, since every minute is a
+// CloudWatch datapoint fetched per run
+const maxRuleMinutes = 60
+
+// Rule is a named condition of the rules decision policy. Rules only come
+// from a policy document.
+type Rule struct {
+	Name      string
+	Action    string // scale_out or scale_in
+	When      string
+	Condition *rules.Condition // compiled by Validate
+}
+
+// Config holds the autoscaler configuration
+type Config struct {
+	ClusterIdentifier            string
+	MaxReadReplicas              int
+	MinReadReplicas              int
+	InstanceClass                string
+	FallbackInstanceClasses      []string
+	CooldownMinutes              int
+	CPUScaleOutThreshold         float64
+	CPUScaleInThreshold          float64
+	ConnectionsScaleOutThreshold float64
+	EvaluationPeriods            int
+	DecisionWindowMinutes        int
+	ReaderNameTemplate           string
+	LockBackend                  string
+	LockTTLSeconds               int
+	PolicySource                 string
+	PolicyCacheSeconds           int
+	MaxActionsPerRun             int
+	ReaderTags                   map[string]string
+	MaxCreatesPerHour            int
+	MaxCreatesPerDay             int
+	MaxDeletesPerHour            int
+	MaxDeletesPerDay             int
+	HistoryBackend               string
+	AlertTopicArn                string
+	PriceTable                   string
+	PriceSnapshot                bool
+	HourlyBudget                 float64
+	Environment                  string
+	Profile                      string
+	VerticalScaling              bool
+	DecisionPolicy               string
+	ScoreWeights                 map[string]float64
+	ScoreScaleOut                float64
+	ScoreScaleIn                 float64
+	ReplicaLagThreshold          float64 // milliseconds
+	FreeableMemoryThreshold      float64 // megabytes
+	Rules                        []Rule
+
+	// values records the raw setting of every known variable, "" if defaulted
+	values map[string]string
+	// overrides records variables replaced after loading, and by what
+	overrides map[string]string
+}
+
+// loader reads variables and collects every parse problem instead of stopping at the first
+type loader struct {
+	lookup   func(string) (string, bool)
+	values   map[string]string
+	problems []error
+}
+
+func (l *loader) raw(key string) (string, bool) {
+	value, ok := l.lookup(key)
+	value = strings.TrimSpace(value)
+	if !ok || value == "" {
+		l.values[key] = ""
+		return "", false
+	}
+	l.values[key] = value
+	return value, true
+}
+
+func (l *loader) string(key, defaultValue string) string {
+	if value, ok := l.raw(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+func (l *loader) int(key string, defaultValue int) int {
+	value, ok := l.raw(key)
+	if !ok {
+		return defaultValue
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		l.problems = append(l.problems, fmt.Errorf("%s: %q is not an integer", key, value))
+		return defaultValue
+	}
+	return intValue
+}
+
+func (l *loader) float(key string, defaultValue float64) float64 {
+	value, ok := l.raw(key)
+	if !ok {
+		return defaultValue
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.problems = append(l.problems, fmt.Errorf("%s: %q is not a number", key, value))
+		return defaultValue
+	}
+	return floatValue
+}
+
+func (l *loader) bool(key string, defaultValue bool) bool {
+	value, ok := l.raw(key)
+	if !ok {
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		l.problems = append(l.problems, fmt.Errorf("%s: %q is not true or false", key, value))
+		return defaultValue
+	}
+	return boolValue
+}
+
+func (l *loader) list(key string) []string {
+	value, _ := l.raw(key)
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// tags parses a list of key=value pairs
+func (l *loader) tags(key string) map[string]string {
+	tags := make(map[string]string)
+	for _, item := range l.list(key) {
+		tagKey, tagValue, ok := strings.Cut(item, "=")
+		tagKey = strings.TrimSpace(tagKey)
+		if !ok || tagKey == "" {
+			l.problems = append(l.problems, fmt.Errorf("%s: %q is not of the form key=value", key, item))
+			continue
+		}
+		tags[tagKey] = strings.TrimSpace(tagValue)
+	}
+	return tags
+}
+
+// weights parses a list of name=weight pairs, falling back to defaultValue
+func (l *loader) weights(key, defaultValue string) map[string]float64 {
+	items := l.list(key)
+	if len(items) == 0 {
+		items = strings.Split(defaultValue, ",")
+	}
+	weights := make(map[string]float64)
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, "=")
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil {
+			l.problems = append(l.problems, fmt.Errorf("%s: %q is not of the form signal=weight", key, item))
+			continue
+		}
+		weights[strings.TrimSpace(name)] = weight
+	}
+	return weights
+}
+
+// Load reads the configuration through lookup and validates it. The returned
+// error lists every problem found, one per line.
+func Load(lookup func(string) (string, bool)) (*Config, error) {
+	l := &loader{lookup: lookup, values: make(map[string]string)}
+
+	cfg := &Config{
+		ClusterIdentifier:            l.string("CLUSTER_IDENTIFIER", ""),
+		MaxReadReplicas:              l.int("MAX_READ_REPLICAS", 10),
+		MinReadReplicas:              l.int("MIN_READ_REPLICAS", 1),
+		InstanceClass:                l.string("INSTANCE_CLASS", "db.r6g.large"),
+		FallbackInstanceClasses:      l.list("FALLBACK_INSTANCE_CLASSES"),
+		CooldownMinutes:              l.int("COOLDOWN_MINUTES", 15),
+		CPUScaleOutThreshold:         l.float("CPU_SCALE_OUT_THRESHOLD", 70.0),
+		CPUScaleInThreshold:          l.float("CPU_SCALE_IN_THRESHOLD", 30.0),
+		ConnectionsScaleOutThreshold: l.float("CONNECTIONS_SCALE_OUT_THRESHOLD", 400.0),
+		EvaluationPeriods:            l.int("EVALUATION_PERIODS", 3),
+		DecisionWindowMinutes:        l.int("DECISION_WINDOW_MINUTES", 5),
+		ReaderNameTemplate:           l.string("READER_NAME_TEMPLATE", naming.DefaultTemplate),
+		LockBackend:                  l.string("LOCK_BACKEND", "tag"),
+		LockTTLSeconds:               l.int("LOCK_TTL_SECONDS", 300),
+		PolicySource:                 l.string("POLICY_SOURCE", ""),
+		PolicyCacheSeconds:           l.int("POLICY_CACHE_SECONDS", 60),
+		MaxActionsPerRun:             l.int("MAX_ACTIONS_PER_RUN", 1),
+		ReaderTags:                   l.tags("READER_TAGS"),
+		MaxCreatesPerHour:            l.int("MAX_CREATES_PER_HOUR", 6),
+		MaxCreatesPerDay:             l.int("MAX_CREATES_PER_DAY", 20),
+		MaxDeletesPerHour:            l.int("MAX_DELETES_PER_HOUR", 6),
+		MaxDeletesPerDay:             l.int("MAX_DELETES_PER_DAY", 20),
+		HistoryBackend:               l.string("HISTORY_BACKEND", "tag"),
+		AlertTopicArn:                l.string("ALERT_TOPIC_ARN", ""),
+		PriceTable:                   l.string("PRICE_TABLE", ""),
+		PriceSnapshot:                l.bool("PRICE_SNAPSHOT", true),
+		HourlyBudget:                 l.float("HOURLY_BUDGET", 0),
+		Environment:                  l.string("ENVIRONMENT", ""),
+		Profile:                      l.string("PROFILE", ""),
+		VerticalScaling:              l.bool("VERTICAL_SCALING", true),
+		DecisionPolicy:               l.string("DECISION_POLICY", "threshold"),
+		ScoreWeights:                 l.weights("SCORE_WEIGHTS", defaultScoreWeights),
+		ScoreScaleOut:                l.float("SCORE_SCALE_OUT", 1.0),
+		ScoreScaleIn:                 l.float("SCORE_SCALE_IN", 0.4),
+		ReplicaLagThreshold:          l.float("REPLICA_LAG_THRESHOLD_MS", 2000),
+		FreeableMemoryThreshold:      l.float("FREEABLE_MEMORY_THRESHOLD_MB", 512),
+		values:                       l.values,
+	}
+
+	problems := append(l.problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return cfg, errors.Join(problems...)
+	}
+	return cfg, nil
+}
+
+// FromEnv loads the configuration from the process environment
+func FromEnv() (*Config, error) {
+	return Load(os.LookupEnv)
+}
+
+// FromEnvFile loads the configuration from a KEY=VALUE file, as used by CI
+// to check environment files before a deploy
+func FromEnvFile(path string) (*Config, error) {
+	values, err := ReadEnvFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	})
+}
+
+// ReadEnvFile parses KEY=VALUE lines, ignoring blank lines, comments and a leading "export"
+func ReadEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNumber)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	return values, nil
+}
+
+// Validate checks ranges and cross-field constraints, listing every problem
+// found one per line
+func (c *Config) Validate() error {
+	return errors.Join(c.validate()...)
+}
+
+// Clone returns a copy that can be modified without affecting c
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.FallbackInstanceClasses = append([]string(nil), c.FallbackInstanceClasses...)
+	clone.ReaderTags = make(map[string]string, len(c.ReaderTags))
+	for key, value := range c.ReaderTags {
+		clone.ReaderTags[key] = value
+	}
+	clone.ScoreWeights = make(map[string]float64, len(c.ScoreWeights))
+	for name, weight := range c.ScoreWeights {
+		clone.ScoreWeights[name] = weight
+	}
+	clone.Rules = append([]Rule(nil), c.Rules...)
+	clone.overrides = make(map[string]string, len(c.overrides))
+	for key, source := range c.overrides {
+		clone.overrides[key] = source
+	}
+	return &clone
+}
+
+// Override records that the variable key was replaced by source, for example
+// a policy document, so that Summary reports where the value came from
+func (c *Config) Override(key, source string) {
+	if c.overrides == nil {
+		c.overrides = make(map[string]string)
+	}
+	c.overrides[key] = source
+}
+
+// IsSet reports whether the variable key was set explicitly, either in the
+// environment or by an override
+func (c *Config) IsSet(key string) bool {
+	_, overridden := c.overrides[key]
+	return c.values[key] != "" || overridden
+}
+
+// validate checks ranges and cross-field constraints
+func (c *Config) validate() []error {
+	var problems []error
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.ClusterIdentifier == "" {
+		problemf("CLUSTER_IDENTIFIER is required")
+	} else if err := naming.ValidateIdentifier(c.ClusterIdentifier); err != nil {
+		problemf("CLUSTER_IDENTIFIER: %v", err)
+	}
+
+	if c.MinReadReplicas < 0 {
+		problemf("MIN_READ_REPLICAS must not be negative, got %d", c.MinReadReplicas)
+	}
+	if c.MaxReadReplicas < 1 || c.MaxReadReplicas > MaxReadReplicasLimit {
+		problemf("MAX_READ_REPLICAS must be between 1 and %d, got %d", MaxReadReplicasLimit, c.MaxReadReplicas)
+	}
+	if c.MinReadReplicas > c.MaxReadReplicas {
+		problemf("MIN_READ_REPLICAS (%d) must not exceed MAX_READ_REPLICAS (%d)", c.MinReadReplicas, c.MaxReadReplicas)
+	}
+
+	for _, class := range append([]string{c.InstanceClass}, c.FallbackInstanceClasses...) {
+		if !instanceClassPattern.MatchString(class) {
+			problemf("instance class %q is not of the form db.<family>.<size>", class)
+		}
+	}
+
+	if c.CooldownMinutes < 0 {
+		problemf("COOLDOWN_MINUTES must not be negative, got %d", c.CooldownMinutes)
+	}
+	if c.CPUScaleOutThreshold <= 0 || c.CPUScaleOutThreshold > 100 {
+		problemf("CPU_SCALE_OUT_THRESHOLD must be in (0, 100], got %g", c.CPUScaleOutThreshold)
+	}
+	if c.CPUScaleInThreshold < 0 || c.CPUScaleInThreshold > 100 {
+		problemf("CPU_SCALE_IN_THRESHOLD must be in [0, 100], got %g", c.CPUScaleInThreshold)
+	}
+	if c.CPUScaleInThreshold >= c.CPUScaleOutThreshold {
+		problemf("CPU_SCALE_IN_THRESHOLD (%g) must be below CPU_SCALE_OUT_THRESHOLD (%g)", c.CPUScaleInThreshold, c.CPUScaleOutThreshold)
+	}
+	if c.ConnectionsScaleOutThreshold <= 0 {
+		problemf("CONNECTIONS_SCALE_OUT_THRESHOLD must be positive, got %g", c.ConnectionsScaleOutThreshold)
+	}
+	if c.EvaluationPeriods < 1 {
+		problemf("EVALUATION_PERIODS must be at least 1, got %d", c.EvaluationPeriods)
+	}
+	if c.DecisionWindowMinutes < 1 {
+		problemf("DECISION_WINDOW_MINUTES must be at least 1, got %d", c.DecisionWindowMinutes)
+	}
+
+	if _, err := naming.Parse(c.ReaderNameTemplate); err != nil {
+		problemf("READER_NAME_TEMPLATE: %v", err)
+	}
+
+	switch c.LockBackend {
+	case "tag", "memory", "none":
+	default:
+		problemf("LOCK_BACKEND must be tag, memory or none, got %q", c.LockBackend)
+	}
+	if c.LockTTLSeconds < 1 {
+		problemf("LOCK_TTL_SECONDS must be at least 1, got %d", c.LockTTLSeconds)
+	}
+
+	if c.PolicySource != "" {
+		scheme, location, _ := strings.Cut(c.PolicySource, ":")
+		switch {
+		case scheme != "ssm" && scheme != "appconfig" && scheme != "file":
+			problemf("POLICY_SOURCE must start with ssm:, appconfig: or file:, got %q", c.PolicySource)
+		case location == "":
+			problemf("POLICY_SOURCE %q has no location", c.PolicySource)
+		case scheme == "appconfig" && strings.Count(location, "/") != 2:
+			problemf("POLICY_SOURCE %q must be appconfig:<application>/<environment>/<profile>", c.PolicySource)
+		}
+	}
+	if c.PolicyCacheSeconds < 0 {
+		problemf("POLICY_CACHE_SECONDS must not be negative, got %d", c.PolicyCacheSeconds)
+	}
+
+	if c.MaxActionsPerRun < 1 || c.MaxActionsPerRun > MaxReadReplicasLimit {
+		problemf("MAX_ACTIONS_PER_RUN must be between 1 and %d, got %d", MaxReadReplicasLimit, c.MaxActionsPerRun)
+	}
+	limits := []struct {
+		name string
+		max  int
+	}{
+		{"MAX_CREATES_PER_HOUR", c.MaxCreatesPerHour},
+		{"MAX_CREATES_PER_DAY", c.MaxCreatesPerDay},
+		{"MAX_DELETES_PER_HOUR", c.MaxDeletesPerHour},
+		{"MAX_DELETES_PER_DAY", c.MaxDeletesPerDay},
+	}
+	for _, limit := range limits {
+		if limit.max < 0 || limit.max > history.TagCapacity {
+			problemf("%s must be between 0 (no limit) and %d, got %d", limit.name, history.TagCapacity, limit.max)
+		}
+	}
+	if c.MaxCreatesPerDay > 0 && c.MaxCreatesPerHour > c.MaxCreatesPerDay {
+		problemf("MAX_CREATES_PER_HOUR (%d) must not exceed MAX_CREATES_PER_DAY (%d)", c.MaxCreatesPerHour, c.MaxCreatesPerDay)
+	}
+	if c.MaxDeletesPerDay > 0 && c.MaxDeletesPerHour > c.MaxDeletesPerDay {
+		problemf("MAX_DELETES_PER_HOUR (%d) must not exceed MAX_DELETES_PER_DAY (%d)", c.MaxDeletesPerHour, c.MaxDeletesPerDay)
+	}
+	switch c.HistoryBackend {
+	case "tag", "memory", "none":
+	default:
+		problemf("HISTORY_BACKEND must be tag, memory or none, got %q", c.HistoryBackend)
+	}
+	if c.AlertTopicArn != "" && !strings.HasPrefix(c.AlertTopicArn, "arn:") {
+		problemf("ALERT_TOPIC_ARN must be an SNS topic ARN, got %q", c.AlertTopicArn)
+	}
+
+	if c.HourlyBudget < 0 {
+		problemf("HOURLY_BUDGET must not be negative, got %g", c.HourlyBudget)
+	}
+	if c.HourlyBudget > 0 && c.PriceTable == "" && !c.PriceSnapshot {
+		problemf("HOURLY_BUDGET needs prices: set PRICE_TABLE or PRICE_SNAPSHOT=true")
+	}
+
+	switch c.Profile {
+	case "", "prod", "staging", "ephemeral":
+	default:
+		problemf("PROFILE must be prod, staging or ephemeral, got %q", c.Profile)
+	}
+
+	switch c.DecisionPolicy {
+	case "threshold", "score", "rules":
+	default:
+		problemf("DECISION_POLICY must be threshold, score or rules, got %q", c.DecisionPolicy)
+	}
+	var totalWeight float64
+	for name, weight := range c.ScoreWeights {
+		if !contains(ScoreSignals, name) {
+			problemf("SCORE_WEIGHTS: unknown signal %q, must be one of %s", name, strings.Join(ScoreSignals, ", "))
+		}
+		if weight < 0 {
+			problemf("SCORE_WEIGHTS: weight of %s must not be negative, got %g", name, weight)
+		}
+		totalWeight += weight
+	}
+	if totalWeight <= 0 {
+		problemf("SCORE_WEIGHTS must give at least one signal a positive weight")
+	}
+	if c.ScoreScaleIn < 0 || c.ScoreScaleIn >= c.ScoreScaleOut {
+		problemf("SCORE_SCALE_IN (%g) must be at least 0 and below SCORE_SCALE_OUT (%g)", c.ScoreScaleIn, c.ScoreScaleOut)
+	}
+	if c.ReplicaLagThreshold <= 0 {
+		problemf("REPLICA_LAG_THRESHOLD_MS must be positive, got %g", c.ReplicaLagThreshold)
+	}
+	if c.FreeableMemoryThreshold <= 0 {
+		problemf("FREEABLE_MEMORY_THRESHOLD_MB must be positive, got %g", c.FreeableMemoryThreshold)
+	}
+
+	problems = append(problems, c.validateRules()...)
+
+	for key := range c.ReaderTags {
+		if strings.HasPrefix(key, "autoscaler:") {
+			problemf("READER_TAGS: key %q uses the reserved autoscaler: prefix", key)
+		}
+	}
+
+	return problems
+}
+
+// validateRules compiles every rule, keeping the compiled condition
+func (c *Config) validateRules() []error {
+	var problems []error
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.DecisionPolicy == "rules" && len(c.Rules) == 0 {
+		problemf("DECISION_POLICY=rules needs at least one rule in the policy document")
+	}
+	if c.DecisionPolicy != "rules" && len(c.Rules) > 0 {
+		problemf("rules are only evaluated with DECISION_POLICY=rules, got %q", c.DecisionPolicy)
+	}
+
+	names := make(map[string]bool)
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			problemf("rule %d has no name", i+1)
+		} else if names[rule.Name] {
+			problemf("rule %q is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if rule.Action != "scale_out" && rule.Action != "scale_in" {
+			problemf("rule %q: action must be scale_out or scale_in, got %q", rule.Name, rule.Action)
+		}
+		condition, err := rules.Compile(rule.When)
+		if err != nil {
+			if compileErr, ok := err.(*rules.Error); ok {
+				problemf("rule %q: %v\n%s", rule.Name, err, compileErr.Caret("    "))
+			} else {
+				problemf("rule %q: %v", rule.Name, err)
+			}
+			continue
+		}
+		if span := condition.Span(c.EvaluationPeriods); span > maxRuleMinutes {
+			problemf("rule %q reads %d minutes of data, at most %d are allowed", rule.Name, span, maxRuleMinutes)
+		}
+		rule.Condition = condition
+	}
+	return problems
+}
+
+// Summary describes the effective configuration, one variable per line,
+// marking the ones that fell back to their default or were overridden
+func (c *Config) Summary() string {
+	effective := map[string]string{
+		"CLUSTER_IDENTIFIER":              c.ClusterIdentifier,
+		"MAX_READ_REPLICAS":               strconv.Itoa(c.MaxReadReplicas),
+		"MIN_READ_REPLICAS":               strconv.Itoa(c.MinReadReplicas),
+		"INSTANCE_CLASS":                  c.InstanceClass,
+		"FALLBACK_INSTANCE_CLASSES":       strings.Join(c.FallbackInstanceClasses, ","),
+		"COOLDOWN_MINUTES":                strconv.Itoa(c.CooldownMinutes),
+		"CPU_SCALE_OUT_THRESHOLD":         strconv.FormatFloat(c.CPUScaleOutThreshold, 'g', -1, 64),
+		"CPU_SCALE_IN_THRESHOLD":          strconv.FormatFloat(c.CPUScaleInThreshold, 'g', -1, 64),
+		"CONNECTIONS_SCALE_OUT_THRESHOLD": strconv.FormatFloat(c.ConnectionsScaleOutThreshold, 'g', -1, 64),
+		"EVALUATION_PERIODS":              strconv.Itoa(c.EvaluationPeriods),
+		"DECISION_WINDOW_MINUTES":         strconv.Itoa(c.DecisionWindowMinutes),
+		"READER_NAME_TEMPLATE":            c.ReaderNameTemplate,
+		"LOCK_BACKEND":                    c.LockBackend,
+		"LOCK_TTL_SECONDS":                strconv.Itoa(c.LockTTLSeconds),
+		"POLICY_SOURCE":                   c.PolicySource,
+		"POLICY_CACHE_SECONDS":            strconv.Itoa(c.PolicyCacheSeconds),
+		"MAX_ACTIONS_PER_RUN":             strconv.Itoa(c.MaxActionsPerRun),
+		"READER_TAGS":                     formatTags(c.ReaderTags),
+		"MAX_CREATES_PER_HOUR":            strconv.Itoa(c.MaxCreatesPerHour),
+		"MAX_CREATES_PER_DAY":             strconv.Itoa(c.MaxCreatesPerDay),
+		"MAX_DELETES_PER_HOUR":            strconv.Itoa(c.MaxDeletesPerHour),
+		"MAX_DELETES_PER_DAY":             strconv.Itoa(c.MaxDeletesPerDay),
+		"HISTORY_BACKEND":                 c.HistoryBackend,
+		"ALERT_TOPIC_ARN":                 c.AlertTopicArn,
+		"PRICE_TABLE":                     c.PriceTable,
+		"PRICE_SNAPSHOT":                  strconv.FormatBool(c.PriceSnapshot),
+		"HOURLY_BUDGET":                   strconv.FormatFloat(c.HourlyBudget, 'g', -1, 64),
+		"ENVIRONMENT":                     c.Environment,
+		"PROFILE":                         c.Profile,
+		"VERTICAL_SCALING":                strconv.FormatBool(c.VerticalScaling),
+		"DECISION_POLICY":                 c.DecisionPolicy,
+		"SCORE_WEIGHTS":                   formatWeights(c.ScoreWeights),
+		"SCORE_SCALE_OUT":                 strconv.FormatFloat(c.ScoreScaleOut, 'g', -1, 64),
+		"SCORE_SCALE_IN":                  strconv.FormatFloat(c.ScoreScaleIn, 'g', -1, 64),
+		"REPLICA_LAG_THRESHOLD_MS":        strconv.FormatFloat(c.ReplicaLagThreshold, 'g', -1, 64),
+		"FREEABLE_MEMORY_THRESHOLD_MB":    strconv.FormatFloat(c.FreeableMemoryThreshold, 'g', -1, 64),
+		"RULES":                           formatRules(c.Rules),
+	}
+
+	keys := make([]string, 0, len(effective))
+	for key := range effective {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		source := ""
+		if override, ok := c.overrides[key]; ok {
+			source = " (" + override + ")"
+		} else if c.values[key] == "" {
+			source = " (default)"
+		}
+		fmt.Fprintf(&b, "  %-32s %s%s\n", key, effective[key], source)
+	}
+	return b.String()
+}
+
+// formatTags renders tags as sorted key=value pairs
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// formatWeights renders score weights as name=weight pairs in signal order
+func formatWeights(weights map[string]float64) string {
+	var pairs []string
+	for _, name := range ScoreSignals {
+		if weight, ok := weights[name]; ok {
+			pairs = append(pairs, name+"="+strconv.FormatFloat(weight, 'g', -1, 64))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// formatRules renders the names of rules in order
+func formatRules(rules []Rule) string {
+	names := make([]string, len(rules))
+	for i, rule := range rules {
+		names[i] = rule.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func lookupFrom(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(lookupFrom(map[string]string{"CLUSTER_IDENTIFIER": "test-cluster"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.MaxReadReplicas != 10 || cfg.MinReadReplicas != 1 {
+		t.Errorf("Expected default replicas 1..10, got %d..%d", cfg.MinReadReplicas, cfg.MaxReadReplicas)
+	}
+	if cfg.InstanceClass != "db.r6g.large" {
+		t.Errorf("Expected default instance class 'db.r6g.large', got '%s'", cfg.InstanceClass)
+	}
+	if !strings.Contains(cfg.Summary(), "MAX_READ_REPLICAS") || !strings.Contains(cfg.Summary(), "(default)") {
+		t.Errorf("Expected summary to list defaulted settings, got:\n%s", cfg.Summary())
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	_, err := Load(lookupFrom(map[string]string{
+		"MAX_READ_REPLICAS":       "ten",
+		"MIN_READ_REPLICAS":       "12",
+		"CPU_SCALE_OUT_THRESHOLD": "40",
+		"CPU_SCALE_IN_THRESHOLD":  "50",
+		"LOCK_BACKEND":            "redis",
+		"SCORE_WEIGHTS":           "writer_cpu=2,disk=1",
+	}))
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+
+	expected := []string{
+		"CLUSTER_IDENTIFIER is required",
+		`MAX_READ_REPLICAS: "ten" is not an integer`,
+		"MIN_READ_REPLICAS (12) must not exceed MAX_READ_REPLICAS (10)",
+		"CPU_SCALE_IN_THRESHOLD (50) must be below CPU_SCALE_OUT_THRESHOLD (40)",
+		"LOCK_BACKEND must be tag, memory or none",
+		`SCORE_WEIGHTS: unknown signal "disk"`,
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected error to contain %q, got:\n%v", problem, err)
+		}
+	}
+}
+
+func TestFromEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autoscaler.env")
+	content := `# autoscaler settings
+export CLUSTER_IDENTIFIER="prod-cluster"
+MAX_READ_REPLICAS=5
+FALLBACK_INSTANCE_CLASSES=db.r5.large, db.r6g.xlarge
+READER_TAGS=team=platform, cost-center=42
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := FromEnvFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.ClusterIdentifier != "prod-cluster" {
+		t.Errorf("Expected cluster identifier 'prod-cluster', got '%s'", cfg.ClusterIdentifier)
+	}
+	if cfg.MaxReadReplicas != 5 {
+		t.Errorf("Expected 5 max replicas, got %d", cfg.MaxReadReplicas)
+	}
+	if len(cfg.FallbackInstanceClasses) != 2 || cfg.FallbackInstanceClasses[1] != "db.r6g.xlarge" {
+		t.Errorf("Expected two fallback classes, got %v", cfg.FallbackInstanceClasses)
+	}
+	if len(cfg.ReaderTags) != 2 || cfg.ReaderTags["cost-center"] != "42" {
+		t.Errorf("Expected two reader tags, got %v", cfg.ReaderTags)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	cfg, err := Load(lookupFrom(map[string]string{"CLUSTER_IDENTIFIER": "test-cluster"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg.DecisionPolicy = "rules"
+	cfg.Rules = []Rule{
+		{Name: "hot", Action: "scale_out", When: "writer.cpu.p90 > && readers.lag.max < 2000"},
+		{Name: "hot", Action: "grow", When: "connections.writer > 400 for 2h"},
+		{Name: "quiet", Action: "scale_in", When: "writer.cpu.max < 30"},
+	}
+
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	expected := []string{
+		`rule "hot": column 18: expected a number or metric, got "&&"`,
+		`rule "hot" is defined twice`,
+		`rule "hot": action must be scale_out or scale_in, got "grow"`,
+		`rule "hot" reads 120 minutes of data, at most 60 are allowed`,
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected error to contain %q, got:\n%v", problem, err)
+		}
+	}
+	if cfg.Rules[2].Condition == nil {
+		t.Error("Expected the valid rule to be compiled")
+	}
+}
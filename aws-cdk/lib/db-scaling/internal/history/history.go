@@ -0,0 +1,69 @@
+// Package history records when the autoscaler created or deleted readers, so
+// that rate limits can be enforced across invocations.
+package history
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Retention is how long entries are kept; the longest rate-limit period
+const Retention = 24 * time.Hour
+
+// Recorder keeps the times of past actions per cluster and kind of action
+type Recorder interface {
+	// Record adds an action of the given kind that happened at t
+	Record(ctx context.Context, cluster, kind string, t time.Time) error
+	// Since returns the times of actions of the given kind after t, oldest first
+	Since(ctx context.Context, cluster, kind string, t time.Time) ([]time.Time, error)
+}
+
+// Noop records nothing, which disables rate limits
+type Noop struct{}
+
+func (Noop) Record(ctx context.Context, cluster, kind string, t time.Time) error { return nil }
+
+func (Noop) Since(ctx context.Context, cluster, kind string, t time.Time) ([]time.Time, error) {
+	return nil, nil
+}
+
+// Memory is an in-process Recorder, used in tests and single-instance setups
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string][]time.Time
+}
+
+// NewMemory creates an empty in-memory recorder
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string][]time.Time)}
+}
+
+func (m *Memory) Record(ctx context.Context, cluster, kind string, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := cluster + "/" + kind
+	m.entries[key] = prune(append(m.entries[key], t), t.Add(-Retention))
+	return nil
+}
+
+func (m *Memory) Since(ctx context.Context, cluster, kind string, t time.Time) ([]time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return prune(m.entries[cluster+"/"+kind], t), nil
+}
+
+// prune returns the times after cutoff, oldest first
+func prune(times []time.Time, cutoff time.Time) []time.Time {
+	var kept []time.Time
+	for _, t := range times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Before(kept[j]) })
+	return kept
+}
@@ -0,0 +1,56 @@
+package history
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemorySince(t *testing.T) {
+	ctx := context.Background()
+	recorder := NewMemory()
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, age := range []time.Duration{30 * time.Hour, 3 * time.Hour, 30 * time.Minute, 5 * time.Minute} {
+		recorder.Record(ctx, "prod", "create", now.Add(-age))
+	}
+	recorder.Record(ctx, "prod", "delete", now)
+
+	lastHour, _ := recorder.Since(ctx, "prod", "create", now.Add(-time.Hour))
+	if len(lastHour) != 2 {
+		t.Errorf("Expected 2 creates in the last hour, got %v", lastHour)
+	}
+	lastDay, _ := recorder.Since(ctx, "prod", "create", now.Add(-24*time.Hour))
+	if len(lastDay) != 3 {
+		t.Errorf("Expected 3 creates in the last day, got %v", lastDay)
+	}
+	other, _ := recorder.Since(ctx, "staging", "create", now.Add(-24*time.Hour))
+	if len(other) != 0 {
+		t.Errorf("Expected no history for another cluster, got %v", other)
+	}
+}
+
+func TestEncodeFitsOneTag(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	var times []time.Time
+	for i := 0; i < 2*TagCapacity; i++ {
+		times = append(times, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	value := encode(times)
+	if len(value) > maxTagValue {
+		t.Errorf("Expected at most %d characters, got %d", maxTagValue, len(value))
+	}
+
+	decoded := decode(value + " not-a-time!")
+	if len(decoded) < TagCapacity {
+		t.Errorf("Expected at least %d entries to fit, got %d", TagCapacity, len(decoded))
+	}
+	if !decoded[len(decoded)-1].Equal(times[len(times)-1]) {
+		t.Errorf("Expected the newest entry to be kept, got %v", decoded[len(decoded)-1])
+	}
+	if strings.Contains(value, "  ") {
+		t.Errorf("Unexpected empty field in %q", value)
+	}
+}
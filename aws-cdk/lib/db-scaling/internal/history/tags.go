@@ -0,0 +1,118 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/docdb"
+	"github.com/aws/aws-sdk-go/service/docdb/docdbiface"
+
+	"docdb-auto-scaling/internal/clusterarn"
+)
+
+const (
+	tagPrefix = "autoscaler:history-"
+
+	// maxTagValue is the DocumentDB limit on tag values
+	maxTagValue = 256
+)
+
+// TagCapacity is the number of entries one tag can hold. Rate limits above
+// it can't be enforced by ClusterTag.
+const TagCapacity = maxTagValue / 7
+
+// ClusterTag is a Recorder that keeps each kind of action as a tag on the
+// DocumentDB cluster, so every autoscaler instance sees the same history
+// without extra infrastructure. The value is a space-separated list of
+// base-36 Unix timestamps, trimmed to Retention and TagCapacity entries.
+// Callers are expected to hold the cluster's scaling lock while recording.
+type ClusterTag struct {
+	client docdbiface.DocDBAPI
+	arns   *clusterarn.Cache
+}
+
+// NewClusterTag creates a cluster-tag recorder
+func NewClusterTag(client docdbiface.DocDBAPI) *ClusterTag {
+	return &ClusterTag{client: client, arns: clusterarn.NewCache(client)}
+}
+
+func (c *ClusterTag) Record(ctx context.Context, cluster, kind string, t time.Time) error {
+	times, err := c.read(ctx, cluster, kind)
+	if err != nil {
+		return err
+	}
+	times = prune(append(times, t), t.Add(-Retention))
+
+	arn, err := c.arns.Get(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.AddTagsToResourceWithContext(ctx, &docdb.AddTagsToResourceInput{
+		ResourceName: aws.String(arn),
+		Tags:         []*docdb.Tag{{Key: aws.String(tagPrefix + kind), Value: aws.String(encode(times))}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s history of %s: %w", kind, cluster, err)
+	}
+	return nil
+}
+
+func (c *ClusterTag) Since(ctx context.Context, cluster, kind string, t time.Time) ([]time.Time, error) {
+	times, err := c.read(ctx, cluster, kind)
+	if err != nil {
+		return nil, err
+	}
+	return prune(times, t), nil
+}
+
+func (c *ClusterTag) read(ctx context.Context, cluster, kind string) ([]time.Time, error) {
+	arn, err := c.arns.Get(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.client.ListTagsForResourceWithContext(ctx, &docdb.ListTagsForResourceInput{
+		ResourceName: aws.String(arn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s history of %s: %w", kind, cluster, err)
+	}
+	for _, tag := range result.TagList {
+		if aws.StringValue(tag.Key) == tagPrefix+kind {
+			return decode(aws.StringValue(tag.Value)), nil
+		}
+	}
+	return nil, nil
+}
+
+// encode renders times as base-36 Unix seconds, dropping the oldest entries
+// that don't fit in one tag value
+func encode(times []time.Time) string {
+	fields := make([]string, len(times))
+	for i, t := range times {
+		fields[i] = strconv.FormatInt(t.Unix(), 36)
+	}
+	value := strings.Join(fields, " ")
+	for len(value) > maxTagValue {
+		fields = fields[1:]
+		value = strings.Join(fields, " ")
+	}
+	return value
+}
+
+// decode parses a tag value, skipping malformed entries
+func decode(value string) []time.Time {
+	var times []time.Time
+	for _, field := range strings.Fields(value) {
+		seconds, err := strconv.ParseInt(field, 36, 64)
+		if err != nil {
+			continue
+		}
+		times = append(times, time.Unix(seconds, 0).UTC())
+	}
+	return times
+}
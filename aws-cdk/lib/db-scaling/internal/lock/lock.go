@@ -0,0 +1,53 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrLocked is returned by Acquire when another owner holds an unexpired lease
+	ErrLocked = errors.New("lock is held by another owner")
+
+	// ErrLeaseLost is returned when a lease expired or was taken over by a newer holder
+	ErrLeaseLost = errors.New("lease is no longer held")
+)
+
+// Lease is a time-limited claim on a lock. Token is a fencing token that
+// increases with every acquisition of the same key, so a holder whose lease
+// was taken over can detect it before acting.
+type Lease struct {
+	Key       string
+	Owner     string
+	Token     int64
+	ExpiresAt time.Time
+}
+
+func (l *Lease) String() string {
+	return fmt.Sprintf("%s (owner %s, token %d, expires %s)", l.Key, l.Owner, l.Token, l.ExpiresAt.Format(time.RFC3339))
+}
+
+// Locker is a lease-based lock held per cluster for the duration of an invocation
+type Locker interface {
+	// Acquire takes the lock for key unless another owner holds an unexpired lease
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (*Lease, error)
+
+	// Validate returns ErrLeaseLost if the lease is expired or has been superseded
+	Validate(ctx context.Context, lease *Lease) error
+
+	// Release gives up the lease; releasing a lost lease is a no-op
+	Release(ctx context.Context, lease *Lease) error
+}
+
+// Noop is a Locker that always succeeds, used when locking is disabled
+type Noop struct{}
+
+func (Noop) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (*Lease, error) {
+	return &Lease{Key: key, Owner: owner, ExpiresAt: time.Now().Add(ttl)}, nil
+}
+
+func (Noop) Validate(ctx context.Context, lease *Lease) error { return nil }
+
+func (Noop) Release(ctx context.Context, lease *Lease) error { return nil }
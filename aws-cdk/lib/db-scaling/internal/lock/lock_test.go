@@ -0,0 +1,118 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/docdb"
+	"github.com/aws/aws-sdk-go/service/docdb/docdbiface"
+)
+
+func TestMemoryLock(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	locker := NewMemory()
+	locker.now = func() time.Time { return now }
+
+	first, err := locker.Acquire(ctx, "cluster", "invocation-1", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := locker.Acquire(ctx, "cluster", "invocation-2", 5*time.Minute); err != ErrLocked {
+		t.Errorf("Expected ErrLocked while the lease is held, got %v", err)
+	}
+
+	// Once the lease expires a new owner takes over with a higher fencing token
+	now = now.Add(6 * time.Minute)
+	second, err := locker.Acquire(ctx, "cluster", "invocation-2", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.Token <= first.Token {
+		t.Errorf("Expected fencing token to increase, got %d then %d", first.Token, second.Token)
+	}
+	if err := locker.Validate(ctx, first); err != ErrLeaseLost {
+		t.Errorf("Expected the first lease to be lost, got %v", err)
+	}
+	if err := locker.Validate(ctx, second); err != nil {
+		t.Errorf("Expected the second lease to be valid, got %v", err)
+	}
+
+	// Releasing a stale lease must not free the lock held by the new owner
+	locker.Release(ctx, first)
+	if _, err := locker.Acquire(ctx, "cluster", "invocation-3", 5*time.Minute); err != ErrLocked {
+		t.Errorf("Expected ErrLocked after releasing a stale lease, got %v", err)
+	}
+
+	locker.Release(ctx, second)
+	if _, err := locker.Acquire(ctx, "cluster", "invocation-3", 5*time.Minute); err != nil {
+		t.Errorf("Expected lock to be free after release, got %v", err)
+	}
+}
+
+// fakeTagClient keeps cluster tags in memory
+type fakeTagClient struct {
+	docdbiface.DocDBAPI
+	tags map[string]string
+}
+
+func (f *fakeTagClient) DescribeDBClustersWithContext(ctx aws.Context, input *docdb.DescribeDBClustersInput, opts ...request.Option) (*docdb.DescribeDBClustersOutput, error) {
+	return &docdb.DescribeDBClustersOutput{DBClusters: []*docdb.DBCluster{
+		{DBClusterArn: aws.String("arn:aws:rds:us-east-1:123456789012:cluster:" + aws.StringValue(input.DBClusterIdentifier))},
+	}}, nil
+}
+
+func (f *fakeTagClient) ListTagsForResourceWithContext(ctx aws.Context, input *docdb.ListTagsForResourceInput, opts ...request.Option) (*docdb.ListTagsForResourceOutput, error) {
+	output := &docdb.ListTagsForResourceOutput{}
+	for key, value := range f.tags {
+		output.TagList = append(output.TagList, &docdb.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return output, nil
+}
+
+func (f *fakeTagClient) AddTagsToResourceWithContext(ctx aws.Context, input *docdb.AddTagsToResourceInput, opts ...request.Option) (*docdb.AddTagsToResourceOutput, error) {
+	for _, tag := range input.Tags {
+		f.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return &docdb.AddTagsToResourceOutput{}, nil
+}
+
+func (f *fakeTagClient) RemoveTagsFromResourceWithContext(ctx aws.Context, input *docdb.RemoveTagsFromResourceInput, opts ...request.Option) (*docdb.RemoveTagsFromResourceOutput, error) {
+	for _, key := range input.TagKeys {
+		delete(f.tags, aws.StringValue(key))
+	}
+	return &docdb.RemoveTagsFromResourceOutput{}, nil
+}
+
+func TestClusterTagLock(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeTagClient{tags: map[string]string{}}
+	locker := NewClusterTag(client)
+	locker.SettleDelay = 0
+
+	first, err := locker.Acquire(ctx, "cluster", "invocation-1", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := locker.Acquire(ctx, "cluster", "invocation-2", 5*time.Minute); err != ErrLocked {
+		t.Errorf("Expected ErrLocked while the lease is held, got %v", err)
+	}
+
+	if err := locker.Release(ctx, first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, err := locker.Acquire(ctx, "cluster", "invocation-2", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.Token != first.Token+1 {
+		t.Errorf("Expected fencing token %d, got %d", first.Token+1, second.Token)
+	}
+	if err := locker.Validate(ctx, first); err != ErrLeaseLost {
+		t.Errorf("Expected the first lease to be lost, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Locker, used in tests and single-instance setups
+type Memory struct {
+	mu     sync.Mutex
+	leases map[string]Lease
+	tokens map[string]int64
+	now    func() time.Time
+}
+
+// NewMemory creates an empty in-memory locker
+func NewMemory() *Memory {
+	return &Memory{
+		leases: make(map[string]Lease),
+		tokens: make(map[string]int64),
+		now:    time.Now,
+	}
+}
+
+func (m *Memory) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (*Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if current, held := m.leases[key]; held && current.Owner != owner && now.Before(current.ExpiresAt) {
+		return nil, ErrLocked
+	}
+
+	m.tokens[key]++
+	lease := Lease{Key: key, Owner: owner, Token: m.tokens[key], ExpiresAt: now.Add(ttl)}
+	m.leases[key] = lease
+	return &lease, nil
+}
+
+func (m *Memory) Validate(ctx context.Context, lease *Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, held := m.leases[lease.Key]
+	if !held || current.Token != lease.Token || !m.now().Before(current.ExpiresAt) {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (m *Memory) Release(ctx context.Context, lease *Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if current, held := m.leases[lease.Key]; held && current.Token == lease.Token {
+		delete(m.leases, lease.Key)
+	}
+	return nil
+}
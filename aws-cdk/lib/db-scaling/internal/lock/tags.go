@@ -0,0 +1,161 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/docdb"
+	"github.com/aws/aws-sdk-go/service/docdb/docdbiface"
+
+	"docdb-auto-scaling/internal/clusterarn"
+)
+
+const (
+	tagOwner   = "autoscaler:lock-owner"
+	tagToken   = "autoscaler:lock-token"
+	tagExpires = "autoscaler:lock-expires"
+)
+
+// ClusterTag is a Locker that stores the lease as tags on the DocumentDB
+// cluster itself. Tags have no native compare-and-set, so Acquire writes its
+// claim and then reads it back after SettleDelay: of two concurrent writers
+// only the one whose tags survived proceeds. Validate repeats the read-back
+// before every mutating call, which catches any remaining overlap through the
+// fencing token.
+type ClusterTag struct {
+	client      docdbiface.DocDBAPI
+	SettleDelay time.Duration
+	arns        *clusterarn.Cache
+	now         func() time.Time
+}
+
+// NewClusterTag creates a cluster-tag locker
+func NewClusterTag(client docdbiface.DocDBAPI) *ClusterTag {
+	return &ClusterTag{
+		client:      client,
+		SettleDelay: 2 * time.Second,
+		arns:        clusterarn.NewCache(client),
+		now:         time.Now,
+	}
+}
+
+func (c *ClusterTag) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (*Lease, error) {
+	current, err := c.read(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.now()
+	if current.Owner != "" && current.Owner != owner && now.Before(current.ExpiresAt) {
+		return nil, ErrLocked
+	}
+
+	lease := &Lease{Key: key, Owner: owner, Token: current.Token + 1, ExpiresAt: now.Add(ttl)}
+	if err := c.write(ctx, lease); err != nil {
+		return nil, err
+	}
+
+	if c.SettleDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.SettleDelay):
+		}
+	}
+
+	if err := c.Validate(ctx, lease); err != nil {
+		if err == ErrLeaseLost {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (c *ClusterTag) Validate(ctx context.Context, lease *Lease) error {
+	current, err := c.read(ctx, lease.Key)
+	if err != nil {
+		return err
+	}
+	if current.Owner != lease.Owner || current.Token != lease.Token || !c.now().Before(current.ExpiresAt) {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (c *ClusterTag) Release(ctx context.Context, lease *Lease) error {
+	if err := c.Validate(ctx, lease); err != nil {
+		if err == ErrLeaseLost {
+			return nil
+		}
+		return err
+	}
+
+	arn, err := c.arns.Get(ctx, lease.Key)
+	if err != nil {
+		return err
+	}
+
+	// The token tag stays so that the next holder gets a higher fencing token
+	_, err = c.client.RemoveTagsFromResourceWithContext(ctx, &docdb.RemoveTagsFromResourceInput{
+		ResourceName: aws.String(arn),
+		TagKeys:      []*string{aws.String(tagOwner), aws.String(tagExpires)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove lock tags from %s: %w", lease.Key, err)
+	}
+	return nil
+}
+
+func (c *ClusterTag) read(ctx context.Context, key string) (Lease, error) {
+	arn, err := c.arns.Get(ctx, key)
+	if err != nil {
+		return Lease{}, err
+	}
+
+	result, err := c.client.ListTagsForResourceWithContext(ctx, &docdb.ListTagsForResourceInput{
+		ResourceName: aws.String(arn),
+	})
+	if err != nil {
+		return Lease{}, fmt.Errorf("failed to read lock tags of %s: %w", key, err)
+	}
+
+	lease := Lease{Key: key}
+	for _, tag := range result.TagList {
+		value := aws.StringValue(tag.Value)
+		switch aws.StringValue(tag.Key) {
+		case tagOwner:
+			lease.Owner = value
+		case tagToken:
+			lease.Token, _ = strconv.ParseInt(value, 10, 64)
+		case tagExpires:
+			if expiresAt, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				lease.ExpiresAt = expiresAt
+			}
+		}
+	}
+	return lease, nil
+}
+
+func (c *ClusterTag) write(ctx context.Context, lease *Lease) error {
+	arn, err := c.arns.Get(ctx, lease.Key)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.AddTagsToResourceWithContext(ctx, &docdb.AddTagsToResourceInput{
+		ResourceName: aws.String(arn),
+		Tags: []*docdb.Tag{
+			{Key: aws.String(tagOwner), Value: aws.String(lease.Owner)},
+			{Key: aws.String(tagToken), Value: aws.String(strconv.FormatInt(lease.Token, 10))},
+			{Key: aws.String(tagExpires), Value: aws.String(lease.ExpiresAt.UTC().Format(time.RFC3339Nano))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write lock tags to %s: %w", lease.Key, err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package naming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultTemplate names readers after the cluster and the decision's idempotency key
+const DefaultTemplate = "{cluster}-reader-{key}"
+
+// MaxIdentifierLength is DocumentDB's limit for instance identifiers
+const MaxIdentifierLength = 63
+
+const (
+	placeholderCluster = "{cluster}"
+	placeholderWindow  = "{window}"
+	placeholderKey     = "{key}"
+)
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+	identifierPattern  = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*$`)
+)
+
+// Template renders deterministic reader instance identifiers
+type Template struct {
+	pattern string
+}
+
+// Parse validates a naming template. Supported placeholders are {cluster},
+// {window} (decision window start as UTC yyyyMMddHHmm) and {key} (short hash
+// of cluster and window). The template must contain {window} or {key} so
+// that different decision windows produce different names.
+func Parse(pattern string) (*Template, error) {
+	if pattern == "" {
+		pattern = DefaultTemplate
+	}
+
+	for _, placeholder := range placeholderPattern.FindAllString(pattern, -1) {
+		switch placeholder {
+		case placeholderCluster, placeholderWindow, placeholderKey:
+		default:
+			return nil, fmt.Errorf("naming template %q: unknown placeholder %s", pattern, placeholder)
+		}
+	}
+
+	if !strings.Contains(pattern, placeholderWindow) && !strings.Contains(pattern, placeholderKey) {
+		return nil, fmt.Errorf("naming template %q must contain %s or %s", pattern, placeholderWindow, placeholderKey)
+	}
+
+	// Render with a sample to catch invalid literal characters early
+	if _, err := (&Template{pattern: pattern}).Render("cluster", time.Unix(0, 0)); err != nil {
+		return nil, err
+	}
+
+	return &Template{pattern: pattern}, nil
+}
+
+// Render returns the identifier for a reader created in the given decision
+// window. The cluster name is shortened if needed to stay within
+// MaxIdentifierLength.
+func (t *Template) Render(cluster string, window time.Time) (string, error) {
+	return t.RenderOrdinal(cluster, window, 0)
+}
+
+// RenderOrdinal returns the identifier of the n-th reader created in window,
+// for plans that add several readers at once. Ordinal 0 is the same as Render;
+// later ordinals change the key, or append "-<n>" if the template has none.
+func (t *Template) RenderOrdinal(cluster string, window time.Time, ordinal int) (string, error) {
+	key := IdempotencyKey(cluster, window)
+	if ordinal > 0 {
+		key = IdempotencyKey(fmt.Sprintf("%s#%d", cluster, ordinal), window)
+	}
+	replacer := strings.NewReplacer(
+		placeholderWindow, window.UTC().Format("200601021504"),
+		placeholderKey, key,
+	)
+	withoutCluster := replacer.Replace(t.pattern)
+	if ordinal > 0 && !strings.Contains(t.pattern, placeholderKey) {
+		withoutCluster += fmt.Sprintf("-%d", ordinal)
+	}
+
+	clusterCount := strings.Count(withoutCluster, placeholderCluster)
+	if clusterCount > 0 {
+		fixedLength := len(withoutCluster) - clusterCount*len(placeholderCluster)
+		available := (MaxIdentifierLength - fixedLength) / clusterCount
+		if available < 1 {
+			return "", fmt.Errorf("naming template %q leaves no room for the cluster name", t.pattern)
+		}
+		if len(cluster) > available {
+			cluster = strings.TrimRight(cluster[:available], "-")
+		}
+	}
+
+	identifier := strings.ReplaceAll(withoutCluster, placeholderCluster, cluster)
+	if err := ValidateIdentifier(identifier); err != nil {
+		return "", fmt.Errorf("naming template %q: %w", t.pattern, err)
+	}
+	return identifier, nil
+}
+
+// IdempotencyKey derives a short, stable key from the cluster and decision window
+func IdempotencyKey(cluster string, window time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", cluster, window.UTC().Unix())))
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// Window returns the start of the decision window containing t
+func Window(t time.Time, size time.Duration) time.Time {
+	if size <= 0 {
+		return t.UTC()
+	}
+	return t.UTC().Truncate(size)
+}
+
+// ValidateIdentifier checks an instance identifier against the DocumentDB naming rules
+func ValidateIdentifier(identifier string) error {
+	switch {
+	case identifier == "":
+		return fmt.Errorf("identifier must not be empty")
+	case len(identifier) > MaxIdentifierLength:
+		return fmt.Errorf("identifier %q is longer than %d characters", identifier, MaxIdentifierLength)
+	case !identifierPattern.MatchString(identifier):
+		return fmt.Errorf("identifier %q must start with a letter and contain only letters, digits and hyphens", identifier)
+	case strings.HasSuffix(identifier, "-"):
+		return fmt.Errorf("identifier %q must not end with a hyphen", identifier)
+	case strings.Contains(identifier, "--"):
+		return fmt.Errorf("identifier %q must not contain two consecutive hyphens", identifier)
+	}
+	return nil
+}
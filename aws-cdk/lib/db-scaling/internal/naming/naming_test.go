@@ -0,0 +1,79 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	if _, err := Parse(""); err != nil {
+		t.Errorf("Expected default template to be valid, got %v", err)
+	}
+
+	invalid := []string{
+		"{cluster}-reader",          // not deterministic per window
+		"{cluster}-{unknown}-{key}", // unknown placeholder
+		"{cluster}_reader_{key}",    // underscores are not allowed
+		"1{cluster}-{key}",          // must start with a letter
+	}
+	for _, pattern := range invalid {
+		if _, err := Parse(pattern); err == nil {
+			t.Errorf("Expected error for template %q", pattern)
+		}
+	}
+}
+
+func TestRenderIsDeterministic(t *testing.T) {
+	template, _ := Parse(DefaultTemplate)
+	window := Window(time.Date(2024, 5, 1, 10, 7, 30, 0, time.UTC), 5*time.Minute)
+
+	first, err := template.Render("prod-cluster", window)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, _ := template.Render("prod-cluster", Window(window.Add(2*time.Minute), 5*time.Minute))
+	if first != second {
+		t.Errorf("Expected the same name within a window, got %q and %q", first, second)
+	}
+
+	next, _ := template.Render("prod-cluster", window.Add(5*time.Minute))
+	if first == next {
+		t.Errorf("Expected a different name in the next window, got %q twice", first)
+	}
+}
+
+func TestRenderShortensLongClusterNames(t *testing.T) {
+	template, _ := Parse("{cluster}-reader-{window}")
+	cluster := "feature-" + strings.Repeat("a", 60) + "-docdb"
+
+	identifier, err := template.Render(cluster, time.Unix(1714557600, 0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(identifier) > MaxIdentifierLength {
+		t.Errorf("Expected at most %d characters, got %d (%q)", MaxIdentifierLength, len(identifier), identifier)
+	}
+	if !strings.HasSuffix(identifier, "-reader-202405011000") {
+		t.Errorf("Expected window suffix to be preserved, got %q", identifier)
+	}
+}
+
+func TestRenderOrdinal(t *testing.T) {
+	window := time.Unix(1714557600, 0)
+	for _, pattern := range []string{DefaultTemplate, "{cluster}-reader-{window}"} {
+		template, _ := Parse(pattern)
+		first, _ := template.Render("prod-cluster", window)
+		zero, _ := template.RenderOrdinal("prod-cluster", window, 0)
+		second, err := template.RenderOrdinal("prod-cluster", window, 1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if zero != first {
+			t.Errorf("%s: expected ordinal 0 to match Render, got %q and %q", pattern, zero, first)
+		}
+		if second == first {
+			t.Errorf("%s: expected ordinal 1 to differ, got %q twice", pattern, first)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"docdb-auto-scaling/internal/config"
+)
+
+// Document is a versioned scaling policy. Fields left out keep the value
+// from the environment configuration.
+type Document struct {
+	Version                      string   `json:"version" yaml:"version"`
+	MinReadReplicas              *int     `json:"minReadReplicas,omitempty" yaml:"minReadReplicas,omitempty"`
+	MaxReadReplicas              *int     `json:"maxReadReplicas,omitempty" yaml:"maxReadReplicas,omitempty"`
+	InstanceClass                *string  `json:"instanceClass,omitempty" yaml:"instanceClass,omitempty"`
+	FallbackInstanceClasses      []string `json:"fallbackInstanceClasses,omitempty" yaml:"fallbackInstanceClasses,omitempty"`
+	CooldownMinutes              *int     `json:"cooldownMinutes,omitempty" yaml:"cooldownMinutes,omitempty"`
+	CPUScaleOutThreshold         *float64 `json:"cpuScaleOutThreshold,omitempty" yaml:"cpuScaleOutThreshold,omitempty"`
+	CPUScaleInThreshold          *float64 `json:"cpuScaleInThreshold,omitempty" yaml:"cpuScaleInThreshold,omitempty"`
+	ConnectionsScaleOutThreshold *float64 `json:"connectionsScaleOutThreshold,omitempty" yaml:"connectionsScaleOutThreshold,omitempty"`
+	EvaluationPeriods            *int     `json:"evaluationPeriods,omitempty" yaml:"evaluationPeriods,omitempty"`
+	HourlyBudget                 *float64 `json:"hourlyBudget,omitempty" yaml:"hourlyBudget,omitempty"`
+
+	DecisionPolicy          *string            `json:"decisionPolicy,omitempty" yaml:"decisionPolicy,omitempty"`
+	ScoreWeights            map[string]float64 `json:"scoreWeights,omitempty" yaml:"scoreWeights,omitempty"`
+	ScoreScaleOut           *float64           `json:"scoreScaleOut,omitempty" yaml:"scoreScaleOut,omitempty"`
+	ScoreScaleIn            *float64           `json:"scoreScaleIn,omitempty" yaml:"scoreScaleIn,omitempty"`
+	ReplicaLagThreshold     *float64           `json:"replicaLagThresholdMs,omitempty" yaml:"replicaLagThresholdMs,omitempty"`
+	FreeableMemoryThreshold *float64           `json:"freeableMemoryThresholdMb,omitempty" yaml:"freeableMemoryThresholdMb,omitempty"`
+
+	Rules []Rule `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// Rule is a scaling condition evaluated by DECISION_POLICY=rules, such as
+// `writer.cpu.p90 > 75 && readers.lag.max < 2000`
+type Rule struct {
+	Name   string `json:"name" yaml:"name"`
+	Action string `json:"action" yaml:"action"`
+	When   string `json:"when" yaml:"when"`
+}
+
+// Parse decodes a JSON or YAML policy document, rejecting unknown fields
+func Parse(data []byte) (*Document, error) {
+	doc := &Document{}
+	trimmed := bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON policy: %w", err)
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(trimmed))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(doc); err != nil {
+			return nil, fmt.Errorf("invalid YAML policy: %w", err)
+		}
+	}
+
+	if doc.Version == "" {
+		return nil, fmt.Errorf("policy document has no version")
+	}
+	return doc, nil
+}
+
+// Apply overlays the document on base and validates the result
+func (d *Document) Apply(base *config.Config) (*config.Config, error) {
+	cfg := base.Clone()
+	source := "policy " + d.Version
+
+	if d.MinReadReplicas != nil {
+		cfg.MinReadReplicas = *d.MinReadReplicas
+		cfg.Override("MIN_READ_REPLICAS", source)
+	}
+	if d.MaxReadReplicas != nil {
+		cfg.MaxReadReplicas = *d.MaxReadReplicas
+		cfg.Override("MAX_READ_REPLICAS", source)
+	}
+	if d.InstanceClass != nil {
+		cfg.InstanceClass = *d.InstanceClass
+		cfg.Override("INSTANCE_CLASS", source)
+	}
+	if d.FallbackInstanceClasses != nil {
+		cfg.FallbackInstanceClasses = append([]string(nil), d.FallbackInstanceClasses...)
+		cfg.Override("FALLBACK_INSTANCE_CLASSES", source)
+	}
+	if d.CooldownMinutes != nil {
+		cfg.CooldownMinutes = *d.CooldownMinutes
+		cfg.Override("COOLDOWN_MINUTES", source)
+	}
+	if d.CPUScaleOutThreshold != nil {
+		cfg.CPUScaleOutThreshold = *d.CPUScaleOutThreshold
+		cfg.Override("CPU_SCALE_OUT_THRESHOLD", source)
+	}
+	if d.CPUScaleInThreshold != nil {
+		cfg.CPUScaleInThreshold = *d.CPUScaleInThreshold
+		cfg.Override("CPU_SCALE_IN_THRESHOLD", source)
+	}
+	if d.ConnectionsScaleOutThreshold != nil {
+		cfg.ConnectionsScaleOutThreshold = *d.ConnectionsScaleOutThreshold
+		cfg.Override("CONNECTIONS_SCALE_OUT_THRESHOLD", source)
+	}
+	if d.EvaluationPeriods != nil {
+		cfg.EvaluationPeriods = *d.EvaluationPeriods
+		cfg.Override("EVALUATION_PERIODS", source)
+	}
+	if d.HourlyBudget != nil {
+		cfg.HourlyBudget = *d.HourlyBudget
+		cfg.Override("HOURLY_BUDGET", source)
+	}
+	if d.DecisionPolicy != nil {
+		cfg.DecisionPolicy = *d.DecisionPolicy
+		cfg.Override("DECISION_POLICY", source)
+	}
+	if d.ScoreWeights != nil {
+		// The document's weights replace the configured ones; signals it leaves out don't count
+		cfg.ScoreWeights = make(map[string]float64, len(d.ScoreWeights))
+		for name, weight := range d.ScoreWeights {
+			cfg.ScoreWeights[name] = weight
+		}
+		cfg.Override("SCORE_WEIGHTS", source)
+	}
+	if d.ScoreScaleOut != nil {
+		cfg.ScoreScaleOut = *d.ScoreScaleOut
+		cfg.Override("SCORE_SCALE_OUT", source)
+	}
+	if d.ScoreScaleIn != nil {
+		cfg.ScoreScaleIn = *d.ScoreScaleIn
+		cfg.Override("SCORE_SCALE_IN", source)
+	}
+	if d.ReplicaLagThreshold != nil {
+		cfg.ReplicaLagThreshold = *d.ReplicaLagThreshold
+		cfg.Override("REPLICA_LAG_THRESHOLD_MS", source)
+	}
+	if d.FreeableMemoryThreshold != nil {
+		cfg.FreeableMemoryThreshold = *d.FreeableMemoryThreshold
+		cfg.Override("FREEABLE_MEMORY_THRESHOLD_MB", source)
+	}
+	if d.Rules != nil {
+		cfg.Rules = make([]config.Rule, len(d.Rules))
+		for i, rule := range d.Rules {
+			cfg.Rules[i] = config.Rule{Name: rule.Name, Action: rule.Action, When: rule.When}
+		}
+		cfg.Override("RULES", source)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("policy %s is invalid:\n%w", d.Version, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/appconfigdata"
+	"github.com/aws/aws-sdk-go/service/appconfigdata/appconfigdataiface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// Provider fetches the raw policy document from a backing store. An empty
+// result with a nil error means the document has not changed since the last
+// fetch.
+type Provider interface {
+	Fetch(ctx context.Context) (data []byte, revision string, err error)
+	String() string
+}
+
+// NewProvider creates the provider for a POLICY_SOURCE value:
+// ssm:<parameter-name>, appconfig:<application>/<environment>/<profile> or
+// file:<path>
+func NewProvider(source string, sess *session.Session) (Provider, error) {
+	scheme, location, _ := strings.Cut(source, ":")
+	switch scheme {
+	case "ssm":
+		return &SSM{client: ssm.New(sess), name: location}, nil
+	case "appconfig":
+		parts := strings.Split(location, "/")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("appconfig policy source must be <application>/<environment>/<profile>, got %q", location)
+		}
+		return &AppConfig{
+			client:      appconfigdata.New(sess),
+			application: parts[0],
+			environment: parts[1],
+			profile:     parts[2],
+		}, nil
+	case "file":
+		return &File{Path: location}, nil
+	default:
+		return nil, fmt.Errorf("unsupported policy source %q", source)
+	}
+}
+
+// File reads the policy from a local file, for offline testing
+type File struct {
+	Path string
+}
+
+func (f *File) Fetch(ctx context.Context) ([]byte, string, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read policy file: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return data, "sha256:" + hex.EncodeToString(sum[:])[:12], nil
+}
+
+func (f *File) String() string {
+	return "file:" + f.Path
+}
+
+// SSM reads the policy from a Parameter Store parameter
+type SSM struct {
+	client ssmiface.SSMAPI
+	name   string
+}
+
+func (s *SSM) Fetch(ctx context.Context) ([]byte, string, error) {
+	result, err := s.client.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(s.name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get parameter %s: %w", s.name, err)
+	}
+	revision := strconv.FormatInt(aws.Int64Value(result.Parameter.Version), 10)
+	return []byte(aws.StringValue(result.Parameter.Value)), revision, nil
+}
+
+func (s *SSM) String() string {
+	return "ssm:" + s.name
+}
+
+// AppConfig reads the policy from an AppConfig configuration profile. The
+// session token is kept between fetches, so AppConfig returns an empty body
+// while the deployed configuration is unchanged.
+type AppConfig struct {
+	client      appconfigdataiface.AppConfigDataAPI
+	application string
+	environment string
+	profile     string
+	token       *string
+}
+
+func (a *AppConfig) Fetch(ctx context.Context) ([]byte, string, error) {
+	if a.token == nil {
+		session, err := a.client.StartConfigurationSessionWithContext(ctx, &appconfigdata.StartConfigurationSessionInput{
+			ApplicationIdentifier:          aws.String(a.application),
+			EnvironmentIdentifier:          aws.String(a.environment),
+			ConfigurationProfileIdentifier: aws.String(a.profile),
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to start AppConfig session: %w", err)
+		}
+		a.token = session.InitialConfigurationToken
+	}
+
+	result, err := a.client.GetLatestConfigurationWithContext(ctx, &appconfigdata.GetLatestConfigurationInput{
+		ConfigurationToken: a.token,
+	})
+	if err != nil {
+		// Tokens expire after 24 hours; start a new session next time
+		a.token = nil
+		return nil, "", fmt.Errorf("failed to get AppConfig configuration: %w", err)
+	}
+	a.token = result.NextPollConfigurationToken
+
+	return result.Configuration, aws.StringValue(result.VersionLabel), nil
+}
+
+func (a *AppConfig) String() string {
+	return fmt.Sprintf("appconfig:%s/%s/%s", a.application, a.environment, a.profile)
+}
@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"docdb-auto-scaling/internal/config"
+)
+
+// EnvVersion is reported when no policy document is in effect
+const EnvVersion = "env"
+
+// Store caches the effective configuration and reloads the policy document
+// once the TTL has passed. If the policy can't be fetched or is invalid, the
+// last good policy is kept, or the environment configuration if there is none.
+type Store struct {
+	provider Provider
+	base     *config.Config
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	current  *config.Config
+	version  string
+	raw      []byte
+	loadedAt time.Time
+}
+
+// NewStore creates a store; a nil provider always yields the base configuration
+func NewStore(provider Provider, base *config.Config, ttl time.Duration) *Store {
+	return &Store{
+		provider: provider,
+		base:     base,
+		ttl:      ttl,
+		now:      time.Now,
+		current:  base,
+		version:  EnvVersion,
+	}
+}
+
+// Get returns the effective configuration and the version of the policy it came from
+func (s *Store) Get(ctx context.Context) (*config.Config, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil || (!s.loadedAt.IsZero() && s.now().Sub(s.loadedAt) < s.ttl) {
+		return s.current, s.version
+	}
+	s.loadedAt = s.now()
+
+	data, revision, err := s.provider.Fetch(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to load policy from %s, keeping version %s: %v", s.provider, s.version, err)
+		return s.current, s.version
+	}
+	if len(data) == 0 || bytes.Equal(data, s.raw) {
+		return s.current, s.version
+	}
+
+	doc, err := Parse(data)
+	if err != nil {
+		log.Printf("Warning: ignoring policy revision %s from %s, keeping version %s: %v", revision, s.provider, s.version, err)
+		return s.current, s.version
+	}
+	cfg, err := doc.Apply(s.base)
+	if err != nil {
+		log.Printf("Warning: ignoring policy revision %s from %s, keeping version %s: %v", revision, s.provider, s.version, err)
+		return s.current, s.version
+	}
+
+	version := doc.Version
+	if revision != "" {
+		version += "@" + revision
+	}
+	log.Printf("Loaded policy version %s from %s", version, s.provider)
+
+	s.current, s.version, s.raw = cfg, version, data
+	return s.current, s.version
+}
@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"docdb-auto-scaling/internal/config"
+)
+
+func baseConfig(t *testing.T) *config.Config {
+	cfg, err := config.Load(func(key string) (string, bool) {
+		if key == "CLUSTER_IDENTIFIER" {
+			return "test-cluster", true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return cfg
+}
+
+func TestParse(t *testing.T) {
+	yamlDoc := "version: v2\nmaxReadReplicas: 4\ncpuScaleOutThreshold: 65\n"
+	doc, err := Parse([]byte(yamlDoc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if doc.Version != "v2" || *doc.MaxReadReplicas != 4 || *doc.CPUScaleOutThreshold != 65 {
+		t.Errorf("Unexpected YAML document: %+v", doc)
+	}
+
+	jsonDoc := `{"version": "v3", "minReadReplicas": 2}`
+	doc, err = Parse([]byte(jsonDoc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if doc.Version != "v3" || *doc.MinReadReplicas != 2 {
+		t.Errorf("Unexpected JSON document: %+v", doc)
+	}
+
+	if _, err := Parse([]byte(`{"version": "v4", "maxReplicas": 2}`)); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+	if _, err := Parse([]byte("maxReadReplicas: 2\n")); err == nil {
+		t.Error("Expected error for missing version")
+	}
+}
+
+func TestStoreReloadsFilePolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := NewStore(&File{Path: path}, baseConfig(t), time.Minute)
+	store.now = func() time.Time { return now }
+
+	write("version: v1\nmaxReadReplicas: 4\n")
+	cfg, version := store.Get(context.Background())
+	if cfg.MaxReadReplicas != 4 || !strings.HasPrefix(version, "v1@") {
+		t.Errorf("Expected v1 with 4 max replicas, got %s with %d", version, cfg.MaxReadReplicas)
+	}
+	if !strings.Contains(cfg.Summary(), "(policy v1)") {
+		t.Errorf("Expected summary to attribute MAX_READ_REPLICAS to the policy, got:\n%s", cfg.Summary())
+	}
+
+	// Within the TTL the cached policy is used
+	write("version: v2\nmaxReadReplicas: 6\n")
+	if _, version := store.Get(context.Background()); !strings.HasPrefix(version, "v1@") {
+		t.Errorf("Expected cached v1, got %s", version)
+	}
+
+	now = now.Add(2 * time.Minute)
+	cfg, version = store.Get(context.Background())
+	if cfg.MaxReadReplicas != 6 || !strings.HasPrefix(version, "v2@") {
+		t.Errorf("Expected v2 with 6 max replicas, got %s with %d", version, cfg.MaxReadReplicas)
+	}
+
+	// An invalid policy keeps the last good one
+	write("version: v3\nminReadReplicas: 9\nmaxReadReplicas: 2\n")
+	now = now.Add(2 * time.Minute)
+	if _, version := store.Get(context.Background()); !strings.HasPrefix(version, "v2@") {
+		t.Errorf("Expected v2 to be kept after invalid v3, got %s", version)
+	}
+}
+
+func TestStoreWithoutProvider(t *testing.T) {
+	base := baseConfig(t)
+	cfg, version := NewStore(nil, base, time.Minute).Get(context.Background())
+	if cfg != base || version != EnvVersion {
+		t.Errorf("Expected environment configuration, got version %s", version)
+	}
+}
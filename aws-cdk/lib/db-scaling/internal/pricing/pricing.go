@@ -0,0 +1,105 @@
+// Package pricing holds hourly on-demand prices of DocumentDB instance
+// classes, so that the autoscaler can report and cap the cost of a cluster.
+package pricing
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+//go:embed snapshot.json
+var snapshotJSON []byte
+
+// Table maps instance classes to their price per instance-hour
+type Table struct {
+	Currency string             `json:"currency"`
+	Region   string             `json:"region,omitempty"`
+	AsOf     string             `json:"asOf,omitempty"`
+	Prices   map[string]float64 `json:"prices"`
+}
+
+// Parse decodes a JSON price table, rejecting unknown fields and
+// non-positive prices. The currency defaults to USD.
+func Parse(data []byte) (*Table, error) {
+	table := &Table{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(table); err != nil {
+		return nil, fmt.Errorf("invalid price table: %w", err)
+	}
+	if table.Currency == "" {
+		table.Currency = "USD"
+	}
+	if len(table.Prices) == 0 {
+		return nil, fmt.Errorf("price table has no prices")
+	}
+	for class, price := range table.Prices {
+		if price <= 0 {
+			return nil, fmt.Errorf("price of %s must be positive, got %g", class, price)
+		}
+	}
+	return table, nil
+}
+
+// LoadFile reads a price table from a JSON file
+func LoadFile(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price table: %w", err)
+	}
+	table, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return table, nil
+}
+
+// Snapshot returns the price table built into the binary: us-east-1
+// on-demand prices at the date in AsOf. Use a price table file for other
+// regions or current prices.
+func Snapshot() *Table {
+	table, err := Parse(snapshotJSON)
+	if err != nil {
+		panic(fmt.Sprintf("built-in price snapshot: %v", err))
+	}
+	return table
+}
+
+// Load builds the price table from a file, the snapshot or both; classes
+// missing from the file are taken from the snapshot. It returns nil if
+// neither is used.
+func Load(path string, snapshot bool) (*Table, error) {
+	var table, fallback *Table
+	if snapshot {
+		fallback = Snapshot()
+	}
+	if path == "" {
+		return fallback, nil
+	}
+
+	table, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if fallback == nil {
+		return table, nil
+	}
+	if fallback.Currency != table.Currency {
+		return nil, fmt.Errorf("%s: currency %s does not match the snapshot's %s", path, table.Currency, fallback.Currency)
+	}
+	for class, price := range fallback.Prices {
+		if _, ok := table.Prices[class]; !ok {
+			table.Prices[class] = price
+		}
+	}
+	return table, nil
+}
+
+// Price returns the hourly price of an instance class
+func (t *Table) Price(class string) (float64, bool) {
+	price, ok := t.Prices[class]
+	return price, ok
+}
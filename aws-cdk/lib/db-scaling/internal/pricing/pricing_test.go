@@ -0,0 +1,43 @@
+package pricing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMergesFileOverSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	os.WriteFile(path, []byte(`{"region": "eu-west-1", "prices": {"db.r6g.large": 0.29}}`), 0o644)
+
+	table, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if price, _ := table.Price("db.r6g.large"); price != 0.29 {
+		t.Errorf("Expected the file's price to win, got %g", price)
+	}
+	if _, ok := table.Price("db.r5.large"); !ok {
+		t.Errorf("Expected classes missing from the file to come from the snapshot")
+	}
+
+	table, _ = Load(path, false)
+	if _, ok := table.Price("db.r5.large"); ok {
+		t.Errorf("Expected only the file's classes without the snapshot")
+	}
+	if table, _ := Load("", false); table != nil {
+		t.Errorf("Expected no table without a file or snapshot, got %+v", table)
+	}
+}
+
+func TestParseRejectsBadPrices(t *testing.T) {
+	for _, data := range []string{
+		`{"prices": {}}`,
+		`{"prices": {"db.r6g.large": 0}}`,
+		`{"prices": {"db.r6g.large": 0.26}, "discount": 0.1}`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected %s to be rejected", data)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package profile holds the scaling defaults of each kind of environment.
+// Branch environments get the ephemeral profile, so that a forgotten feature
+// branch can't scale out like production.
+package profile
+
+import (
+	"fmt"
+	"strings"
+
+	"docdb-auto-scaling/internal/config"
+)
+
+// Profile names
+const (
+	Prod      = "prod"
+	Staging   = "staging"
+	Ephemeral = "ephemeral"
+)
+
+// Profile is a set of defaults. It only replaces settings that were neither
+// set in the environment nor by a policy document.
+type Profile struct {
+	Name             string
+	MinReadReplicas  int
+	MaxReadReplicas  int
+	CooldownMinutes  int
+	MaxCreatesPerDay int
+	VerticalScaling  bool
+}
+
+var profiles = map[string]Profile{
+	// What the main deployment ran with before profiles, above the
+	// configuration defaults
+	Prod:      {Name: Prod, MinReadReplicas: 1, MaxReadReplicas: 14, CooldownMinutes: 20, MaxCreatesPerDay: 20, VerticalScaling: true},
+	Staging:   {Name: Staging, MinReadReplicas: 1, MaxReadReplicas: 4, CooldownMinutes: 30, MaxCreatesPerDay: 10, VerticalScaling: true},
+	Ephemeral: {Name: Ephemeral, MinReadReplicas: 0, MaxReadReplicas: 1, CooldownMinutes: 60, MaxCreatesPerDay: 4, VerticalScaling: false},
+}
+
+// environments maps well-known environment names to their profile; every
+// other name is a branch environment
+var environments = map[string]string{
+	"":           Prod,
+	"prod":       Prod,
+	"production": Prod,
+	"main":       Prod,
+	"master":     Prod,
+	"staging":    Staging,
+	"stage":      Staging,
+	"develop":    Staging,
+	"dev":        Staging,
+}
+
+// Get returns the profile with the given name
+func Get(name string) (Profile, bool) {
+	p, ok := profiles[name]
+	return p, ok
+}
+
+// Select returns the profile named by PROFILE, or else the one derived
+// from the environment name
+func Select(name, environment string) Profile {
+	if p, ok := profiles[name]; ok {
+		return p
+	}
+	if name, ok := environments[strings.ToLower(environment)]; ok {
+		return profiles[name]
+	}
+	return profiles[Ephemeral]
+}
+
+// Apply returns a copy of base with the profile's defaults filled in
+func (p Profile) Apply(base *config.Config) (*config.Config, error) {
+	cfg := base.Clone()
+	source := "profile " + p.Name
+
+	set := func(key string, apply func()) {
+		if !cfg.IsSet(key) {
+			apply()
+			cfg.Override(key, source)
+		}
+	}
+	set("MIN_READ_REPLICAS", func() { cfg.MinReadReplicas = p.MinReadReplicas })
+	set("MAX_READ_REPLICAS", func() { cfg.MaxReadReplicas = p.MaxReadReplicas })
+	set("COOLDOWN_MINUTES", func() { cfg.CooldownMinutes = p.CooldownMinutes })
+	set("MAX_CREATES_PER_DAY", func() { cfg.MaxCreatesPerDay = p.MaxCreatesPerDay })
+	set("VERTICAL_SCALING", func() { cfg.VerticalScaling = p.VerticalScaling })
+	if cfg.MaxCreatesPerHour > cfg.MaxCreatesPerDay && !cfg.IsSet("MAX_CREATES_PER_HOUR") {
+		cfg.MaxCreatesPerHour = cfg.MaxCreatesPerDay
+		cfg.Override("MAX_CREATES_PER_HOUR", source)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("profile %s does not fit the configuration:\n%w", p.Name, err)
+	}
+	return cfg, nil
+}
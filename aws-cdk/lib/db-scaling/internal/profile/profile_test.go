@@ -0,0 +1,62 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+
+	"docdb-auto-scaling/internal/config"
+)
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name, environment, expected string
+	}{
+		{"", "main", Prod},
+		{"", "", Prod},
+		{"", "Staging", Staging},
+		{"", "feature-leaderboard-cache", Ephemeral},
+		{"staging", "feature-leaderboard-cache", Staging},
+	}
+	for _, tt := range tests {
+		if p := Select(tt.name, tt.environment); p.Name != tt.expected {
+			t.Errorf("Select(%q, %q): expected %s, got %s", tt.name, tt.environment, tt.expected, p.Name)
+		}
+	}
+}
+
+func TestProdProfile(t *testing.T) {
+	// The production cluster is deployed with these; lowering them takes
+	// away headroom it relies on
+	p, _ := Get(Prod)
+	if p.MinReadReplicas != 1 || p.MaxReadReplicas != 14 || p.CooldownMinutes != 20 || p.MaxCreatesPerDay != 20 || !p.VerticalScaling {
+		t.Errorf("Expected 1-14 readers, a 20 minute cooldown and 20 creates a day, got %+v", p)
+	}
+}
+
+func TestApplyKeepsExplicitSettings(t *testing.T) {
+	base, err := config.Load(func(key string) (string, bool) {
+		value, ok := map[string]string{"CLUSTER_IDENTIFIER": "test-cluster", "COOLDOWN_MINUTES": "5"}[key]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	p, _ := Get(Ephemeral)
+	cfg, err := p.Apply(base)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.MaxReadReplicas != 1 || cfg.VerticalScaling || cfg.MaxCreatesPerHour != 4 {
+		t.Errorf("Expected the ephemeral defaults, got max %d, vertical %v, creates/hour %d", cfg.MaxReadReplicas, cfg.VerticalScaling, cfg.MaxCreatesPerHour)
+	}
+	if cfg.CooldownMinutes != 5 {
+		t.Errorf("Expected the explicit cooldown to be kept, got %d", cfg.CooldownMinutes)
+	}
+	if !strings.Contains(cfg.Summary(), "(profile ephemeral)") {
+		t.Errorf("Expected the summary to name the profile, got:\n%s", cfg.Summary())
+	}
+	if base.MaxReadReplicas != 10 {
+		t.Errorf("Expected the base configuration to be unchanged, got max %d", base.MaxReadReplicas)
+	}
+}
@@ -0,0 +1,234 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+)
+
+type valueType int
+
+const (
+	numberType valueType = iota
+	boolType
+)
+
+func (t valueType) String() string {
+	if t == boolType {
+		return "a condition"
+	}
+	return "a number"
+}
+
+// node is an expression in the syntax tree. Conditions evaluate to 1 or 0.
+// Statistics cover the window minutes ending shift minutes ago; `for` checks
+// each of its minutes with a one-minute window.
+type node interface {
+	pos() int
+	eval(env *Env, shift, window int) (float64, error)
+}
+
+type number struct {
+	value float64
+	at    int
+}
+
+type boolean struct {
+	value bool
+	at    int
+}
+
+type variable struct {
+	name string
+	stat string // empty for scalars
+	at   int
+}
+
+type unary struct {
+	op      string
+	operand node
+	at      int
+}
+
+type binary struct {
+	op          string
+	left, right node
+	at          int
+}
+
+type held struct {
+	cond     node
+	duration time.Duration
+	at       int
+}
+
+func (n *number) pos() int   { return n.at }
+func (n *boolean) pos() int  { return n.at }
+func (n *variable) pos() int { return n.at }
+func (n *unary) pos() int    { return n.at }
+func (n *binary) pos() int   { return n.at }
+func (n *held) pos() int     { return n.at }
+
+func (n *held) minutes() int { return minutes(n.duration) }
+
+// typeOf infers the type of n, reporting the first mismatch
+func typeOf(n node) (valueType, error) {
+	switch n := n.(type) {
+	case *number, *variable:
+		return numberType, nil
+	case *boolean:
+		return boolType, nil
+	case *unary:
+		if n.op == "!" {
+			return boolType, check(n.operand, boolType)
+		}
+		return numberType, check(n.operand, numberType)
+	case *held:
+		return boolType, check(n.cond, boolType)
+	case *binary:
+		operands, result := numberType, boolType
+		switch n.op {
+		case "&&", "||":
+			operands = boolType
+		case "+", "-", "*", "/":
+			result = numberType
+		}
+		if err := check(n.left, operands); err != nil {
+			return result, err
+		}
+		return result, check(n.right, operands)
+	}
+	return numberType, fmt.Errorf("unknown node %T", n)
+}
+
+// check requires n to have type want
+func check(n node, want valueType) error {
+	got, err := typeOf(n)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		msg := fmt.Sprintf("expected %s, got %s", want, got)
+		if want == boolType {
+			msg += "; compare it, as in writer.cpu > 75"
+		}
+		return &Error{Pos: n.pos(), Msg: msg}
+	}
+	return nil
+}
+
+// walk calls fn for n and every node below it
+func walk(n node, fn func(node)) {
+	fn(n)
+	switch n := n.(type) {
+	case *unary:
+		walk(n.operand, fn)
+	case *binary:
+		walk(n.left, fn)
+		walk(n.right, fn)
+	case *held:
+		walk(n.cond, fn)
+	}
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (n *number) eval(*Env, int, int) (float64, error) { return n.value, nil }
+
+func (n *boolean) eval(*Env, int, int) (float64, error) { return truth(n.value), nil }
+
+func (n *variable) eval(env *Env, shift, window int) (float64, error) {
+	if n.stat == "" {
+		value, ok := env.Scalars[n.name]
+		if !ok {
+			return 0, fmt.Errorf("no value for %s", n.name)
+		}
+		return value, nil
+	}
+
+	series := env.Series[n.name]
+	end := len(series) - shift
+	if end <= 0 {
+		if shift > 0 {
+			return 0, fmt.Errorf("no data for %s %d minutes ago", n.name, shift)
+		}
+		return 0, fmt.Errorf("no data for %s", n.name)
+	}
+	return statistic(n.stat, series[max(end-window, 0):end]), nil
+}
+
+func (n *unary) eval(env *Env, shift, window int) (float64, error) {
+	value, err := n.operand.eval(env, shift, window)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == "!" {
+		return truth(value == 0), nil
+	}
+	return -value, nil
+}
+
+func (n *binary) eval(env *Env, shift, window int) (float64, error) {
+	left, err := n.left.eval(env, shift, window)
+	if err != nil {
+		return 0, err
+	}
+	// && and || skip the right side, so it may lack data
+	switch {
+	case n.op == "&&" && left == 0:
+		return 0, nil
+	case n.op == "||" && left != 0:
+		return 1, nil
+	}
+	right, err := n.right.eval(env, shift, window)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		return truth(right != 0), nil
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/":
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero at column %d", n.at+1)
+		}
+		return left / right, nil
+	case "<":
+		return truth(left < right), nil
+	case "<=":
+		return truth(left <= right), nil
+	case ">":
+		return truth(left > right), nil
+	case ">=":
+		return truth(left >= right), nil
+	case "==":
+		return truth(left == right), nil
+	case "!=":
+		return truth(left != right), nil
+	}
+	return 0, fmt.Errorf("unknown operator %s", n.op)
+}
+
+// eval holds if the condition held in each of the last minutes
+func (n *held) eval(env *Env, shift, _ int) (float64, error) {
+	for step := 0; step < n.minutes(); step++ {
+		value, err := n.cond.eval(env, shift+step, 1)
+		if err != nil {
+			return 0, err
+		}
+		if value == 0 {
+			return 0, nil
+		}
+	}
+	return 1, nil
+}
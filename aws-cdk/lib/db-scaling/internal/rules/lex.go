@@ -0,0 +1,112 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenDuration
+	tokenIdent
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	pos      int
+	number   float64
+	duration time.Duration
+}
+
+// describe names a token for error messages
+func (t token) describe() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators longest first, so that >= isn't read as >
+var operators = []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "!", "+", "-", "*", "/"}
+
+type lexer struct {
+	source string
+	pos    int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.source) && unicode.IsSpace(rune(l.source[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.source) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+
+	c := l.source[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokenLeftParen, text: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokenRightParen, text: ")", pos: start}, nil
+	case isDigit(c) || c == '.':
+		return l.number(start)
+	case isLetter(c):
+		for l.pos < len(l.source) && (isLetter(l.source[l.pos]) || isDigit(l.source[l.pos]) || l.source[l.pos] == '.') {
+			l.pos++
+		}
+		return token{kind: tokenIdent, text: l.source[start:l.pos], pos: start}, nil
+	}
+
+	for _, op := range operators {
+		if len(l.source)-l.pos >= len(op) && l.source[l.pos:l.pos+len(op)] == op {
+			l.pos += len(op)
+			return token{kind: tokenOperator, text: op, pos: start}, nil
+		}
+	}
+	if c == '&' || c == '|' || c == '=' {
+		return token{}, &Error{Source: l.source, Pos: start, Msg: fmt.Sprintf("unexpected %q, did you mean %q?", string(c), string(c)+string(c))}
+	}
+	return token{}, &Error{Source: l.source, Pos: start, Msg: fmt.Sprintf("unexpected character %q", string(c))}
+}
+
+// number reads a number, or a duration such as 3m or 90s
+func (l *lexer) number(start int) (token, error) {
+	for l.pos < len(l.source) && (isDigit(l.source[l.pos]) || l.source[l.pos] == '.') {
+		l.pos++
+	}
+	for l.pos < len(l.source) && isLetter(l.source[l.pos]) {
+		l.pos++
+	}
+	text := l.source[start:l.pos]
+
+	if value, err := strconv.ParseFloat(text, 64); err == nil {
+		return token{kind: tokenNumber, text: text, pos: start, number: value}, nil
+	}
+	if d, err := time.ParseDuration(text); err == nil {
+		if d <= 0 {
+			return token{}, &Error{Source: l.source, Pos: start, Msg: fmt.Sprintf("duration %s must be positive", text)}
+		}
+		return token{kind: tokenDuration, text: text, pos: start, duration: d}, nil
+	}
+	return token{}, &Error{Source: l.source, Pos: start, Msg: fmt.Sprintf("%q is neither a number nor a duration such as 3m", text)}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,230 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The grammar, loosest binding first:
+//
+//	expr       = and { "||" and }
+//	and        = not { "&&" not }
+//	not        = "!" not | held
+//	held       = comparison [ "for" DURATION ]
+//	comparison = sum [ ( "<" | "<=" | ">" | ">=" | "==" | "!=" ) sum ]
+//	sum        = product { ( "+" | "-" ) product }
+//	product    = unary { ( "*" | "/" ) unary }
+//	unary      = "-" unary | primary
+//	primary    = NUMBER | "true" | "false" | METRIC | "(" expr ")"
+//
+// Types are checked after parsing, so that `(a > 1) for 3m` and `(a + b) > 1`
+// share one parenthesis rule.
+type parser struct {
+	lexer lexer
+	tok   token
+	err   error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lexer.next()
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &Error{Source: p.lexer.source, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) is(op string) bool {
+	return p.tok.kind == tokenOperator && p.tok.text == op
+}
+
+func (p *parser) parse() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind == tokenEOF {
+		return nil, p.errorf(0, "empty expression")
+	}
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.errorf(p.tok.pos, "unexpected %s, expected && or || between conditions", p.tok.describe())
+	}
+	return root, nil
+}
+
+func (p *parser) expr() (node, error) {
+	return p.binaryLevel([]string{"||"}, p.and)
+}
+
+func (p *parser) and() (node, error) {
+	return p.binaryLevel([]string{"&&"}, p.not)
+}
+
+// binaryLevel parses a left-associative chain of the operators in ops
+func (p *parser) binaryLevel(ops []string, operand func() (node, error)) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenOperator && contains(ops, p.tok.text) {
+		op, pos := p.tok.text, p.tok.pos
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right, at: pos}
+	}
+	return left, p.err
+}
+
+func (p *parser) not() (node, error) {
+	if p.is("!") {
+		pos := p.tok.pos
+		p.next()
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: "!", operand: operand, at: pos}, nil
+	}
+	return p.held()
+}
+
+func (p *parser) held() (node, error) {
+	cond, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenIdent || p.tok.text != "for" {
+		return cond, nil
+	}
+	pos := p.tok.pos
+	p.next()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind != tokenDuration {
+		return nil, p.errorf(p.tok.pos, "expected a duration such as 3m after for, got %s", p.tok.describe())
+	}
+	d := p.tok.duration
+	p.next()
+	return &held{cond: cond, duration: d, at: pos}, p.err
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenOperator && contains(comparisons, p.tok.text) {
+		op, pos := p.tok.text, p.tok.pos
+		p.next()
+		right, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokenOperator && contains(comparisons, p.tok.text) {
+			return nil, p.errorf(p.tok.pos, "comparisons can't be chained, join them with &&")
+		}
+		return &binary{op: op, left: left, right: right, at: pos}, nil
+	}
+	return left, nil
+}
+
+var comparisons = []string{"<", "<=", ">", ">=", "==", "!="}
+
+func (p *parser) sum() (node, error) {
+	return p.binaryLevel([]string{"+", "-"}, p.product)
+}
+
+func (p *parser) product() (node, error) {
+	return p.binaryLevel([]string{"*", "/"}, p.unary)
+}
+
+func (p *parser) unary() (node, error) {
+	if p.is("-") {
+		pos := p.tok.pos
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: "-", operand: operand, at: pos}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokenNumber:
+		p.next()
+		return &number{value: tok.number, at: tok.pos}, p.err
+	case tokenDuration:
+		return nil, p.errorf(tok.pos, "duration %s is only allowed after for", tok.text)
+	case tokenIdent:
+		p.next()
+		switch tok.text {
+		case "true", "false":
+			return &boolean{value: tok.text == "true", at: tok.pos}, p.err
+		case "for":
+			return nil, p.errorf(tok.pos, "for must follow a condition, as in writer.cpu > 75 for 3m")
+		}
+		v, err := p.variable(tok)
+		if err != nil {
+			return nil, err
+		}
+		return v, p.err
+	case tokenLeftParen:
+		p.next()
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenRightParen {
+			return nil, p.errorf(p.tok.pos, "expected ) to close the ( at column %d, got %s", tok.pos+1, p.tok.describe())
+		}
+		p.next()
+		return inner, p.err
+	}
+	return nil, p.errorf(tok.pos, "expected a number or metric, got %s", tok.describe())
+}
+
+// variable resolves a metric name, splitting off a trailing statistic
+func (p *parser) variable(tok token) (node, error) {
+	name := tok.text
+	if _, ok := Scalars[name]; ok {
+		return &variable{name: name, at: tok.pos}, nil
+	}
+	if _, ok := Series[name]; ok {
+		return &variable{name: name, stat: "avg", at: tok.pos}, nil
+	}
+	if i := strings.LastIndex(name, "."); i > 0 {
+		base, stat := name[:i], name[i+1:]
+		if _, ok := Series[base]; ok {
+			if !contains(Statistics, stat) {
+				return nil, p.errorf(tok.pos+i+1, "unknown statistic %q for %s, must be one of %s", stat, base, strings.Join(Statistics, ", "))
+			}
+			return &variable{name: base, stat: stat, at: tok.pos}, nil
+		}
+	}
+	return nil, p.errorf(tok.pos, "unknown metric %q, must be one of %s, %s", name, sortedKeys(Series), sortedKeys(Scalars))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
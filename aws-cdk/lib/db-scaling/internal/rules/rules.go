@@ -0,0 +1,171 @@
+// Package rules compiles user-defined scaling conditions such as
+// `writer.cpu.p90 > 75 && readers.lag.max < 2000` or
+// `connections.writer > 400 for 3m` and evaluates them against per-minute
+// metric series.
+package rules
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Series are the per-minute metrics a condition can refer to. A series name
+// may be followed by a statistic over the evaluation window, e.g.
+// writer.cpu.p90; without one it means the average. Within a `for` clause
+// the statistic covers each minute on its own.
+var Series = map[string]string{
+	"writer.cpu":          "writer CPUUtilization, percent",
+	"readers.cpu":         "average reader CPUUtilization, percent",
+	"connections.writer":  "writer DatabaseConnections",
+	"connections.readers": "average reader DatabaseConnections",
+	"readers.lag":         "DBClusterReplicaLagMaximum, milliseconds",
+	"writer.memory":       "writer FreeableMemory, megabytes",
+}
+
+// Scalars are the single values a condition can refer to
+var Scalars = map[string]string{
+	"readers.count": "readers of the cluster",
+}
+
+// Statistics can follow a series name
+var Statistics = []string{"avg", "min", "max", "last", "p50", "p90", "p95", "p99"}
+
+// Error is a compile error at a byte offset of the source
+type Error struct {
+	Source string
+	Pos    int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos+1, e.Msg)
+}
+
+// Caret returns the source with a marker under the error, indented by prefix
+func (e *Error) Caret(prefix string) string {
+	return prefix + e.Source + "\n" + prefix + strings.Repeat(" ", e.Pos) + "^"
+}
+
+// Condition is a compiled rule expression
+type Condition struct {
+	source string
+	root   node
+}
+
+// Compile parses and type-checks a condition. The error is an *Error.
+func Compile(source string) (*Condition, error) {
+	p := &parser{lexer: lexer{source: source}}
+	p.next()
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if err := check(root, boolType); err != nil {
+		if e, ok := err.(*Error); ok {
+			e.Source = source
+		}
+		return nil, err
+	}
+	return &Condition{source: source, root: root}, nil
+}
+
+// String returns the source of the condition
+func (c *Condition) String() string {
+	return c.source
+}
+
+// Refs returns the series the condition reads, sorted
+func (c *Condition) Refs() []string {
+	seen := make(map[string]bool)
+	walk(c.root, func(n node) {
+		if v, ok := n.(*variable); ok && v.stat != "" {
+			seen[v.name] = true
+		}
+	})
+	refs := make([]string, 0, len(seen))
+	for name := range seen {
+		refs = append(refs, name)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// Span returns how many minutes of data the condition reads with a window
+// of the given length: the window, or its longest `for` clause
+func (c *Condition) Span(window int) int {
+	longest := max(window, 1)
+	walk(c.root, func(n node) {
+		if h, ok := n.(*held); ok && h.minutes() > longest {
+			longest = h.minutes()
+		}
+	})
+	return longest
+}
+
+// Env is the data a condition is evaluated against
+type Env struct {
+	Series  map[string][]float64 // per-minute values, oldest first
+	Scalars map[string]float64
+	Window  int // minutes a statistic covers, EVALUATION_PERIODS
+}
+
+// Eval reports whether the condition holds. A condition that reads a series
+// without data in the window fails with an error rather than guessing.
+func (c *Condition) Eval(env *Env) (bool, error) {
+	value, err := c.root.eval(env, 0, max(env.Window, 1))
+	if err != nil {
+		return false, err
+	}
+	return value != 0, nil
+}
+
+// statistic computes stat over values
+func statistic(stat string, values []float64) float64 {
+	switch stat {
+	case "min":
+		lowest := math.Inf(1)
+		for _, v := range values {
+			lowest = math.Min(lowest, v)
+		}
+		return lowest
+	case "max":
+		highest := math.Inf(-1)
+		for _, v := range values {
+			highest = math.Max(highest, v)
+		}
+		return highest
+	case "last":
+		return values[len(values)-1]
+	case "avg":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+
+	// Percentiles use the nearest rank
+	var percentile float64
+	fmt.Sscanf(strings.TrimPrefix(stat, "p"), "%g", &percentile)
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(percentile / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+// minutes rounds a `for` duration up to whole evaluation steps
+func minutes(d time.Duration) int {
+	return int(math.Ceil(d.Minutes()))
+}
+
+func sortedKeys(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,110 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		column int
+		msg    string
+	}{
+		{"", 1, "empty expression"},
+		{"writer.cpu.p90 > && readers.lag.max < 2000", 18, `expected a number or metric, got "&&"`},
+		{"writer.cpux > 75", 1, `unknown metric "writer.cpux"`},
+		{"writer.cpu.p91 > 75", 12, `unknown statistic "p91" for writer.cpu`},
+		{"writer.cpu > 75 & readers.cpu < 30", 17, `did you mean "&&"?`},
+		{"connections.writer > 400 for", 29, "expected a duration such as 3m after for"},
+		{"connections.writer > 3m", 22, "only allowed after for"},
+		{"writer.cpu + 5", 12, "expected a condition, got a number"},
+		{"(writer.cpu > 75 && readers.cpu > 60", 37, "expected ) to close the ( at column 1"},
+		{"10 < writer.cpu < 80", 17, "comparisons can't be chained"},
+		{"writer.cpu > 75 && readers.count", 20, "expected a condition, got a number"},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.source)
+		if err == nil {
+			t.Errorf("%q: expected a compile error", tt.source)
+			continue
+		}
+		compileErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%q: expected *Error, got %T", tt.source, err)
+			continue
+		}
+		if compileErr.Pos+1 != tt.column || !strings.Contains(compileErr.Msg, tt.msg) {
+			t.Errorf("%q: expected %q at column %d, got %v", tt.source, tt.msg, tt.column, err)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	env := &Env{
+		Series: map[string][]float64{
+			"writer.cpu":         {50, 60, 70, 80, 90},
+			"readers.lag":        {100, 200, 1500},
+			"connections.writer": {350, 420, 450, 410},
+		},
+		Scalars: map[string]float64{"readers.count": 2},
+		Window:  3,
+	}
+
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"writer.cpu > 75", true},      // average of 70, 80, 90
+		{"writer.cpu.min >= 70", true}, // the window is the last 3 minutes
+		{"writer.cpu.p90 > 85 && readers.lag.max < 2000", true},
+		{"writer.cpu.last - writer.cpu.min > 30", false},
+		{"connections.writer > 400 for 3m", true}, // every minute, not the average
+		{"connections.writer > 400 for 4m", false},
+		{"writer.cpu.p90 > 55 for 4m", true},
+		{"readers.count < 3 && !(readers.lag.max > 2000)", true},
+		{"readers.count >= 3 && connections.readers > 10", false}, // missing data on the skipped side
+		{"readers.count < 3 || connections.readers > 10", true},
+	}
+
+	for _, tt := range tests {
+		cond, err := Compile(tt.source)
+		if err != nil {
+			t.Errorf("%q: unexpected compile error: %v", tt.source, err)
+			continue
+		}
+		got, err := cond.Eval(env)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.source, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.source, tt.want, got)
+		}
+	}
+
+	// Data that is missing, or too short for the for clause, is an error
+	for _, source := range []string{"connections.readers > 10", "readers.lag.last > 0 for 5m"} {
+		cond, err := Compile(source)
+		if err != nil {
+			t.Fatalf("%q: unexpected compile error: %v", source, err)
+		}
+		if _, err := cond.Eval(env); err == nil {
+			t.Errorf("%q: expected a missing data error", source)
+		}
+	}
+}
+
+func TestRefsAndSpan(t *testing.T) {
+	cond, err := Compile("writer.cpu.p90 > 75 && (readers.lag.max < 2000 for 10m) && readers.count > 0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if refs := strings.Join(cond.Refs(), ","); refs != "readers.lag,writer.cpu" {
+		t.Errorf("Expected refs readers.lag,writer.cpu, got %s", refs)
+	}
+	if cond.Span(3) != 10 {
+		t.Errorf("Expected a span of 10 minutes, got %d", cond.Span(3))
+	}
+}
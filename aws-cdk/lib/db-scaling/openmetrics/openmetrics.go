@@ -0,0 +1,288 @@
+// Package openmetrics collects gauges, counters and histograms and writes
+// them in the OpenMetrics text format understood by Prometheus. It is shared
+// by the autoscaler daemon and the lambda-functions binaries.
+package openmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text written by Registry.WriteTo
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Labels identifies one series within a metric family
+type Labels map[string]string
+
+// DefaultLatencyBuckets suit AWS API calls, in seconds
+var DefaultLatencyBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricType string
+
+const (
+	typeGauge     metricType = "gauge"
+	typeCounter   metricType = "counter"
+	typeHistogram metricType = "histogram"
+)
+
+// Registry holds metric families in registration order
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     metricType
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labels Labels
+	value  float64
+	counts []uint64 // histogram bucket counts, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(name, help string, typ metricType, buckets []float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, f := range r.families {
+		if f.name == name {
+			if f.typ != typ {
+				panic(fmt.Sprintf("openmetrics: %s registered as %s and %s", name, f.typ, typ))
+			}
+			return f
+		}
+	}
+
+	f := &family{name: name, help: help, typ: typ, buckets: buckets, series: make(map[string]*series)}
+	r.families = append(r.families, f)
+	return f
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	registry *Registry
+	family   *family
+}
+
+// Counter is a monotonically increasing total
+type Counter struct {
+	registry *Registry
+	family   *family
+}
+
+// Histogram counts observations into buckets
+type Histogram struct {
+	registry *Registry
+	family   *family
+}
+
+// Gauge registers (or returns the existing) gauge family
+func (r *Registry) Gauge(name, help string) *Gauge {
+	return &Gauge{registry: r, family: r.register(name, help, typeGauge, nil)}
+}
+
+// Counter registers (or returns the existing) counter family. The name must
+// not carry the _total suffix; it is added to the sample.
+func (r *Registry) Counter(name, help string) *Counter {
+	return &Counter{registry: r, family: r.register(name, help, typeCounter, nil)}
+}
+
+// Histogram registers (or returns the existing) histogram family with the
+// given upper bucket bounds in increasing order
+func (r *Registry) Histogram(name, help string, buckets []float64) *Histogram {
+	return &Histogram{registry: r, family: r.register(name, help, typeHistogram, buckets)}
+}
+
+func (r *Registry) seriesFor(f *family, labels Labels) *series {
+	key := labelString(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: labels}
+		if f.typ == typeHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Set sets the gauge for the given labels
+func (g *Gauge) Set(value float64, labels Labels) {
+	g.registry.mu.Lock()
+	defer g.registry.mu.Unlock()
+	g.registry.seriesFor(g.family, labels).value = value
+}
+
+// Reset drops every series, so label values that disappeared are no longer reported
+func (g *Gauge) Reset() {
+	g.registry.mu.Lock()
+	defer g.registry.mu.Unlock()
+	g.family.series = make(map[string]*series)
+}
+
+// Add increases the counter for the given labels
+func (c *Counter) Add(delta float64, labels Labels) {
+	if delta < 0 {
+		panic("openmetrics: counters cannot decrease")
+	}
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+	c.registry.seriesFor(c.family, labels).value += delta
+}
+
+// Inc increases the counter by one
+func (c *Counter) Inc(labels Labels) {
+	c.Add(1, labels)
+}
+
+// Observe records one observation for the given labels
+func (h *Histogram) Observe(value float64, labels Labels) {
+	h.registry.mu.Lock()
+	defer h.registry.mu.Unlock()
+
+	s := h.registry.seriesFor(h.family, labels)
+	for i, bound := range h.family.buckets {
+		if value <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// WriteTo writes all families in OpenMetrics text format, terminated by # EOF
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range r.families {
+		fmt.Fprintf(cw, "# TYPE %s %s\n", f.name, f.typ)
+		if f.help != "" {
+			fmt.Fprintf(cw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		}
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			switch f.typ {
+			case typeGauge:
+				writeSample(cw, f.name, s.labels, "", "", s.value)
+			case typeCounter:
+				writeSample(cw, f.name+"_total", s.labels, "", "", s.value)
+			case typeHistogram:
+				var cumulative uint64
+				for i, bound := range f.buckets {
+					cumulative += s.counts[i]
+					writeSample(cw, f.name+"_bucket", s.labels, "le", formatFloat(bound), float64(cumulative))
+				}
+				writeSample(cw, f.name+"_bucket", s.labels, "le", "+Inf", float64(s.count))
+				writeSample(cw, f.name+"_count", s.labels, "", "", float64(s.count))
+				writeSample(cw, f.name+"_sum", s.labels, "", "", s.sum)
+			}
+		}
+	}
+	fmt.Fprint(cw, "# EOF\n")
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// String returns the exposition text
+func (r *Registry) String() string {
+	var b strings.Builder
+	r.WriteTo(&b)
+	return b.String()
+}
+
+func writeSample(w io.Writer, name string, labels Labels, extraName, extraValue string, value float64) {
+	all := labels
+	if extraName != "" {
+		all = make(Labels, len(labels)+1)
+		for k, v := range labels {
+			all[k] = v
+		}
+		all[extraName] = extraValue
+	}
+	fmt.Fprintf(w, "%s%s %s\n", name, labelString(all), formatFloat(value))
+}
+
+func labelString(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf(`%s="%s"`, name, escapeLabel(labels[name]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
+
+func escapeHelp(value string) string { return helpEscaper.Replace(value) }
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,48 @@
+package openmetrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	registry := NewRegistry()
+
+	readers := registry.Gauge("docdb_readers", "Reader instances by status")
+	readers.Set(2, Labels{"status": "available"})
+	readers.Set(1, Labels{"status": "creating"})
+
+	decisions := registry.Counter("docdb_decisions", "Scaling decisions")
+	decisions.Inc(Labels{"action": "scale_out", "reason": `CPU "high"`})
+	decisions.Inc(Labels{"action": "scale_out", "reason": `CPU "high"`})
+
+	latency := registry.Histogram("aws_api_duration_seconds", "AWS API latency", []float64{0.1, 1})
+	latency.Observe(0.05, Labels{"operation": "DescribeDBClusters"})
+	latency.Observe(0.5, Labels{"operation": "DescribeDBClusters"})
+	latency.Observe(3, Labels{"operation": "DescribeDBClusters"})
+
+	expected := `# TYPE docdb_readers gauge
+# HELP docdb_readers Reader instances by status
+docdb_readers{status="available"} 2
+docdb_readers{status="creating"} 1
+# TYPE docdb_decisions counter
+# HELP docdb_decisions Scaling decisions
+docdb_decisions_total{action="scale_out",reason="CPU \"high\""} 2
+# TYPE aws_api_duration_seconds histogram
+# HELP aws_api_duration_seconds AWS API latency
+aws_api_duration_seconds_bucket{le="0.1",operation="DescribeDBClusters"} 1
+aws_api_duration_seconds_bucket{le="1",operation="DescribeDBClusters"} 2
+aws_api_duration_seconds_bucket{le="+Inf",operation="DescribeDBClusters"} 3
+aws_api_duration_seconds_count{operation="DescribeDBClusters"} 3
+aws_api_duration_seconds_sum{operation="DescribeDBClusters"} 3.55
+# EOF
+`
+	if result := registry.String(); result != expected {
+		t.Errorf("Unexpected exposition:\n%s\nexpected:\n%s", result, expected)
+	}
+
+	readers.Reset()
+	if strings.Contains(registry.String(), `status="creating"`) {
+		t.Error("Expected Reset to drop previous series")
+	}
+}
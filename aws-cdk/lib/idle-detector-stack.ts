@@ -0,0 +1,90 @@
+import * as cdk from 'aws-cdk-lib';
+import {Construct} from 'constructs';
+import * as lambda from 'aws-cdk-lib/aws-lambda';
+import * as iam from 'aws-cdk-lib/aws-iam';
+import * as scheduler from 'aws-cdk-lib/aws-scheduler';
+import * as schedulerTargets from 'aws-cdk-lib/aws-scheduler-targets';
+import {goLambdaAssetExclude} from './utils/go-lambda-asset';
+
+export interface IdleDetectorStackProps extends cdk.StackProps {
+    // Idle clusters are stopped, not just shrunk to the writer
+    readonly stopClusters?: boolean;
+    readonly idleHours?: number;
+}
+
+/**
+ * Account-wide job that shrinks the DocumentDB clusters of idle branch
+ * environments. It is deployed once, from the main branch.
+ */
+export class IdleDetectorStack extends cdk.Stack {
+    public readonly idleDetectorFunction: lambda.Function;
+
+    constructor(scope: Construct, id: string, props: IdleDetectorStackProps) {
+        super(scope, id, props);
+
+        this.idleDetectorFunction = new lambda.Function(this, 'IdleDetectorFunction', {
+            runtime: lambda.Runtime.PROVIDED_AL2023,
+            handler: 'bootstrap',
+            code: lambda.Code.fromAsset(".", {
+                exclude: goLambdaAssetExclude,
+                ignoreMode: cdk.IgnoreMode.GIT,
+                bundling: {
+                    image: lambda.Runtime.PROVIDED_AL2023.bundlingImage,
+                    command: [
+                        'bash', '-c', [
+                            'cd /asset-input/lambda-functions',
+                            'go mod tidy',
+                            'go mod download',
+                            'cd cmd/idle-detector',
+                            'GOOS=linux GOARCH=amd64 go build -o bootstrap .',
+                            'cp bootstrap /asset-output/'
+                        ].join(' && ')
+                    ],
+                    user: 'root'
+                }
+            }),
+            timeout: cdk.Duration.minutes(5),
+            memorySize: 256,
+            environment: {
+                IDLE_HOURS: String(props.idleHours ?? 72),
+                IDLE_STOP_CLUSTERS: String(props.stopClusters ?? false),
+                // Clusters are tagged with their environment by the infrastructure stack
+                IDLE_ENVIRONMENT_TAG: 'Environment'
+            }
+        });
+
+        this.idleDetectorFunction.addToRolePolicy(new iam.PolicyStatement({
+            effect: iam.Effect.ALLOW,
+            actions: [
+                'rds:DescribeDBClusters',
+                'rds:DescribeDBInstances',
+                'rds:ListTagsForResource',
+                // Pauses the cluster's autoscaler before removing readers
+                'rds:AddTagsToResource',
+                'rds:RemoveTagsFromResource',
+                'rds:DeleteDBInstance',
+                'rds:StopDBCluster',
+                'rds:StartDBCluster',
+                'cloudwatch:GetMetricStatistics'
+            ],
+            resources: ['*']
+        }));
+
+        const schedulerRole = new iam.Role(this, 'IdleDetectorSchedulerRole', {
+            assumedBy: new iam.ServicePrincipal('scheduler.amazonaws.com'),
+        });
+        schedulerRole.addToPolicy(new iam.PolicyStatement({
+            actions: ['lambda:InvokeFunction'],
+            resources: [this.idleDetectorFunction.functionArn]
+        }));
+
+        new scheduler.Schedule(this, 'IdleDetectorSchedule', {
+            // Daily, so that clusters DocumentDB restarts after 7 days stopped are stopped again
+            schedule: scheduler.ScheduleExpression.cron({minute: '0', hour: '2'}),
+            target: new schedulerTargets.LambdaInvoke(this.idleDetectorFunction, {
+                input: scheduler.ScheduleTargetInput.fromObject({action: 'scan'})
+            }),
+            description: 'Shrinks the DocumentDB clusters of idle branch environments'
+        });
+    }
+}
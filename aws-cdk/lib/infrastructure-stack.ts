@@ -223,6 +223,9 @@ export class InfrastructureStack extends cdk.Stack {
                 preferredMaintenanceWindow: 'sun:04:00-sun:05:00'
             });
 
+            // The idle detector tells branch environments from prod by this tag
+            cdk.Tags.of(this.docDbCluster).add('Environment', props.environment);
+
             // Allow inbound access from VPC CIDR to DocumentDB
             this.docDbCluster.connections.allowDefaultPortFrom(
                 ec2.Peer.ipv4(this.vpc.vpcCidrBlock),
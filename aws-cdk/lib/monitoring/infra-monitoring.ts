@@ -819,7 +819,7 @@ export class InfraMonitoring extends Construct {
                             'cd /asset-input',
                             'go mod tidy',
                             'go mod download',
-                            'GOOS=linux GOARCH=amd64 go build -o bootstrap main.go',
+                            'GOOS=linux GOARCH=amd64 go build -o bootstrap .',
                             'cp bootstrap /asset-output/'
                         ].join(' && ')
                     ],
@@ -829,17 +829,21 @@ export class InfraMonitoring extends Construct {
             timeout: cdk.Duration.minutes(5),
             environment: {
                 CLUSTER_IDENTIFIER: props.documentDbCluster?.clusterIdentifier || 'test-cluster',
-                MAX_READ_REPLICAS: "14",
-                MIN_READ_REPLICAS: "1",
+                // Replica bounds, cooldown and daily create limit come from the environment's
+                // profile: prod for main/master, staging for staging/develop, ephemeral otherwise
+                ENVIRONMENT: props.environment,
                 INSTANCE_CLASS: props.documentDbInstanceClass || "db.r6g.large",
-                COOLDOWN_MINUTES: "20", // Prevent scaling operations for 20 minutes after any scaling activity
                 CPU_SCALE_OUT_THRESHOLD: "80",
                 CPU_SCALE_IN_THRESHOLD: "30",
                 CONNECTIONS_SCALE_OUT_THRESHOLD: "500",
-                EVALUATION_PERIODS: "3"
+                EVALUATION_PERIODS: "3",
+                // Creates and deletes beyond the rate limits are deferred and reported to the alarm topic
+                ALERT_TOPIC_ARN: this.alarmSnsTopic.topicArn
             }
         });
 
+        this.alarmSnsTopic.grantPublish(autoScalingFunction);
+
         // Grant permissions to the Lambda function
         autoScalingFunction.addToRolePolicy(new iam.PolicyStatement({
             effect: iam.Effect.ALLOW,
@@ -848,6 +852,16 @@ export class InfraMonitoring extends Construct {
                 'rds:DescribeDBInstances',
                 'rds:CreateDBInstance',
                 'rds:DeleteDBInstance',
+                // Reconcile brings readers of a retired instance class back to INSTANCE_CLASS
+                'rds:ModifyDBInstance',
+                // Cluster tags hold the lease that serialises overlapping invocations
+                'rds:ListTagsForResource',
+                'rds:AddTagsToResource',
+                'rds:RemoveTagsFromResource',
+                // Policy documents loaded through POLICY_SOURCE
+                'ssm:GetParameter',
+                'appconfig:StartConfigurationSession',
+                'appconfig:GetLatestConfiguration',
                 'cloudwatch:GetMetricStatistics',
                 'cloudwatch:GetMetricData'
             ],
@@ -869,7 +883,9 @@ export class InfraMonitoring extends Construct {
                 input: scheduler.ScheduleTargetInput.fromObject({
                     source: 'scheduler',
                     environment: props.environment,
-                    clusterIdentifier: props.documentDbCluster?.clusterIdentifier || 'test-cluster'
+                    clusterIdentifier: props.documentDbCluster?.clusterIdentifier || 'test-cluster',
+                    // Lets retried invocations reuse the same reader name
+                    scheduledTime: scheduler.ContextAttribute.scheduledTime
                 })
             }),
             description: `DocumentDB autoscaling scheduler for ${props.environment}`
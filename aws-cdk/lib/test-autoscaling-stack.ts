@@ -10,6 +10,7 @@ import * as sns from 'aws-cdk-lib/aws-sns';
 import * as snsSubscriptions from 'aws-cdk-lib/aws-sns-subscriptions';
 import * as docdb from 'aws-cdk-lib/aws-docdb';
 import * as ec2 from 'aws-cdk-lib/aws-ec2';
+import { goLambdaAssetExclude } from './utils/go-lambda-asset';
 
 export interface TestAutoScalingStackProps extends cdk.StackProps {
     environment: string;
@@ -59,20 +60,27 @@ export class TestAutoScalingStack extends cdk.Stack {
             lambdaEnvironment.CLUSTER_IDENTIFIER = 'test-cluster';
         }
 
+        // Named explicitly, so that its role can be granted invoke on itself below
+        const loadGeneratorFunctionName = `docdb-load-generator-${props.environment}`;
+
         // Create Lambda function to generate load on DocumentDB (Go) - using Docker bundling
         this.loadGeneratorFunction = new lambda.Function(this, 'LoadGeneratorFunction', {
+            functionName: loadGeneratorFunctionName,
             runtime: lambda.Runtime.PROVIDED_AL2023,
             handler: 'bootstrap',
-            code: lambda.Code.fromAsset("lambda-functions", {
+            code: lambda.Code.fromAsset(".", {
+                // lambda-functions imports shared packages from lib/db-scaling
+                exclude: goLambdaAssetExclude,
+                ignoreMode: cdk.IgnoreMode.GIT,
                 bundling: {
                     image: lambda.Runtime.PROVIDED_AL2023.bundlingImage,
                     command: [
                         'bash', '-c', [
-                            'cd /asset-input',
+                            'cd /asset-input/lambda-functions',
                             'go mod tidy',
                             'go mod download',
                             'cd cmd/load-generator',
-                            'GOOS=linux GOARCH=amd64 go build -o bootstrap main.go',
+                            'GOOS=linux GOARCH=amd64 go build -o bootstrap .',
                             'cp bootstrap /asset-output/'
                         ].join(' && ')
                     ],
@@ -93,20 +101,36 @@ export class TestAutoScalingStack extends cdk.Stack {
         // Grant secrets access
         props.dbCredentialsSecret.grantRead(this.loadGeneratorFunction);
 
+        // With "invocations", the load generator fans out to copies of itself. The ARN
+        // is built from the name, since granting invoke on the function itself is circular.
+        this.loadGeneratorFunction.addToRolePolicy(new iam.PolicyStatement({
+            effect: iam.Effect.ALLOW,
+            actions: ['lambda:InvokeFunction'],
+            resources: [this.formatArn({
+                service: 'lambda',
+                resource: 'function',
+                resourceName: loadGeneratorFunctionName,
+                arnFormat: cdk.ArnFormat.COLON_RESOURCE_NAME
+            })]
+        }));
+
         // Create Lambda function to check metrics and scaling status (Go) - using Docker bundling
         this.metricsCheckerFunction = new lambda.Function(this, 'MetricsCheckerFunction', {
             runtime: lambda.Runtime.PROVIDED_AL2023,
             handler: 'bootstrap',
-            code: lambda.Code.fromAsset("lambda-functions", {
+            code: lambda.Code.fromAsset(".", {
+                // lambda-functions imports shared packages from lib/db-scaling
+                exclude: goLambdaAssetExclude,
+                ignoreMode: cdk.IgnoreMode.GIT,
                 bundling: {
                     image: lambda.Runtime.PROVIDED_AL2023.bundlingImage,
                     command: [
                         'bash', '-c', [
-                            'cd /asset-input',
+                            'cd /asset-input/lambda-functions',
                             'go mod tidy',
                             'go mod download',
                             'cd cmd/metrics-checker',
-                            'GOOS=linux GOARCH=amd64 go build -o bootstrap main.go',
+                            'GOOS=linux GOARCH=amd64 go build -o bootstrap .',
                             'cp bootstrap /asset-output/'
                         ].join(' && ')
                     ],
@@ -160,10 +184,18 @@ export class TestAutoScalingStack extends cdk.Stack {
         // Step Function tasks
         const startLoadGeneration = new stepfunctionsTasks.LambdaInvoke(this, 'StartLoadGeneration', {
             lambdaFunction: this.loadGeneratorFunction,
+            // Ramp up, spike, oscillate and go quiet, so that one run exercises scale-out,
+            // scale-in after a spike and hysteresis. Phases must fit the 15 minute timeout.
             payload: stepfunctions.TaskInput.fromObject({
-                'durationMinutes': 8,
                 'numThreads': 50,
-                'operationType': 'mixed'
+                'operationType': 'mixed',
+                'maxErrorRate': 0.05,
+                'profile': [
+                    { 'shape': 'ramp', 'durationMinutes': 3, 'rate': 100, 'toRate': 1500 },
+                    { 'shape': 'spike', 'durationMinutes': 3, 'rate': 500, 'toRate': 3000, 'spikeMinutes': 1 },
+                    { 'shape': 'sine', 'durationMinutes': 4, 'rate': 200, 'toRate': 1500, 'periodMinutes': 2 },
+                    { 'shape': 'constant', 'durationMinutes': 3, 'rate': 50 }
+                ]
             }),
             resultPath: '$.loadGenerationResult'
         });
@@ -0,0 +1,5 @@
+// The Go Lambdas are bundled from the CDK root so that the lambda-functions
+// module can resolve its replace directive to lib/db-scaling. Only those two
+// directories count towards the asset hash, without the binary that
+// `make build-local` leaves in lib/db-scaling.
+export const goLambdaAssetExclude = ['/*', '!/lambda-functions/', '!/lib/', '/lib/*', '!/lib/db-scaling/', '/lib/db-scaling/docdb-auto-scaling'];